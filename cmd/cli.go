@@ -6,9 +6,10 @@ package cmd
 
 import (
 	"cnabtool/pkg/config"
-	"cnabtool/pkg/data"
 	"cnabtool/pkg/logging"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/spf13/cobra"
@@ -18,6 +19,10 @@ import (
 var Version string
 var Commit string
 
+// wrong command line
+
+var ErrUsage = errors.New("usage")
+
 func BuildCliCmd(cnf *config.Config) *cobra.Command {
 
 	// rootCmd represents the base command when called without any subcommands
@@ -26,20 +31,32 @@ func BuildCliCmd(cnf *config.Config) *cobra.Command {
 		Short: "The cnab tool",
 		Long: `The tool for manipulating cnab content.
 `,
+		// errors are logged and mapped to exit code by caller
+		SilenceErrors: true,
+		SilenceUsage:  true,
 
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			// You can bind cobra and viper in a few locations, but PersistencePreRunE on the root command works well
 			ret := cnf.InitConfig(cmd)
+			if ret != nil {
+				// broken config is usage error
+				ret = fmt.Errorf("%w: %w", ErrUsage, ret)
+			}
 
-			// add sensitives to global list
-			data.Sensitives = append(data.Sensitives, data.Gc.Credentials.Password)
-			basicauth := []byte(data.Gc.Credentials.Username + ":" + data.Gc.Credentials.Password)
-			data.Sensitives = append(data.Sensitives, base64.StdEncoding.EncodeToString(basicauth))
+			// add sensitives to list of logger
+			cnf.Logger.AddSensitive(cnf.Credentials.Password)
+			basicauth := []byte(cnf.Credentials.Username + ":" + cnf.Credentials.Password)
+			cnf.Logger.AddSensitive(base64.StdEncoding.EncodeToString(basicauth))
 
 			return ret
 		},
 	}
 
+	// wrong flags are usage errors for all subcommands
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return fmt.Errorf("%w: %s", ErrUsage, err.Error())
+	})
+
 	// config file path
 	rootCmd.PersistentFlags().StringP("config", "c", "", "Customer config file path.")
 
@@ -57,15 +74,32 @@ func BuildCliCmd(cnf *config.Config) *cobra.Command {
 	rootCmd.PersistentFlags().IntVarP(&cnf.Timeout, "url", "t", 10000, "Timeout ms.")
 	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
 
+	rootCmd.PersistentFlags().BoolVarP(&cnf.Unsecure, "unsecure", "", false, "Skip TLS certificate verification.")
+	viper.BindPFlag("unsecure", rootCmd.PersistentFlags().Lookup("unsecure"))
+
+	rootCmd.PersistentFlags().StringVarP(&cnf.CACert, "cacert", "", "", "Extra CA bundle file (pem).")
+	viper.BindPFlag("cacert", rootCmd.PersistentFlags().Lookup("cacert"))
+
+	rootCmd.PersistentFlags().IntVarP(&cnf.Retries, "retries", "", config.ConfigDefaultRetries,
+		"Retries on transient registry errors (5xx, 429, connection reset).")
+	viper.BindPFlag("retries", rootCmd.PersistentFlags().Lookup("retries"))
+
 	rootCmd.AddCommand(VersionCmd(cnf))
 
 	// command noun "content"
 	contentCmd := ContentCmd(cnf)
 	rootCmd.AddCommand(contentCmd)
+	// manifests of inspected project are fetched in parallel
+	contentCmd.PersistentFlags().IntVarP(&cnf.Concurrency, "concurrency", "", config.ConfigDefaultConcurrency,
+		"Parallel manifest fetches while inspecting project.")
+	viper.BindPFlag("concurrency", contentCmd.PersistentFlags().Lookup("concurrency"))
 
 	// command verb "get" for "content"
 	contentCmd.AddCommand(GetManifestCmd(cnf))
 
+	// command verb "bundle" for "content"
+	contentCmd.AddCommand(BundleContentCmd(cnf))
+
 	// command verb "inspect" for "content"
 	inspectContentCmd := InspectContentCmd(cnf)
 	contentCmd.AddCommand(inspectContentCmd)
@@ -78,5 +112,11 @@ func BuildCliCmd(cnf *config.Config) *cobra.Command {
 	// local flag dry-run
 	deleteContentCmd.Flags().BoolVarP(&cnf.DryRun, "dry-run", "", false, "Dry-run mode")
 
+	// command verb "prune" for "content"
+	contentCmd.AddCommand(PruneContentCmd(cnf))
+
+	// command verb "untag" for "content"
+	contentCmd.AddCommand(UntagContentCmd(cnf))
+
 	return rootCmd
 }
@@ -5,14 +5,20 @@ Copyright © 2023 Aleksey Barabanov <alekseybb@gmail.com>
 package cmd
 
 import (
-	"cnabtool/pkg/client"
+	"bytes"
+	"cnabtool/pkg/cnab"
 	"cnabtool/pkg/config"
 	"cnabtool/pkg/content"
-	"cnabtool/pkg/data"
 	"cnabtool/pkg/logging"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 // contentCmd represents the content command
@@ -24,8 +30,8 @@ func ContentCmd(cnf *config.Config) *cobra.Command {
 		Use:   "content",
 		Short: "Content manipulation",
 		Long:  `Get indexes, inspect components of registry objects and delete cnab and images`,
-		Run: func(cc *cobra.Command, args []string) {
-			logging.Fatal("too a few arguments. use action's verb")
+		RunE: func(cc *cobra.Command, args []string) error {
+			return usageError("too a few arguments. use action's verb")
 		},
 	}
 
@@ -42,29 +48,87 @@ func GetManifestCmd(cnf *config.Config) *cobra.Command {
 		Short: "Get the content manifest",
 		Long:  `Get manifest with reference address and show it as json`,
 
-		Run: func(cc *cobra.Command, args []string) {
+		RunE: func(cc *cobra.Command, args []string) error {
 			// argument is registry reference string
 			if len(args) == 0 {
-				logging.Fatal("too a few arguments. use reference to index")
+				return usageError("too a few arguments. use reference to index")
 			}
 
-			config := (*content.Config)(cnf)
-
-			logging.Debug(fmt.Sprintf("config %+v", config))
-			response, _, err := config.GetManifest(args[0])
-			if err == nil {
-				if data.Gc.Verbosity >= logging.LogNormalLevel {
+			cnf.Logger.Debug(fmt.Sprintf("config %+v", cnf))
+			if cnf.Head {
+				response, err := cnab.HeadManifest((*cnab.Config)(cnf), args[0])
+				if err != nil {
+					return err
+				}
+				if cnf.Logger.Verbosity() >= logging.LogNormalLevel {
 					content.ResponsePrettyPrint(response)
 				}
-			} else {
-				logging.Error(fmt.Sprintf("%+v", err))
+				return nil
+			}
+			response, err := cnab.GetManifest((*cnab.Config)(cnf), args[0])
+			if err != nil {
+				return err
+			}
+			if cnf.Logger.Verbosity() >= logging.LogNormalLevel {
+				content.ResponsePrettyPrint(response)
 			}
+			return nil
 		},
 	}
 
+	// local flags
+	getContentCmd.Flags().BoolVarP(&cnf.Head, "head", "", false,
+		"Check manifest with HEAD request and show digest, media type and size only")
+
 	return getContentCmd
 }
 
+// BundleContentCmd get bundle.json of the cnab
+
+func BundleContentCmd(cnf *config.Config) *cobra.Command {
+
+	// cmd represents the content command
+	var bundleContentCmd = &cobra.Command{
+		Use:   "bundle",
+		Short: "Get bundle.json of the cnab",
+		Long: `Get bundle.json of cnab with reference address, check its digest
+and show it as canonical json`,
+
+		RunE: func(cc *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return usageError("too a few arguments. use reference to cnab")
+			}
+
+			cnf.Logger.Debug(fmt.Sprintf("config %+v", cnf))
+			bundle, err := cnab.GetBundle((*cnab.Config)(cnf), args[0])
+			if err != nil {
+				return err
+			}
+			if len(cnf.Field) != 0 {
+				bundle, err = cnab.BundleField(bundle, cnf.Field)
+				if err != nil {
+					return err
+				}
+			}
+			if len(cnf.Output) != 0 {
+				return os.WriteFile(cnf.Output, append(bundle, '\n'), 0644)
+			}
+			if cnf.Logger.Verbosity() >= logging.LogNormalLevel {
+				fmt.Println(string(bundle))
+			}
+			return nil
+		},
+	}
+
+	// local flags
+	bundleContentCmd.Flags().StringVarP(&cnf.Field, "field", "", "",
+		"Dot separated path of single field, e.g. images.web.image or invocationImages.0.image")
+	bundleContentCmd.Flags().StringVarP(&cnf.Output, "output", "o", "",
+		"Write bundle.json to file instead of stdout")
+
+	return bundleContentCmd
+}
+
 // InspectContentCmd inspect content of the cnab project
 
 func InspectContentCmd(cnf *config.Config) *cobra.Command {
@@ -76,41 +140,37 @@ func InspectContentCmd(cnf *config.Config) *cobra.Command {
 		Long: `Inspect all items in project with manifest reference address
 and report summary as json`,
 
-		Run: func(cc *cobra.Command, args []string) {
+		RunE: func(cc *cobra.Command, args []string) error {
 			if len(args) == 0 {
-				logging.Fatal("too a few arguments. use reference to cnab")
+				return usageError("too a few arguments. use reference to cnab")
 			}
 
-			config := (*content.Config)(cnf)
-
-			logging.Debug(fmt.Sprintf("config %+v", config))
-			regres, cl, err := config.GetManifest(args[0])
+			cnf.Logger.Debug(fmt.Sprintf("config %+v", cnf))
+			project, err := cnab.Open((*cnab.Config)(cnf), args[0])
 			if err != nil {
-				logging.Error(fmt.Sprintf("%+v", err))
-			} else {
-				if regres.Media != client.MediaTypeOciIndex {
-					logging.Error(fmt.Sprintf("unexpected media type %+v, must be cnab index", regres.Media))
-					// add comment to error
-					errLine, err := logging.PrettyString(regres.Content)
-					if err != nil {
-						errLine = regres.Content
-					}
-					logging.Error(fmt.Sprintf("%+v", errLine))
-				} else {
-					// continue if first reference is cnab index
-					// add first index
-					err := content.AddCnab(regres, cl.Tag)
-					if err != nil {
-						errLine := fmt.Sprintf("can't create first index, %+v", err.Error())
-						logging.Fatal(errLine)
-					}
-					config.InspectCnab(cl)
-					config.ShowCnabReport(cl)
+				return err
+			}
+			graph, err := project.Inspect()
+			if graph == nil {
+				return err
+			}
+			if cnf.Logger.Verbosity() >= logging.LogNormalLevel {
+				if err := printGraph(graph, cnf.Raw); err != nil {
+					return err
 				}
 			}
+			// partial report is printed, but exit code tells about it
+			if err != nil {
+				return err
+			}
+			return graph.Check()
 		},
 	}
 
+	// local flags
+	inspectContentCmd.Flags().BoolVarP(&cnf.FollowImages, "follow-images", "", false,
+		"Follow images of bundle.json pinned by digest, also in other repositories")
+
 	return inspectContentCmd
 }
 
@@ -123,48 +183,65 @@ func DeleteContentCmd(cnf *config.Config) *cobra.Command {
 		Use:   "delete",
 		Short: "Delete the cnab content",
 		Long: `Inspect cnab project and delete all possible component parts of
-selected cnab`,
+selected cnab, items reachable from other tags are kept`,
 
-		Run: func(cc *cobra.Command, args []string) {
+		RunE: func(cc *cobra.Command, args []string) error {
 			if len(args) == 0 {
-				logging.Fatal("too a few arguments. use reference to cnab")
+				return usageError("too a few arguments. use reference to cnab")
 			}
 
-			config := (*content.Config)(cnf)
-
-			logging.Debug(fmt.Sprintf("config %+v", config))
-			regres, cl, err := config.GetManifest(args[0])
+			cnf.Logger.Debug(fmt.Sprintf("config %+v", cnf))
+			project, err := cnab.Open((*cnab.Config)(cnf), args[0])
 			if err != nil {
-				logging.Error(fmt.Sprintf("%+v", err))
-			} else {
-				if regres.Media != client.MediaTypeOciIndex {
-					logging.Error(fmt.Sprintf("unexpected media type %+v, must be cnab index", regres.Media))
-					// add comment to error
-					errLine, err := logging.PrettyString(regres.Content)
-					if err != nil {
-						errLine = regres.Content
-					}
-					logging.Error(fmt.Sprintf("%+v", errLine))
-				} else {
-					// continue if first reference is cnab index
-					// add first index
-					err := content.AddCnab(regres, cl.Tag)
-					if err != nil {
-						errLine := fmt.Sprintf("can't create first index, %+v", err.Error())
-						logging.Fatal(errLine)
-					}
-					config.InspectCnab(cl)
-					if data.Gc.Verbosity >= logging.LogDebugLevel {
-						config.ShowCnabReport(cl)
-					}
-					config.DeleteCnab(cl)
-					config.PurgeEmptyFolders(cl)
+				return err
+			}
+			graph, inspectErr := project.Inspect()
+			if graph == nil {
+				return inspectErr
+			}
+			if cnf.Logger.Verbosity() >= logging.LogDebugLevel {
+				if err := printGraph(graph, cnf.Raw); err != nil {
+					return err
 				}
 			}
+			// not fetched tags may keep items of the cnab, so partial graph is not deleted
+			if inspectErr != nil {
+				return inspectErr
+			}
+			if cnf.UntagOnly {
+				if len(cnf.Output) != 0 {
+					return usageError("--output is not supported with --untag-only")
+				}
+				tags, err := project.PlanUntag()
+				if err != nil {
+					return err
+				}
+				_, err = project.Untag(tags)
+				return err
+			}
+			plan, err := project.PlanDelete()
+			if err != nil {
+				return err
+			}
+			err = deleteWithOutput(cnf, project, plan, plan)
+			if purgeErr := project.PurgeEmptyFolders(); err == nil {
+				err = purgeErr
+			}
+			return err
 		},
 	}
 
 	// local flags
+	deleteContentCmd.Flags().StringVarP(&cnf.Output, "output", "o", "",
+		"Write plan in dry-run mode or results of deletion to file, YAML for .yaml and .yml, JSON otherwise")
+	deleteContentCmd.Flags().BoolVarP(&cnf.AllBundles, "all-bundles", "", false,
+		"Delete every cnab of repository instead of the given reference only")
+	deleteContentCmd.Flags().BoolVarP(&cnf.UntagOnly, "untag-only", "", false,
+		"Delete tags of the cnab only, manifests are kept for registry garbage collection")
+	deleteContentCmd.Flags().BoolVarP(&cnf.Force, "force", "", false,
+		"Delete the cnab even if other tags point to it, they are deleted too")
+	deleteContentCmd.Flags().BoolVarP(&cnf.FollowImages, "follow-images", "", false,
+		"Follow images of bundle.json pinned by digest, images of other repositories are kept")
 	deleteContentCmd.Flags().BoolVarP(&cnf.Purge, "purge", "", false,
 		"Remove empty parent folders via Artifactory API after delete")
 	deleteContentCmd.Flags().StringVarP(&cnf.RepoKey, "repo-key", "", "",
@@ -172,3 +249,199 @@ selected cnab`,
 
 	return deleteContentCmd
 }
+
+// PruneContentCmd delete cnab, which are not kept by retention policy of config
+
+func PruneContentCmd(cnf *config.Config) *cobra.Command {
+
+	// cmd represents the content command
+	var pruneContentCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Delete cnab by retention policy",
+		Long: `Inspect repository and delete every cnab, which is not kept by prune policy
+of config: newest N, younger than N days, tags matched by regexes or semver
+constraints and allowlist. Components of kept cnab are kept`,
+
+		RunE: func(cc *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return usageError("too a few arguments. use repository without tag")
+			}
+
+			cnf.Logger.Debug(fmt.Sprintf("config %+v", cnf))
+			project, err := cnab.OpenRepository((*cnab.Config)(cnf), args[0])
+			if err != nil {
+				return err
+			}
+			// not fetched tags may be kept by policy, so partial graph is not pruned
+			if _, err := project.Inspect(); err != nil {
+				return err
+			}
+			plan, err := project.PlanPrune()
+			if err != nil {
+				return err
+			}
+			if cnf.Logger.Verbosity() >= logging.LogNormalLevel {
+				// full plan goes first
+				if err := printJSON(plan); err != nil {
+					return err
+				}
+			}
+			err = deleteWithOutput(cnf, project, plan, plan.Delete)
+			if purgeErr := project.PurgeEmptyFolders(); err == nil {
+				err = purgeErr
+			}
+			return err
+		},
+	}
+
+	// local flags
+	pruneContentCmd.Flags().BoolVarP(&cnf.DryRun, "dry-run", "", false, "Dry-run mode, print plan only")
+	pruneContentCmd.Flags().StringVarP(&cnf.Output, "output", "o", "",
+		"Write plan in dry-run mode or results of deletion to file, YAML for .yaml and .yml, JSON otherwise")
+	pruneContentCmd.Flags().BoolVarP(&cnf.FollowImages, "follow-images", "", false,
+		"Follow images of bundle.json pinned by digest, images of other repositories are kept")
+	pruneContentCmd.Flags().BoolVarP(&cnf.Purge, "purge", "", false,
+		"Remove empty parent folders via Artifactory API after delete")
+	pruneContentCmd.Flags().StringVarP(&cnf.RepoKey, "repo-key", "", "",
+		"Artifactory repository key (auto-derived from hostname by default)")
+
+	return pruneContentCmd
+}
+
+// UntagContentCmd delete tag without deleting manifest
+
+func UntagContentCmd(cnf *config.Config) *cobra.Command {
+
+	// cmd represents the content command
+	var untagContentCmd = &cobra.Command{
+		Use:   "untag",
+		Short: "Delete the tag",
+		Long: `Delete tag of reference, manifest and other tags are kept. If registry
+rejects tag deletion, manifest is deleted by digest when no other tag points to it`,
+
+		RunE: func(cc *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return usageError("too a few arguments. use reference with tag")
+			}
+
+			cnf.Logger.Debug(fmt.Sprintf("config %+v", cnf))
+			_, err := cnab.Untag((*cnab.Config)(cnf), args[0])
+			return err
+		},
+	}
+
+	// local flags
+	untagContentCmd.Flags().BoolVarP(&cnf.DryRun, "dry-run", "", false, "Dry-run mode")
+
+	return untagContentCmd
+}
+
+// printGraph print inspected graph as short json report or raw items
+
+func printGraph(graph *cnab.Graph, raw bool) error {
+	if raw { // very long output
+		// untagged components are listed too
+		for i, item := range graph.Items {
+			fmt.Printf("Project item %d: %s ---- %+v\n\n\n", i+1, item.Tag, item)
+		}
+		return nil
+	}
+	slout, err := json.Marshal(graph.Report)
+	if err != nil {
+		return errors.New(fmt.Sprintf("can not convert list item - %+v", err.Error()))
+	}
+	if jsonres, err := logging.PrettyString(string(slout)); err == nil {
+		fmt.Println(jsonres)
+	} else {
+		// print as is
+		fmt.Printf("%+v\n", graph.Report)
+	}
+	return nil
+}
+
+// printJSON print value as pretty json
+
+func printJSON(value interface{}) error {
+	out, err := json.Marshal(value)
+	if err != nil {
+		return errors.New(fmt.Sprintf("can not convert to json - %+v", err.Error()))
+	}
+	jsonres, err := logging.PrettyString(string(out))
+	if err != nil {
+		return err
+	}
+	fmt.Println(jsonres)
+	return nil
+}
+
+// deleteWithOutput execute delete plan and write report to output file of config:
+// report is the plan in dry run mode, otherwise outcome with results of requests
+
+func deleteWithOutput(cnf *config.Config, project *cnab.Project, report interface{}, plan *cnab.DeletePlan) error {
+	if cnf.DryRun && len(cnf.Output) != 0 {
+		if err := writeOutput(cnf.Output, report); err != nil {
+			return err
+		}
+	}
+	outcome, err := project.Delete(plan)
+	printResultBodies(cnf, outcome.Results)
+	if !cnf.DryRun && len(cnf.Output) != 0 {
+		// results are written even if some items were not deleted
+		if writeErr := writeOutput(cnf.Output, outcome); writeErr != nil {
+			cnf.Logger.Error(writeErr.Error())
+			if err == nil {
+				err = writeErr
+			}
+		}
+	}
+	return err
+}
+
+// writeOutput write value to file as YAML for .yaml and .yml extensions, otherwise as JSON
+
+func writeOutput(path string, value interface{}) error {
+	var out []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(value); err != nil {
+			return errors.New(fmt.Sprintf("can not convert to yaml - %+v", err.Error()))
+		}
+		encoder.Close()
+		out = buf.Bytes()
+	default:
+		jsonout, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return errors.New(fmt.Sprintf("can not convert to json - %+v", err.Error()))
+		}
+		out = append(jsonout, '\n')
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return fmt.Errorf("can not write output file, %w", err)
+	}
+	return nil
+}
+
+// printResultBodies print json bodies, which registry explains failures with
+
+func printResultBodies(cnf *config.Config, results []cnab.DeleteResult) {
+	if cnf.Logger.Verbosity() < logging.LogNormalLevel {
+		return
+	}
+	for _, result := range results {
+		if len(result.Body) == 0 {
+			continue
+		}
+		if jsonres, err := logging.PrettyString(result.Body); err == nil {
+			fmt.Printf("%s\n", jsonres)
+		}
+	}
+}
+
+// usageError is wrong command line, check it with errors.Is(err, ErrUsage)
+
+func usageError(message string) error {
+	return fmt.Errorf("%w: %s", ErrUsage, message)
+}
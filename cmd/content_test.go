@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"cnabtool/pkg/cnab"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestWriteOutput проверяет выбор формата файла отчёта по расширению
+func TestWriteOutput(t *testing.T) {
+	outcome := &cnab.DeleteOutcome{
+		Results: []cnab.DeleteResult{{
+			Entry:     cnab.DeleteEntry{Digest: "sha256:cnab", Reason: "target", Size: 512, Referrers: []string{"sha256:parent"}},
+			Status:    403,
+			Body:      `{"errors":[{"code":"DENIED"}]}`,
+			Error:     "delete sha256:cnab failed with status 403",
+			Started:   time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+			ElapsedMs: 15,
+		}},
+		Failed: 1,
+	}
+	dir := t.TempDir()
+
+	jsonPath := filepath.Join(dir, "result.json")
+	if err := writeOutput(jsonPath, outcome); err != nil {
+		t.Fatalf("writeOutput should not return error, got: %v", err)
+	}
+	content, _ := os.ReadFile(jsonPath)
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatalf("json output is invalid: %v\n%s", err, content)
+	}
+	results := decoded["results"].([]interface{})
+	result := results[0].(map[string]interface{})
+	if result["status"] != float64(403) || result["elapsedMs"] != float64(15) || result["started"] != "2026-10-17T12:00:00Z" ||
+		result["entry"].(map[string]interface{})["size"] != float64(512) || decoded["failed"] != float64(1) {
+		t.Errorf("json output = %s", content)
+	}
+
+	yamlPath := filepath.Join(dir, "result.YML")
+	if err := writeOutput(yamlPath, outcome); err != nil {
+		t.Fatalf("writeOutput should not return error, got: %v", err)
+	}
+	content, _ = os.ReadFile(yamlPath)
+	var back cnab.DeleteOutcome
+	if err := yaml.Unmarshal(content, &back); err != nil {
+		t.Fatalf("yaml output is invalid: %v\n%s", err, content)
+	}
+	if len(back.Results) != 1 || back.Results[0].Error != outcome.Results[0].Error || back.Results[0].Entry.Referrers[0] != "sha256:parent" ||
+		!back.Results[0].Started.Equal(outcome.Results[0].Started) || !strings.Contains(string(content), "elapsedMs: 15") {
+		t.Errorf("yaml output = %s", content)
+	}
+
+	if err := writeOutput(filepath.Join(dir, "absent", "plan.json"), outcome); err == nil {
+		t.Error("writeOutput should return error for missing directory")
+	}
+}
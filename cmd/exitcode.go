@@ -0,0 +1,51 @@
+/*
+Copyright © 2023 Aleksey Barabanov <alekseybb@gmail.com>
+*/
+
+package cmd
+
+import (
+	"cnabtool/pkg/cnab"
+	"errors"
+	"net"
+)
+
+// process exit codes, documented in README
+
+const (
+	ExitOK        = 0 // success
+	ExitFailure   = 1 // unclassified failure
+	ExitUsage     = 2 // wrong command line or config
+	ExitAuth      = 3 // registry refused credentials
+	ExitNotFound  = 4 // reference, repository or bundle field not found
+	ExitPartial   = 5 // some tags were not fetched or some items were not deleted
+	ExitIntegrity = 6 // digest mismatch, dangling links or invalid content
+	ExitNetwork   = 7 // connection failure, timeout or registry unavailable
+)
+
+// ExitCode map outcome of command to exit code
+
+func ExitCode(err error) int {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		// logged errors don't change exit code, failed command returns error
+		return ExitOK
+	case errors.Is(err, ErrUsage), errors.Is(err, cnab.ErrPolicyInvalid), errors.Is(err, cnab.ErrSharedTags),
+		errors.Is(err, cnab.ErrTLSConfig):
+		return ExitUsage
+	case errors.Is(err, cnab.ErrUnauthorized):
+		return ExitAuth
+	case errors.Is(err, cnab.ErrManifestNotFound), errors.Is(err, cnab.ErrFieldNotFound):
+		return ExitNotFound
+	case errors.Is(err, cnab.ErrPartial):
+		return ExitPartial
+	case errors.Is(err, cnab.ErrDigestMismatch), errors.Is(err, cnab.ErrDanglingLinks),
+		errors.Is(err, cnab.ErrNotCnabIndex), errors.Is(err, cnab.ErrTagListInvalid):
+		return ExitIntegrity
+	case errors.Is(err, cnab.ErrUnavailable), errors.As(err, &netErr):
+		return ExitNetwork
+	default:
+		return ExitFailure
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"cnabtool/pkg/cnab"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+// TestExitCode проверяет соответствие классов ошибок кодам завершения
+func TestExitCode(t *testing.T) {
+	testcases := []struct {
+		err  error
+		want int
+	}{
+		{err: nil, want: ExitOK},
+		{err: errors.New("something"), want: ExitFailure},
+		{err: usageError("too a few arguments"), want: ExitUsage},
+		{err: fmt.Errorf("%w: no rules", cnab.ErrPolicyInvalid), want: ExitUsage},
+		{err: fmt.Errorf("%w: manifest has tags latest", cnab.ErrSharedTags), want: ExitUsage},
+		{err: fmt.Errorf("%w: can not read CA", cnab.ErrTLSConfig), want: ExitUsage},
+		{err: fmt.Errorf("failed to fetch tag list %w", cnab.ErrUnauthorized), want: ExitAuth},
+		{err: fmt.Errorf("%w: v1", cnab.ErrManifestNotFound), want: ExitNotFound},
+		{err: fmt.Errorf("%w: images.web", cnab.ErrFieldNotFound), want: ExitNotFound},
+		{err: fmt.Errorf("%w: 1 of 2", cnab.ErrPartial), want: ExitPartial},
+		{err: fmt.Errorf("reference %w", cnab.ErrDigestMismatch), want: ExitIntegrity},
+		{err: fmt.Errorf("%w: 3 links", cnab.ErrDanglingLinks), want: ExitIntegrity},
+		{err: fmt.Errorf("%w: status 503", cnab.ErrUnavailable), want: ExitNetwork},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: ExitNetwork},
+	}
+	for _, tc := range testcases {
+		if got := ExitCode(tc.err); got != tc.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
@@ -7,7 +7,7 @@ package main
 import (
 	"cnabtool/cmd"
 	"cnabtool/pkg/config"
-	"cnabtool/pkg/data"
+	"fmt"
 	"os"
 )
 
@@ -22,12 +22,17 @@ func main() {
 
 	// make config with defaults and fill values from configs
 	cnf := config.New()
+	// log level until config is read
+	cnf.Logger.SetVerbosity(cnf.Verbosity)
 
 	cli := cmd.BuildCliCmd(cnf)
 
 	err := cli.Execute()
+	if err != nil {
+		cnf.Logger.Error(fmt.Sprintf("%+v", err))
+	}
 
-	if err != nil || data.Gc.Error > 0 {
-		os.Exit(1)
+	if code := cmd.ExitCode(err); code != cmd.ExitOK {
+		os.Exit(code)
 	}
 }
@@ -0,0 +1,256 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// token scope actions
+// https://distribution.github.io/distribution/spec/auth/scope/
+
+const (
+	ScopeActionPull   = "pull"
+	ScopeActionDelete = "pull,delete"
+)
+
+const DefaultTokenExpiry = 60 // seconds, if token server doesn't report expires_in
+
+// authentication challenge from WWW-Authenticate header
+
+type Challenge struct {
+	Scheme string            // lower case scheme, basic or bearer
+	Params map[string]string // realm, service, scope and etc.
+}
+
+// bearer token cached for a scope
+
+type bearerToken struct {
+	Token   string
+	Expires time.Time
+}
+
+// tokens cache, may be shared between client copies
+
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]bearerToken
+}
+
+func (tc *tokenCache) get(scope string) (string, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	bt, ok := tc.tokens[scope]
+	if !ok || time.Now().After(bt.Expires) {
+		return "", false
+	}
+	return bt.Token, true
+}
+
+func (tc *tokenCache) put(scope string, bt bearerToken) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.tokens == nil {
+		tc.tokens = make(map[string]bearerToken)
+	}
+	tc.tokens[scope] = bt
+}
+
+// ParseChallenge decode WWW-Authenticate header value
+// e.g. Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull"
+
+func ParseChallenge(header string) (*Challenge, error) {
+	header = strings.TrimSpace(header)
+	if len(header) == 0 {
+		return nil, errors.New("empty authenticate header")
+	}
+	scheme, rest, _ := strings.Cut(header, " ")
+	ch := &Challenge{
+		Scheme: strings.ToLower(scheme),
+		Params: make(map[string]string),
+	}
+
+	// params are key=value or key="quoted, value" separated by comma
+	for len(rest) > 0 {
+		rest = strings.TrimLeft(rest, " ,")
+		key, tail, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value := ""
+		if strings.HasPrefix(tail, "\"") {
+			// quoted value, take care about escaped quotes
+			var sb strings.Builder
+			i := 1
+			for ; i < len(tail); i++ {
+				if tail[i] == '\\' && i+1 < len(tail) {
+					i++
+					sb.WriteByte(tail[i])
+					continue
+				}
+				if tail[i] == '"' {
+					break
+				}
+				sb.WriteByte(tail[i])
+			}
+			if i >= len(tail) {
+				return nil, errors.New(fmt.Sprintf("unterminated quoted value in authenticate header %s", header))
+			}
+			value = sb.String()
+			rest = tail[i+1:]
+		} else {
+			value, rest, _ = strings.Cut(tail, ",")
+			value = strings.TrimSpace(value)
+		}
+		ch.Params[key] = value
+	}
+	return ch, nil
+}
+
+// repositoryScope make scope for current repository
+
+func (cl *RegClient) repositoryScope(actions string) string {
+	if len(cl.Repository) == 0 {
+		return ""
+	}
+	return "repository:" + cl.Repository + ":" + actions
+}
+
+// authorize set authorization header - cached bearer token for scope or basic credentials
+
+func (cl *RegClient) authorize(req *http.Request, scope string) {
+	if cl.tokens != nil && len(scope) != 0 {
+		if token, ok := cl.tokens.get(scope); ok {
+			req.Header.Set("Authorization", "Bearer "+token)
+			return
+		}
+	}
+	if len(cl.Credentials.Username) != 0 || len(cl.Credentials.Password) != 0 {
+		req.SetBasicAuth(cl.Credentials.Username, cl.Credentials.Password)
+	}
+}
+
+// FetchToken get bearer token from challenge realm, use basic credentials if exists or anonymous otherwise
+
+func (cl *RegClient) FetchToken(ch *Challenge, scope string) (string, error) {
+	realm := ch.Params["realm"]
+	if len(realm) == 0 {
+		return "", errors.New("bearer challenge has no realm")
+	}
+	tokenurl, err := url.Parse(realm)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("invalid realm %s, %+v", realm, err.Error()))
+	}
+	query := tokenurl.Query()
+	if service := ch.Params["service"]; len(service) != 0 {
+		query.Set("service", service)
+	}
+	// multiple scopes are separated by space
+	for _, s := range strings.Fields(scope) {
+		query.Add("scope", s)
+	}
+	tokenurl.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, tokenurl.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", cl.Client)
+	if len(cl.Credentials.Username) != 0 || len(cl.Credentials.Password) != 0 {
+		req.SetBasicAuth(cl.Credentials.Username, cl.Credentials.Password)
+	}
+
+	cl.Logger.Debug(fmt.Sprintf("token request %s", tokenurl.String()))
+
+	res, err := cl.send(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	bytesbody, err := io.ReadAll(io.LimitReader(res.Body, MaxBodySize))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("failed to fetch token body %s", err.Error()))
+	}
+	if res.StatusCode != 200 {
+		return "", fmt.Errorf("%w: token server %s returns %s: %s", ErrUnauthorized, tokenurl.Host, res.Status, strings.Join(strings.Fields(string(bytesbody)), " "))
+	}
+
+	var tokres struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(bytesbody, &tokres); err != nil {
+		return "", errors.New(fmt.Sprintf("token response is not valid json, %s", err.Error()))
+	}
+	token := tokres.Token
+	if len(token) == 0 {
+		token = tokres.AccessToken
+	}
+	if len(token) == 0 {
+		return "", errors.New("token server returns empty token")
+	}
+	// token must not leak to log
+	cl.Logger.AddSensitive(token)
+
+	expires := tokres.ExpiresIn
+	if expires <= 0 {
+		expires = DefaultTokenExpiry
+	}
+	if cl.tokens == nil {
+		cl.tokens = &tokenCache{}
+	}
+	cl.tokens.put(scope, bearerToken{
+		Token:   token,
+		Expires: time.Now().Add(time.Duration(expires) * time.Second),
+	})
+	return token, nil
+}
+
+// doRequest send request and pass bearer challenge if registry asks for it
+
+func (cl *RegClient) doRequest(req *http.Request, scope string) (*http.Response, error) {
+	cl.authorize(req, scope)
+
+	cl.Logger.Debug(fmt.Sprintf("request %+v", req))
+
+	res, err := cl.send(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusUnauthorized {
+		return res, nil
+	}
+
+	ch, err := ParseChallenge(res.Header.Get("WWW-Authenticate"))
+	if err != nil || ch.Scheme != "bearer" {
+		// nothing to do, caller decides about 401
+		return res, nil
+	}
+	if len(scope) == 0 {
+		scope = ch.Params["scope"]
+	}
+
+	token, err := cl.FetchToken(ch, scope)
+	if err != nil {
+		cl.Logger.Error(fmt.Sprintf("failed to fetch bearer token, %+v", err.Error()))
+		return res, nil
+	}
+
+	// drop first answer and repeat request with token
+	io.Copy(io.Discard, io.LimitReader(res.Body, MaxBodySize))
+	res.Body.Close()
+
+	retry := req.Clone(req.Context())
+	retry.Header.Set("Authorization", "Bearer "+token)
+	cl.Logger.Debug(fmt.Sprintf("repeat request with bearer token for scope %s", scope))
+	return cl.send(retry)
+}
@@ -0,0 +1,235 @@
+package client
+
+import (
+	"cnabtool/pkg/data"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParseChallenge проверяет разбор заголовка WWW-Authenticate
+func TestParseChallenge(t *testing.T) {
+	testcases := []struct {
+		name    string
+		header  string
+		scheme  string
+		params  map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "docker hub bearer",
+			header: `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull"`,
+			scheme: "bearer",
+			params: map[string]string{
+				"realm":   "https://auth.docker.io/token",
+				"service": "registry.docker.io",
+				"scope":   "repository:library/alpine:pull",
+			},
+		},
+		{
+			name:   "scope with comma",
+			header: `Bearer realm="https://harbor.example.com/service/token", service="harbor-registry", scope="repository:app/cnab:pull,delete"`,
+			scheme: "bearer",
+			params: map[string]string{
+				"realm":   "https://harbor.example.com/service/token",
+				"service": "harbor-registry",
+				"scope":   "repository:app/cnab:pull,delete",
+			},
+		},
+		{
+			name:   "basic unquoted",
+			header: `Basic realm=Registry`,
+			scheme: "basic",
+			params: map[string]string{
+				"realm": "Registry",
+			},
+		},
+		{
+			name:    "empty header",
+			header:  "",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote",
+			header:  `Bearer realm="https://auth.example.com`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ch, err := ParseChallenge(tc.header)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ParseChallenge(%q) should return error", tc.header)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseChallenge(%q) should not return error, got: %v", tc.header, err)
+			}
+			if ch.Scheme != tc.scheme {
+				t.Errorf("Scheme = %q, want %q", ch.Scheme, tc.scheme)
+			}
+			for k, v := range tc.params {
+				if ch.Params[k] != v {
+					t.Errorf("Params[%q] = %q, want %q", k, ch.Params[k], v)
+				}
+			}
+		})
+	}
+}
+
+// TestGetRegIndex_BearerFlow проверяет получение токена по challenge и повтор запроса
+func TestGetRegIndex_BearerFlow(t *testing.T) {
+
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+	tokenRequests := 0
+	var tokenScope, tokenService, tokenAuth string
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokenRequests++
+			tokenScope = r.URL.Query().Get("scope")
+			tokenService = r.URL.Query().Get("service")
+			tokenAuth = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"secret-token","expires_in":300}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test-registry",scope="repository:repo/cnab:pull"`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(401)
+			w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED"}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeOciIndex)
+		w.WriteHeader(200)
+		w.Write([]byte(manifest))
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		Scheme: "http",
+		Credentials: data.Credentials{
+			Username: "testuser",
+			Password: "testpass",
+		},
+		Client: "cnabtool/0.1.1",
+	}
+	cl := NewRegClient(cfg, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+	cl.Tag = "v1"
+
+	regres, err := cl.GetRegIndex()
+	if err != nil {
+		t.Fatalf("GetRegIndex should pass bearer challenge, got: %v", err)
+	}
+	if regres.Status != 200 {
+		t.Errorf("GetRegIndex status = %d, want 200", regres.Status)
+	}
+	if tokenScope != "repository:repo/cnab:pull" {
+		t.Errorf("token scope = %q, want %q", tokenScope, "repository:repo/cnab:pull")
+	}
+	if tokenService != "test-registry" {
+		t.Errorf("token service = %q, want %q", tokenService, "test-registry")
+	}
+	if !strings.HasPrefix(tokenAuth, "Basic ") {
+		t.Errorf("token request must use basic credentials, got %q", tokenAuth)
+	}
+
+	// второй запрос использует токен из кэша
+	if _, err := cl.GetRegIndex(); err != nil {
+		t.Fatalf("second GetRegIndex should not return error, got: %v", err)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("token requests = %d, want 1 (cached by scope)", tokenRequests)
+	}
+}
+
+// TestWebDelete_BearerDeleteScope проверяет scope pull,delete для удаления
+func TestWebDelete_BearerDeleteScope(t *testing.T) {
+
+	var tokenScope, tokenAuth string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokenScope = r.URL.Query().Get("scope")
+			tokenAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{"access_token":"delete-token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer delete-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test-registry"`)
+			w.WriteHeader(401)
+			return
+		}
+		w.WriteHeader(202)
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		Scheme: "http",
+		Client: "cnabtool/0.1.1",
+	}
+	cl := NewRegClient(cfg, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	res, err := cl.WebDelete(server.URL + "/v2/repo/cnab/manifests/sha256:abc")
+	if err != nil {
+		t.Fatalf("WebDelete should not return error, got: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 202 {
+		t.Errorf("WebDelete status = %d, want 202", res.StatusCode)
+	}
+	if tokenScope != "repository:repo/cnab:pull,delete" {
+		t.Errorf("token scope = %q, want %q", tokenScope, "repository:repo/cnab:pull,delete")
+	}
+	if tokenAuth != "" {
+		t.Errorf("anonymous token request must not send Authorization, got %q", tokenAuth)
+	}
+}
+
+// TestGetRegIndex_BearerTokenFailure проверяет, что отказ сервера токенов даёт unauthorized
+func TestGetRegIndex_BearerTokenFailure(t *testing.T) {
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/token" {
+			w.WriteHeader(403)
+			w.Write([]byte(`{"details":"access denied"}`))
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test-registry"`)
+		w.WriteHeader(401)
+		w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		Scheme: "http",
+		Client: "cnabtool/0.1.1",
+	}
+	cl := NewRegClient(cfg, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+	cl.Tag = "v1"
+
+	regres, err := cl.GetRegIndex()
+	if err == nil {
+		t.Fatal("GetRegIndex should return error when token server refuses")
+	}
+	if regres.Status != 401 {
+		t.Errorf("GetRegIndex status = %d, want 401", regres.Status)
+	}
+	if !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("error = %q, should contain unauthorized", err.Error())
+	}
+}
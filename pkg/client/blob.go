@@ -0,0 +1,257 @@
+package client
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// max redirects of blob request, registries redirect to storage once or twice
+
+const MaxBlobRedirects = 10
+
+// blob metadata from headers
+
+type BlobInfo struct {
+	Digest string
+	Size   int64 // -1 if unknown
+	Media  string
+}
+
+// BlobReader stream blob body and check its digest at the end of stream
+
+type BlobReader struct {
+	BlobInfo
+
+	body     io.ReadCloser
+	hash     hash.Hash // nil for partial content
+	expected string
+	read     int64
+}
+
+// blobURL make blob address in repository
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-blobs
+
+func (cl *RegClient) blobURL(digest string) string {
+	return cl.Scheme + "://" + cl.Registry + "/v2/" + cl.Repository + "/blobs/" + digest
+}
+
+// blobRequest do GET or HEAD request for blob. Redirects to storage backends (S3, Artifactory)
+// are followed by hand, registry credentials are sent to registry host only
+
+func (cl *RegClient) blobRequest(method, digest, rangeHeader string) (*http.Response, error) {
+	if _, _, err := ParseDigest(digest); err != nil {
+		return nil, err
+	}
+
+	newRequest := func(url string) (*http.Request, error) {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		// no Accept-Encoding, digest is calculated over stored bytes
+		req.Header.Set("User-Agent", cl.Client)
+		if len(rangeHeader) != 0 {
+			req.Header.Set("Range", rangeHeader)
+		}
+		return req, nil
+	}
+
+	// redirect answers are returned to us instead of following
+	bcl := cl.Clone()
+	bcl.WebClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	req, err := newRequest(cl.blobURL(digest))
+	if err != nil {
+		return nil, err
+	}
+	res, err := bcl.doRequest(req, cl.repositoryScope(ScopeActionPull))
+	for redirects := 0; err == nil && isRedirect(res.StatusCode); redirects++ {
+		location, lerr := res.Location()
+		io.Copy(io.Discard, io.LimitReader(res.Body, MaxBodySize))
+		res.Body.Close()
+		if lerr != nil {
+			return nil, errors.New(fmt.Sprintf("blob %s redirect without location, %+v", digest, lerr.Error()))
+		}
+		if redirects >= MaxBlobRedirects {
+			return nil, errors.New(fmt.Sprintf("blob %s: stopped after %d redirects", digest, redirects))
+		}
+		cl.Logger.Debug(fmt.Sprintf("blob %s is redirected to %s://%s%s", digest, location.Scheme, location.Host, location.Path))
+
+		req, err = newRequest(location.String())
+		if err != nil {
+			return nil, err
+		}
+		if location.Host == cl.Registry {
+			res, err = bcl.doRequest(req, cl.repositoryScope(ScopeActionPull))
+		} else {
+			// presigned storage url, registry token must not leak there
+			res, err = bcl.send(req)
+		}
+	}
+	return res, err
+}
+
+// isRedirect check redirect status
+
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// blobError read error answer and classify it by status
+
+func blobError(res *http.Response, digest string) error {
+	bytesbody, _ := io.ReadAll(io.LimitReader(res.Body, MaxBodySize))
+	res.Body.Close()
+	return StatusError(res.StatusCode, fmt.Sprintf("failed to fetch blob %s %s: %s", digest, res.Status, strings.Join(strings.Fields(string(bytesbody)), " ")))
+}
+
+// HeadBlob get blob size and media type without body
+
+func (cl *RegClient) HeadBlob(digest string) (*BlobInfo, error) {
+	res, err := cl.blobRequest(http.MethodHead, digest, "")
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		return nil, blobError(res, digest)
+	}
+	res.Body.Close()
+	info := &BlobInfo{Digest: digest, Size: res.ContentLength, Media: res.Header.Get("Content-Type")}
+	if header := res.Header.Get("Docker-Content-Digest"); len(header) != 0 && header != digest {
+		return nil, fmt.Errorf("%w: blob %s has Docker-Content-Digest %s", ErrDigestMismatch, digest, header)
+	}
+	return info, nil
+}
+
+// OpenBlob open blob stream, digest is checked when stream is read to the end
+
+func (cl *RegClient) OpenBlob(digest string) (*BlobReader, error) {
+	res, err := cl.blobRequest(http.MethodGet, digest, "")
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		return nil, blobError(res, digest)
+	}
+	algorithm, _, _ := ParseDigest(digest)
+	h, _ := newHash(algorithm)
+	return &BlobReader{
+		BlobInfo: BlobInfo{Digest: digest, Size: res.ContentLength, Media: res.Header.Get("Content-Type")},
+		body:     res.Body,
+		hash:     h,
+		expected: digest,
+	}, nil
+}
+
+// OpenBlobRange open part of blob from offset, length < 0 means up to the end.
+// Digest of part can't be checked
+
+func (cl *RegClient) OpenBlobRange(digest string, offset, length int64) (*BlobReader, error) {
+	if offset < 0 || length == 0 {
+		return nil, errors.New(fmt.Sprintf("invalid range %d+%d of blob %s", offset, length, digest))
+	}
+	rangeHeader := "bytes=" + strconv.FormatInt(offset, 10) + "-"
+	if length > 0 {
+		rangeHeader += strconv.FormatInt(offset+length-1, 10)
+	}
+
+	res, err := cl.blobRequest(http.MethodGet, digest, rangeHeader)
+	if err != nil {
+		return nil, err
+	}
+	br := &BlobReader{
+		BlobInfo: BlobInfo{Digest: digest, Size: res.ContentLength, Media: res.Header.Get("Content-Type")},
+		body:     res.Body,
+	}
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		return br, nil
+	case http.StatusOK:
+		// range is ignored by server, skip head of blob
+		if _, err := io.CopyN(io.Discard, res.Body, offset); err != nil {
+			res.Body.Close()
+			return nil, errors.New(fmt.Sprintf("failed to skip %d bytes of blob %s, %+v", offset, digest, err.Error()))
+		}
+		br.Size = -1
+		if length > 0 {
+			br.Size = length
+			br.body = struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(res.Body, length), res.Body}
+		}
+		return br, nil
+	}
+	return nil, blobError(res, digest)
+}
+
+// Read read blob body, digest mismatch is reported instead of io.EOF
+
+func (br *BlobReader) Read(p []byte) (int, error) {
+	n, err := br.body.Read(p)
+	br.read += int64(n)
+	if br.hash != nil {
+		br.hash.Write(p[:n])
+	}
+	if err == io.EOF && br.hash != nil {
+		if br.Size >= 0 && br.read != br.Size {
+			return n, fmt.Errorf("%w: blob %s is truncated, %d of %d bytes", ErrDigestMismatch, br.expected, br.read, br.Size)
+		}
+		algorithm, _, _ := strings.Cut(br.expected, StringColon)
+		if actual := algorithm + StringColon + hex.EncodeToString(br.hash.Sum(nil)); actual != br.expected {
+			return n, fmt.Errorf("%w: blob expected %s, computed %s", ErrDigestMismatch, br.expected, actual)
+		}
+	}
+	return n, err
+}
+
+// Close close blob body
+
+func (br *BlobReader) Close() error {
+	return br.body.Close()
+}
+
+// GetBlob fetch small blob, e.g. bundle.json, by digest. Body is limited as manifest body
+// and must match digest
+
+func (cl *RegClient) GetBlob(digest string) ([]byte, error) {
+	br, err := cl.OpenBlob(digest)
+	if err != nil {
+		return nil, err
+	}
+	defer br.Close()
+
+	limit := cl.MaxManifestSize
+	if limit <= 0 {
+		limit = MaxBodySize
+	}
+	if br.Size > limit {
+		return nil, fmt.Errorf("%w: blob %s Content-Length %d exceeds limit %d bytes", ErrManifestTooLarge, digest, br.Size, limit)
+	}
+
+	// read one byte more to detect overflow
+	content, err := io.ReadAll(io.LimitReader(br, limit+1))
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("%w: blob %s exceeds limit %d bytes", ErrManifestTooLarge, digest, limit)
+	}
+	if err != nil {
+		if errors.Is(err, ErrDigestMismatch) {
+			return nil, err
+		}
+		return nil, errors.New(fmt.Sprintf("failed to fetch blob %s body, %s", digest, err.Error()))
+	}
+	return content, nil
+}
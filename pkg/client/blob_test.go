@@ -0,0 +1,203 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBlobClient создаёт клиента к тестовому серверу, отдающему blob по digest
+func newBlobClient(t *testing.T, blobs map[string]string) *RegClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, digest, _ := strings.Cut(r.URL.Path, "/blobs/")
+		content, ok := blobs[digest]
+		if !ok {
+			w.WriteHeader(404)
+			w.Write([]byte(`{"errors":[{"code":"BLOB_UNKNOWN"}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+
+	cl := NewRegClient(&Config{Scheme: "http"}, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+	return cl
+}
+
+// TestGetBlob проверяет загрузку blob с проверкой digest
+func TestGetBlob(t *testing.T) {
+
+	bundle := `{"schemaVersion":"v1.0.0","name":"app","version":"1.0.0"}`
+	digest, _ := ComputeDigest(DigestSha256, []byte(bundle))
+	forged, _ := ComputeDigest(DigestSha256, []byte("other"))
+	missing, _ := ComputeDigest(DigestSha256, []byte("missing"))
+	cl := newBlobClient(t, map[string]string{digest: bundle, forged: bundle})
+
+	content, err := cl.GetBlob(digest)
+	if err != nil {
+		t.Fatalf("GetBlob should not return error, got: %v", err)
+	}
+	if string(content) != bundle {
+		t.Errorf("GetBlob content = %q, want %q", content, bundle)
+	}
+
+	if _, err := cl.GetBlob(forged); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("GetBlob error = %v, want ErrDigestMismatch", err)
+	}
+	if _, err := cl.GetBlob(missing); !errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("GetBlob error = %v, want not found", err)
+	}
+	if _, err := cl.GetBlob("sha256:abc"); err == nil {
+		t.Error("GetBlob should reject invalid digest")
+	}
+
+	cl.MaxManifestSize = 10
+	if _, err := cl.GetBlob(digest); !errors.Is(err, ErrManifestTooLarge) {
+		t.Errorf("GetBlob error = %v, want ErrManifestTooLarge", err)
+	}
+}
+
+// TestGetBlob_RedirectWithoutCredentials проверяет переход на хранилище без заголовка Authorization
+func TestGetBlob_RedirectWithoutCredentials(t *testing.T) {
+
+	bundle := `{"name":"app"}`
+	digest, _ := ComputeDigest(DigestSha256, []byte(bundle))
+
+	var storageAuth []string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageAuth = append(storageAuth, r.Header.Get("Authorization"))
+		if r.URL.Query().Get("X-Amz-Signature") != "sig" {
+			w.WriteHeader(403)
+			return
+		}
+		w.Write([]byte(bundle))
+	}))
+	defer storage.Close()
+
+	var registryAuth string
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryAuth = r.Header.Get("Authorization")
+		http.Redirect(w, r, storage.URL+"/bucket/"+digest+"?X-Amz-Signature=sig", http.StatusTemporaryRedirect)
+	}))
+	defer registry.Close()
+
+	cl := NewRegClient(&Config{Scheme: "http", Credentials: struct {
+		Username string `mapstructure:"username"`
+		Password string `mapstructure:"password"`
+	}{Username: "user", Password: "secret"}}, "test")
+	cl.Registry = strings.TrimPrefix(registry.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	content, err := cl.GetBlob(digest)
+	if err != nil {
+		t.Fatalf("GetBlob should not return error, got: %v", err)
+	}
+	if string(content) != bundle {
+		t.Errorf("GetBlob content = %q, want %q", content, bundle)
+	}
+	if len(registryAuth) == 0 {
+		t.Error("registry should receive credentials")
+	}
+	if len(storageAuth) != 1 || len(storageAuth[0]) != 0 {
+		t.Errorf("storage received Authorization %q, want none", storageAuth)
+	}
+}
+
+// TestHeadBlob проверяет получение размера и типа blob по заголовкам
+func TestHeadBlob(t *testing.T) {
+
+	bundle := `{"name":"app"}`
+	digest, _ := ComputeDigest(DigestSha256, []byte(bundle))
+	cl := newBlobClient(t, map[string]string{digest: bundle})
+
+	info, err := cl.HeadBlob(digest)
+	if err != nil {
+		t.Fatalf("HeadBlob should not return error, got: %v", err)
+	}
+	if info.Size != int64(len(bundle)) || info.Media != "application/octet-stream" {
+		t.Errorf("HeadBlob = %+v, want size %d", info, len(bundle))
+	}
+	missing, _ := ComputeDigest(DigestSha256, []byte("missing"))
+	if _, err := cl.HeadBlob(missing); !errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("HeadBlob error = %v, want not found", err)
+	}
+}
+
+// TestOpenBlob_Streaming проверяет проверку digest при потоковом чтении
+func TestOpenBlob_Streaming(t *testing.T) {
+
+	layer := strings.Repeat("layer data ", 10000)
+	digest, _ := ComputeDigest(DigestSha256, []byte(layer))
+	forged, _ := ComputeDigest(DigestSha256, []byte("forged"))
+	cl := newBlobClient(t, map[string]string{digest: layer, forged: layer})
+
+	br, err := cl.OpenBlob(digest)
+	if err != nil {
+		t.Fatalf("OpenBlob should not return error, got: %v", err)
+	}
+	n, err := io.Copy(io.Discard, br)
+	br.Close()
+	if err != nil || n != int64(len(layer)) {
+		t.Errorf("stream read %d bytes, err %v; want %d", n, err, len(layer))
+	}
+
+	br, err = cl.OpenBlob(forged)
+	if err != nil {
+		t.Fatalf("OpenBlob should not return error before reading, got: %v", err)
+	}
+	_, err = io.Copy(io.Discard, br)
+	br.Close()
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("stream error = %v, want ErrDigestMismatch", err)
+	}
+}
+
+// TestOpenBlobRange проверяет Range-запросы и сервер, игнорирующий Range
+func TestOpenBlobRange(t *testing.T) {
+
+	layer := "0123456789abcdef"
+	digest, _ := ComputeDigest(DigestSha256, []byte(layer))
+	for _, honorRange := range []bool{true, false} {
+		var gotRange string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotRange = r.Header.Get("Range")
+			if honorRange {
+				// http.ServeContent отвечает 206 на Range
+				http.ServeContent(w, r, "", time.Time{}, strings.NewReader(layer))
+				return
+			}
+			w.Write([]byte(layer))
+		}))
+
+		cl := NewRegClient(&Config{Scheme: "http"}, "test")
+		cl.Registry = strings.TrimPrefix(server.URL, "http://")
+		cl.Repository = "repo/cnab"
+
+		br, err := cl.OpenBlobRange(digest, 4, 6)
+		if err != nil {
+			t.Fatalf("OpenBlobRange should not return error, got: %v", err)
+		}
+		part, err := io.ReadAll(br)
+		br.Close()
+		if err != nil || string(part) != "456789" {
+			t.Errorf("honorRange %v: part = %q, %v; want 456789", honorRange, part, err)
+		}
+		if gotRange != "bytes=4-9" {
+			t.Errorf("Range header = %q, want bytes=4-9", gotRange)
+		}
+		server.Close()
+	}
+
+	cl := NewRegClient(&Config{Scheme: "http"}, "test")
+	if _, err := cl.OpenBlobRange(digest, -1, 2); err == nil {
+		t.Error("OpenBlobRange should reject negative offset")
+	}
+}
@@ -13,7 +13,34 @@ import (
 	"time"
 )
 
-const MaxBodySize = 32384 // max body response for index request
+// default max body of manifest response, distribution spec suggests to accept at least 4 MiB
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pushing-manifests
+
+const MaxBodySize = 4 << 20
+
+var ErrManifestTooLarge = errors.New("manifest too large")
+
+// registry answers are classified by these errors, check them with errors.Is
+
+var (
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrManifestNotFound = errors.New("manifest not found")
+	ErrUnavailable      = errors.New("registry unavailable")
+)
+
+// StatusError make error of registry answer, classified by status code
+
+func StatusError(code int, message string) error {
+	switch {
+	case code == 401 || code == 403:
+		return fmt.Errorf("%w: %s", ErrUnauthorized, message)
+	case code == 404:
+		return fmt.Errorf("%w: %s", ErrManifestNotFound, message)
+	case code == 429 || code >= 500:
+		return fmt.Errorf("%w: %s", ErrUnavailable, message)
+	}
+	return errors.New(message)
+}
 
 const (
 	StringSlash = "/"
@@ -33,10 +60,22 @@ type RegClient struct {
 	Tag        string
 	Digest     string
 
-	Credentials data.Credentials
-	Client      string
+	Credentials     data.Credentials
+	Client          string
+	RepoKey         string        // Artifactory repository key
+	TLS             TLSOptions    // tls settings
+	PageSize        int           // tags list page size, 0 - registry default
+	MaxManifestSize int64         // manifest body limit, bytes
+	Retries         int           // retries on transient errors
+	RetryWait       time.Duration // first backoff interval
 
 	WebClient http.Client // web client
+
+	tokens *tokenCache // bearer tokens by scope
+
+	transportErr error // tls settings failure, every request fails with it
+
+	Logger *logging.Logger // logger of config, nil is quiet
 }
 
 const (
@@ -156,6 +195,8 @@ type RegResponse struct {
 	Date      string
 	Digest    string
 	Content   string // response json
+
+	logger *logging.Logger // logger of client, which made request
 }
 
 func NewRegClient(cc *Config, reference string) *RegClient {
@@ -167,14 +208,52 @@ func NewRegClient(cc *Config, reference string) *RegClient {
 			Username: cc.Credentials.Username,
 			Password: cc.Credentials.Password,
 		},
-		Client: cc.Client,
+		Client:  cc.Client,
+		RepoKey: cc.RepoKey,
+		TLS: TLSOptions{
+			Unsecure: cc.Unsecure,
+			CACert:   cc.CACert,
+			Cert:     cc.Cert,
+			Key:      cc.Key,
+			CertsDir: cc.CertsDir,
+		},
+		PageSize:        cc.PageSize,
+		MaxManifestSize: cc.MaxManifestSize,
+		Retries:         cc.Retries,
+		RetryWait:       time.Millisecond * time.Duration(cc.RetryWait),
 		WebClient: http.Client{
 			Timeout: time.Millisecond * time.Duration(cc.Timeout),
 		},
+		tokens: &tokenCache{},
+		Logger: cc.Logger,
+	}
+
+	// registry profile is chosen by registry part of reference
+	if err := cl.ParseReference(); err == nil || cl.ParseRepository() == nil {
+		cl.applyProfile(cc)
+	}
+
+	// tls settings are known only after profile, request without them is not sent
+	transport, err := NewTransport(cl.TLS, cl.Registry, cl.Logger)
+	if err != nil {
+		cl.transportErr = fmt.Errorf("%w: %w", ErrTLSConfig, err)
+		cl.Logger.Error(fmt.Sprintf("can not make tls transport, %+v", err.Error()))
+	} else {
+		cl.WebClient.Transport = transport
 	}
 	return cl
 }
 
+// Clone make client copy for concurrent use, transport and tokens cache are shared
+
+func (cl *RegClient) Clone() *RegClient {
+	if cl.tokens == nil {
+		cl.tokens = &tokenCache{}
+	}
+	clone := *cl
+	return &clone
+}
+
 // split reference to registry, repository, tag and direst
 
 func (cl *RegClient) ParseReference() error {
@@ -214,31 +293,43 @@ func (cl *RegClient) ParseReference() error {
 	// check validity - registry and repository mustn't void
 	// if tag and digest is empty , reference point to tags list
 	if len(cl.Registry) == 0 || len(cl.Repository) == 0 || (len(cl.Tag) == 0 && len(cl.Digest) == 0) {
-		//logging.Debug(fmt.Sprintf("ref %+v", ref))
+		//cl.Logger.Debug(fmt.Sprintf("ref %+v", ref))
 		return errors.New(fmt.Sprintf("reference has non valid format - %s", cl.Reference))
 	}
 
 	return nil
 }
 
+// ParseRepository split reference of repository without tag and digest,
+// e.g. registry.example.com/project/cnab
+
+func (cl *RegClient) ParseRepository() error {
+	registry, repository, _ := strings.Cut(cl.Reference, StringSlash)
+	if !strings.Contains(registry, StringDot) || len(repository) == 0 {
+		return errors.New(fmt.Sprintf("reference does not contain registry and repository parts in %s", cl.Reference))
+	}
+	if strings.Contains(repository, StringColon) || strings.Contains(repository, StringAt) {
+		return errors.New(fmt.Sprintf("repository reference must not contain tag or digest - %s", cl.Reference))
+	}
+	cl.Registry, cl.Repository, cl.Tag, cl.Digest = registry, repository, "", ""
+	return nil
+}
+
 // WebRequest - provide get request to registry
 
 func (cl *RegClient) WebRequest(url, media string) (*http.Response, error) {
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		logging.Error(fmt.Sprintf("%+v", err.Error()))
+		cl.Logger.Error(fmt.Sprintf("%+v", err.Error()))
 		return nil, err
 	}
 	req.Header.Set("User-Agent", cl.Client)
 	req.Header.Set("Accept-Encoding", "gzip, deflate")
 	req.Header.Set("Accept", media)
-	req.SetBasicAuth(cl.Credentials.Username, cl.Credentials.Password)
-
-	logging.Debug(fmt.Sprintf("request %+v", req))
 
-	res, err := cl.WebClient.Do(req)
+	res, err := cl.doRequest(req, cl.repositoryScope(ScopeActionPull))
 	if err != nil {
-		logging.Error(fmt.Sprintf("%+v", err.Error()))
+		cl.Logger.Error(fmt.Sprintf("%+v", err.Error()))
 		return nil, err
 	}
 	return res, nil
@@ -249,17 +340,14 @@ func (cl *RegClient) WebRequest(url, media string) (*http.Response, error) {
 func (cl *RegClient) WebDelete(url string) (*http.Response, error) {
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
-		logging.Error(fmt.Sprintf("%+v", err.Error()))
+		cl.Logger.Error(fmt.Sprintf("%+v", err.Error()))
 		return nil, err
 	}
 	req.Header.Set("User-Agent", cl.Client)
-	req.SetBasicAuth(cl.Credentials.Username, cl.Credentials.Password)
 
-	logging.Debug(fmt.Sprintf("request %+v", req))
-
-	res, err := cl.WebClient.Do(req)
+	res, err := cl.doRequest(req, cl.repositoryScope(ScopeActionDelete))
 	if err != nil {
-		logging.Error(fmt.Sprintf("%+v", err.Error()))
+		cl.Logger.Error(fmt.Sprintf("%+v", err.Error()))
 		return nil, err
 	}
 	return res, nil
@@ -269,25 +357,52 @@ func (cl *RegClient) WebDelete(url string) (*http.Response, error) {
 func (cl *RegClient) WebRequestEx(method, url string) (*http.Response, error) {
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
-		logging.Error(fmt.Sprintf("%+v", err.Error()))
+		cl.Logger.Error(fmt.Sprintf("%+v", err.Error()))
 		return nil, err
 	}
 	req.Header.Set("User-Agent", cl.Client)
-	req.SetBasicAuth(cl.Credentials.Username, cl.Credentials.Password)
-
-	logging.Debug(fmt.Sprintf("request %+v", req))
 
-	res, err := cl.WebClient.Do(req)
+	// scope isn't known for arbitrary url, use scope from challenge
+	res, err := cl.doRequest(req, "")
 	if err != nil {
-		logging.Error(fmt.Sprintf("%+v", err.Error()))
+		cl.Logger.Error(fmt.Sprintf("%+v", err.Error()))
 		return nil, err
 	}
 	return res, nil
 }
 
-// FillResponse - do decode response
+// verifyDigest compare Docker-Content-Digest with content, computed sha256 is used if header is missing
+
+func (regres *RegResponse) verifyDigest(content []byte) error {
+	if regres.Media == MediaTypeV1Pretty {
+		// signed schema 1 digest is calculated without signatures
+		regres.logger.Debug(fmt.Sprintf("skip digest check for %s", regres.Media))
+		return nil
+	}
+	if len(regres.Digest) == 0 {
+		digest, err := ComputeDigest(DigestSha256, content)
+		if err != nil {
+			return err
+		}
+		regres.logger.Debug(fmt.Sprintf("response has no Docker-Content-Digest, use computed %s", digest))
+		regres.Digest = digest
+		return nil
+	}
+	if err := VerifyDigest(regres.Digest, content); err != nil {
+		return fmt.Errorf("Docker-Content-Digest %w", err)
+	}
+	return nil
+}
+
+// FillResponse - do decode response with default size limit
 
 func (regres *RegResponse) FillResponse(res *http.Response) error {
+	return regres.FillResponseLimit(res, MaxBodySize)
+}
+
+// FillResponseLimit - do decode response, body must not exceed limit bytes
+
+func (regres *RegResponse) FillResponseLimit(res *http.Response, limit int64) error {
 
 	// fetch response header fields
 	regres.Media = res.Header.Get("Content-Type")
@@ -305,90 +420,132 @@ func (regres *RegResponse) FillResponse(res *http.Response) error {
 	// response is not valid
 	if res.Body == nil {
 		errLine := fmt.Sprintf("body is nil, status %d, headers %+v", res.StatusCode, res.Header)
-		logging.Error(errLine)
+		regres.logger.Error(errLine)
 		return errors.New(errLine)
 	}
 
-	// get body
+	// refuse at once if registry reports the size
+	if limit <= 0 {
+		limit = MaxBodySize
+	}
+	if res.ContentLength > limit {
+		res.Body.Close()
+		err := fmt.Errorf("%w: Content-Length %d exceeds limit %d bytes", ErrManifestTooLarge, res.ContentLength, limit)
+		regres.logger.Error(err.Error())
+		return err
+	}
+
+	// get body, one extra byte shows the limit is exceeded
 	reader := res.Body
-	bytesbody, readErr := io.ReadAll(io.LimitReader(reader, MaxBodySize))
+	bytesbody, readErr := io.ReadAll(io.LimitReader(reader, limit+1))
 	if readErr != nil {
 		errLine := fmt.Sprintf("failed to fetch response body %s", readErr)
-		logging.Error(errLine)
+		regres.logger.Error(errLine)
 		return errors.New(errLine)
 	}
 	res.Body.Close()
+	if int64(len(bytesbody)) > limit {
+		length := res.Header.Get("Content-Length")
+		if len(length) == 0 {
+			length = "unknown"
+		}
+		err := fmt.Errorf("%w: body exceeds limit %d bytes, Content-Length %s", ErrManifestTooLarge, limit, length)
+		regres.logger.Error(err.Error())
+		return err
+	}
 
 	// body must be json
 	if !json.Valid(bytesbody) {
 		errLine := fmt.Sprintf("response body is not valid json, status %d, headers %+v", res.StatusCode, res.Header)
-		logging.Error(errLine)
-		logging.Debug(fmt.Sprintf("response body  %+v", string(bytesbody)))
+		regres.logger.Error(errLine)
+		regres.logger.Debug(fmt.Sprintf("response body  %+v", string(bytesbody)))
 		return errors.New(errLine)
 	}
 	regres.Content = string(bytesbody)
 
+	// check manifest digest, proxies may drop or rewrite the header
+	if res.StatusCode == 200 {
+		if err := regres.verifyDigest(bytesbody); err != nil {
+			regres.logger.Error(err.Error())
+			return err
+		}
+	}
+
 	jsonres, err := logging.PrettyString(string(bytesbody))
 	if err != nil {
 		errLine := fmt.Sprintf("response body is unvalid json, status %d, headers %+v", res.StatusCode, res.Header)
-		logging.Error(errLine)
-		logging.Debug(fmt.Sprintf("response body  %+v", string(bytesbody)))
+		regres.logger.Error(errLine)
+		regres.logger.Debug(fmt.Sprintf("response body  %+v", string(bytesbody)))
 		return errors.New(errLine)
 	}
 
-	//logging.Debug(fmt.Sprintf("registry response %+v", regres))
-	logging.Debug(fmt.Sprintf("pretty json %+v", jsonres))
+	//regres.logger.Debug(fmt.Sprintf("registry response %+v", regres))
+	regres.logger.Debug(fmt.Sprintf("pretty json %+v", jsonres))
 	return nil
 }
 
-func (cl *RegClient) GetTagList() (*RegResponse, error) {
+// manifestURL make manifest address by tag, or by digest if tag is empty
 
-	//logging.Info(fmt.Sprintf("%+v", cl))
+func (cl *RegClient) manifestURL() string {
+	url := cl.Scheme + "://" + cl.Registry + "/v2/" + cl.Repository + "/manifests/"
+	if len(cl.Tag) != 0 {
+		return url + cl.Tag
+	}
+	return url + cl.Digest
+}
 
-	// tune url
-	url := cl.Scheme + "://" + cl.Registry + "/v2/" + cl.Repository + "/tags/list/"
+// HeadManifest get digest, media type and size of manifest from headers, content is empty.
+// Digest is empty if registry doesn't send Docker-Content-Digest
 
+func (cl *RegClient) HeadManifest() (*RegResponse, error) {
 	regres := &RegResponse{
-		Reference: strings.Replace(url, cl.Scheme+"://", "", 1),
-		Media:     MediaTypeJson,
+		logger:    cl.Logger,
+		Reference: cl.Reference,
 	}
 
-	res, err := cl.WebRequest(url, MediaTypeJson)
-
-	if err != nil { // unrecoverable error
-		logging.Error(fmt.Sprintf("response is nil, %+v", err.Error()))
+	req, err := http.NewRequest(http.MethodHead, cl.manifestURL(), nil)
+	if err != nil {
 		return regres, err
 	}
-	logging.Debug(fmt.Sprintf("response %+v", res))
+	req.Header.Set("User-Agent", cl.Client)
+	// same negotiation as GET, otherwise digest of other format is returned
+	req.Header.Set("Accept", cl.acceptHeader())
 
-	if err := regres.FillResponse(res); err != nil {
-		err_line := fmt.Sprintf("failed to decode response %s", err.Error())
-		logging.Error(err_line)
-		return regres, errors.New(err_line)
+	res, err := cl.doRequest(req, cl.repositoryScope(ScopeActionPull))
+	if err != nil {
+		cl.Logger.Error(fmt.Sprintf("response is nil, %+v", err.Error()))
+		return regres, err
 	}
-	//logging.Info(fmt.Sprintf("regres %+v", regres))
+	res.Body.Close()
+	cl.Logger.Debug(fmt.Sprintf("status %d, response headers %+v", res.StatusCode, res.Header))
 
+	regres.Status = res.StatusCode
+	regres.Media = res.Header.Get("Content-Type")
+	regres.Date = res.Header.Get("Last-Modified")
+	regres.Length = int(res.ContentLength)
+	regres.Digest = res.Header.Get("Docker-Content-Digest")
 	if res.StatusCode != 200 {
-		err_line := fmt.Sprintf("failed to fetch data %+v", regres)
-		logging.Error(err_line)
-		return regres, errors.New(err_line)
+		return regres, StatusError(res.StatusCode, fmt.Sprintf("failed to check manifest %s", res.Status))
+	}
+	if len(regres.Digest) != 0 {
+		if _, _, err := ParseDigest(regres.Digest); err != nil {
+			return regres, fmt.Errorf("Docker-Content-Digest %w: %s", ErrDigestMismatch, err.Error())
+		}
+		// requested digest must be answered
+		if len(cl.Tag) == 0 && len(cl.Digest) != 0 && regres.Digest != cl.Digest {
+			return regres, fmt.Errorf("%w: requested %s, registry reports %s", ErrDigestMismatch, cl.Digest, regres.Digest)
+		}
 	}
-	//logging.Info(fmt.Sprintf("regres content %+v", regres.Content))
-
 	return regres, nil
 }
 
 func (cl *RegClient) GetRegIndex() (*RegResponse, error) {
 
 	// tune url
-	url := cl.Scheme + "://" + cl.Registry + "/v2/" + cl.Repository + "/manifests/"
-	if len(cl.Tag) != 0 {
-		url = url + cl.Tag
-	} else {
-		url = url + cl.Digest
-	}
+	url := cl.manifestURL()
 
 	regres := &RegResponse{
+		logger:    cl.Logger,
 		Reference: cl.Reference,
 	}
 
@@ -396,37 +553,50 @@ func (cl *RegClient) GetRegIndex() (*RegResponse, error) {
 	// The registry picks the best format it supports and returns the matching Content-Type.
 	res, err := cl.WebRequest(url, cl.acceptHeader())
 	if err != nil {
-		logging.Error(fmt.Sprintf("response is nil, %+v", err.Error()))
+		cl.Logger.Error(fmt.Sprintf("response is nil, %+v", err.Error()))
 		return regres, err
 	}
 
-	logging.Debug(fmt.Sprintf("status %d, response headers %+v", res.StatusCode, res.Header))
+	cl.Logger.Debug(fmt.Sprintf("status %d, response headers %+v", res.StatusCode, res.Header))
 
-	if err := regres.FillResponse(res); err != nil {
-		err_line := fmt.Sprintf("failed to decode response %s", err.Error())
-		logging.Error(err_line)
-		return regres, errors.New(err_line)
+	if err := regres.FillResponseLimit(res, cl.MaxManifestSize); err != nil {
+		if res.StatusCode != 200 {
+			// error answer may have empty or not json body, status tells more
+			err = StatusError(res.StatusCode, fmt.Sprintf("failed to fetch data %s, %s", res.Status, err.Error()))
+		} else {
+			err = fmt.Errorf("failed to decode response %w", err)
+		}
+		cl.Logger.Error(err.Error())
+		return regres, err
 	}
 
 	switch res.StatusCode {
 	case 200:
 		// Success — regres.Media now holds the actual Content-Type chosen by the registry.
+		// Requested digest must match content too.
+		if len(cl.Digest) != 0 && regres.Media != MediaTypeV1Pretty {
+			if err := VerifyDigest(cl.Digest, []byte(regres.Content)); err != nil {
+				err = fmt.Errorf("reference %w", err)
+				cl.Logger.Error(err.Error())
+				return regres, err
+			}
+		}
 		return regres, nil
-	case 401:
-		err_line := fmt.Sprintf("unauthorized: %s", strings.Join(strings.Fields(regres.Content), " "))
-		logging.Error(err_line)
-		return regres, errors.New(err_line)
+	case 401, 403:
+		err := fmt.Errorf("%w: %s", ErrUnauthorized, strings.Join(strings.Fields(regres.Content), " "))
+		cl.Logger.Error(err.Error())
+		return regres, err
 	case 404:
-		err_line := fmt.Sprintf("manifest not found: %s", strings.Join(strings.Fields(regres.Content), " "))
-		logging.Error(err_line)
-		return regres, errors.New(err_line)
+		err := fmt.Errorf("%w: %s", ErrManifestNotFound, strings.Join(strings.Fields(regres.Content), " "))
+		cl.Logger.Error(err.Error())
+		return regres, err
 	case 400:
 		err_line := fmt.Sprintf("bad request: %s", strings.Join(strings.Fields(regres.Content), " "))
-		logging.Error(err_line)
+		cl.Logger.Error(err_line)
 		return regres, errors.New(err_line)
 	default:
-		err_line := fmt.Sprintf("failed to fetch data %s: %s", res.Status, strings.Join(strings.Fields(regres.Content), " "))
-		logging.Error(err_line)
-		return regres, errors.New(err_line)
+		err := StatusError(res.StatusCode, fmt.Sprintf("failed to fetch data %s: %s", res.Status, strings.Join(strings.Fields(regres.Content), " ")))
+		cl.Logger.Error(err.Error())
+		return regres, err
 	}
 }
@@ -1,11 +1,12 @@
 package client
 
 import (
-	"cnabtool/pkg/data"
 	"encoding/base64"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -123,6 +124,24 @@ func TestParseReference_ErrorCases(t *testing.T) {
 	}
 }
 
+// TestParseRepository проверяет разбор ссылки на репозиторий без тега и digest
+func TestParseRepository(t *testing.T) {
+	cl := &RegClient{Reference: "registry.example.com:5000/project/cnab", Tag: "old"}
+	if err := cl.ParseRepository(); err != nil {
+		t.Fatalf("ParseRepository should not return error, got: %v", err)
+	}
+	if cl.Registry != "registry.example.com:5000" || cl.Repository != "project/cnab" || cl.Tag != "" || cl.Digest != "" {
+		t.Errorf("ParseRepository = %+v", cl)
+	}
+
+	for _, ref := range []string{"", "registry.example.com", "registry.example.com/", "localhost/project/cnab",
+		"registry.example.com/project/cnab:v1", "registry.example.com/project/cnab@sha256:abc"} {
+		if err := (&RegClient{Reference: ref}).ParseRepository(); err == nil {
+			t.Errorf("ParseRepository(%q) should return error, got nil", ref)
+		}
+	}
+}
+
 // TestParseReference_PortHandling проверяет работу с портами
 // Примечание: текущая реализация ParseReference не поддерживает порты без доменной точки
 // (например, localhost:5000 не проходит проверку на наличие точки в hostname)
@@ -204,12 +223,6 @@ func TestNewRegClient(t *testing.T) {
 
 // TestFillResponse проверяет декодирование HTTP-ответа
 func TestFillResponse(t *testing.T) {
-	// Инициализируем Gc, чтобы logging.Debug не паниковал
-	data.Gc = &data.Config{
-		Verbosity: 4,
-	}
-	defer func() { data.Gc = nil }()
-
 	testcases := []struct {
 		name       string
 		statusCode int
@@ -224,7 +237,7 @@ func TestFillResponse(t *testing.T) {
 			statusCode: 200,
 			content:    `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.index.v1+json"}`,
 			media:      "application/vnd.oci.image.index.v1+json",
-			digest:     "sha256:abc123",
+			digest:     "sha256:68716b19cac79448257caf16840cca10c8dadedc7d5024fe430ab6b7826361c6",
 			date:       "Mon, 01 Jan 2024 00:00:00 GMT",
 			wantErr:    false,
 		},
@@ -280,11 +293,6 @@ func TestFillResponse(t *testing.T) {
 
 // TestFillResponse_EmptyBody проверяет обработку пустого тела
 func TestFillResponse_EmptyBody(t *testing.T) {
-	// Инициализируем Gc, чтобы logging не паниковал
-	data.Gc = &data.Config{
-		Verbosity: 4,
-	}
-	defer func() { data.Gc = nil }()
 	resp := &http.Response{
 		StatusCode: 200,
 		Header:     http.Header{},
@@ -301,10 +309,6 @@ func TestFillResponse_EmptyBody(t *testing.T) {
 
 // TestWebRequestEx проверяет заголовок авторизации
 func TestWebRequestEx(t *testing.T) {
-	data.Gc = &data.Config{
-		Verbosity: 4,
-	}
-	defer func() { data.Gc = nil }()
 	var receivedAuth string
 	var receivedUserAgent string
 
@@ -361,10 +365,6 @@ func TestWebRequestEx(t *testing.T) {
 
 // TestGetTagList проверяет получение списка тегов
 func TestGetTagList(t *testing.T) {
-	data.Gc = &data.Config{
-		Verbosity: 4,
-	}
-	defer func() { data.Gc = nil }()
 	expectedTags := `{"name":"test/repo","tags":["v1.0","v2.0","latest"]}`
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -406,10 +406,6 @@ func TestGetTagList(t *testing.T) {
 
 // TestGetTagList_Error проверяет обработку ошибки
 func TestGetTagList_Error(t *testing.T) {
-	data.Gc = &data.Config{
-		Verbosity: 4,
-	}
-	defer func() { data.Gc = nil }()
 	// Сервер, который возвращает 404
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(404)
@@ -433,8 +429,96 @@ func TestGetTagList_Error(t *testing.T) {
 	cl.Repository = "test/repo"
 
 	_, err := cl.GetTagList()
-	if err == nil {
-		t.Error("GetTagList should return error for 404 response")
+	if !errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("GetTagList error = %v, want ErrManifestNotFound for 404 response", err)
+	}
+}
+
+// TestGetRegIndex_StatusErrors проверяет классификацию ответов реестра типизированными ошибками
+func TestGetRegIndex_StatusErrors(t *testing.T) {
+
+	testcases := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{status: 401, body: `{"errors":[{"code":"DENIED"}]}`, want: ErrUnauthorized},
+		{status: 403, body: `{"errors":[{"code":"DENIED"}]}`, want: ErrUnauthorized},
+		{status: 404, body: `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`, want: ErrManifestNotFound},
+		{status: 404, body: "", want: ErrManifestNotFound},
+		{status: 503, body: "<html>Service Unavailable</html>", want: ErrUnavailable},
+	}
+	for _, tc := range testcases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		}))
+
+		cl := NewRegClient(&Config{Scheme: "http"}, "test")
+		cl.Registry = strings.TrimPrefix(server.URL, "http://")
+		cl.Repository = "test/repo"
+		cl.Tag = "v1"
+
+		if _, err := cl.GetRegIndex(); !errors.Is(err, tc.want) {
+			t.Errorf("status %d: GetRegIndex error = %v, want %v", tc.status, err, tc.want)
+		}
+		server.Close()
+	}
+}
+
+// TestHeadManifest проверяет получение digest, типа и размера манифеста из заголовков
+func TestHeadManifest(t *testing.T) {
+
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+	digest, _ := ComputeDigest(DigestSha256, []byte(manifest))
+	methods := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		if reference != "v1" && reference != digest {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeOciIndex)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", strconv.Itoa(len(manifest)))
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	cl := NewRegClient(&Config{Scheme: "http"}, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "test/repo"
+	cl.Tag = "v1"
+
+	regres, err := cl.HeadManifest()
+	if err != nil {
+		t.Fatalf("HeadManifest should not return error, got: %v", err)
+	}
+	if regres.Digest != digest || regres.Media != MediaTypeOciIndex || regres.Length != len(manifest) {
+		t.Errorf("HeadManifest = %+v, want digest %s, media %s, length %d", regres, digest, MediaTypeOciIndex, len(manifest))
+	}
+	if len(regres.Content) != 0 {
+		t.Errorf("Content = %q, want empty", regres.Content)
+	}
+	if len(methods) != 1 || methods[0] != http.MethodHead {
+		t.Errorf("requests = %v, want single HEAD", methods)
+	}
+
+	cl.Tag = "v2"
+	if _, err := cl.HeadManifest(); !errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("HeadManifest error = %v, want ErrManifestNotFound", err)
+	}
+
+	// registry answers other digest than requested
+	cl.Tag, cl.Digest = "", "sha256:"+strings.Repeat("0", 64)
+	if _, err := cl.HeadManifest(); !errors.Is(err, ErrManifestNotFound) {
+		t.Errorf("HeadManifest error = %v, want ErrManifestNotFound for unknown digest", err)
+	}
+	cl.Digest = digest
+	if _, err := cl.HeadManifest(); err != nil {
+		t.Errorf("HeadManifest by digest should not return error, got: %v", err)
 	}
 }
 
@@ -463,3 +547,57 @@ func TestManifestDiscovery(t *testing.T) {
 		t.Errorf("manifest_discovery[0] = %q, want %q", manifest_discovery[0], MediaTypeOciIndex)
 	}
 }
+
+// TestFillResponseLimit проверяет понятную ошибку для слишком большого манифеста
+func TestFillResponseLimit(t *testing.T) {
+
+	content := `{"schemaVersion":2,"manifests":[` + strings.Repeat(`{"digest":"sha256:0"},`, 100) + `{}]}`
+
+	// размер известен из Content-Length
+	resp := &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{"Content-Length": {strconv.Itoa(len(content))}},
+		ContentLength: int64(len(content)),
+		Body:          io.NopCloser(strings.NewReader(content)),
+	}
+	regres := &RegResponse{}
+	err := regres.FillResponseLimit(resp, 1024)
+	if !errors.Is(err, ErrManifestTooLarge) {
+		t.Fatalf("FillResponseLimit error = %v, want ErrManifestTooLarge", err)
+	}
+	if !strings.Contains(err.Error(), "Content-Length "+strconv.Itoa(len(content))) {
+		t.Errorf("error %q should show Content-Length", err.Error())
+	}
+
+	// chunked ответ без Content-Length
+	resp = &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{},
+		ContentLength: -1,
+		Body:          io.NopCloser(strings.NewReader(content)),
+	}
+	regres = &RegResponse{}
+	err = regres.FillResponseLimit(resp, 1024)
+	if !errors.Is(err, ErrManifestTooLarge) {
+		t.Fatalf("FillResponseLimit error = %v, want ErrManifestTooLarge", err)
+	}
+	if !strings.Contains(err.Error(), "Content-Length unknown") {
+		t.Errorf("error %q should report unknown Content-Length", err.Error())
+	}
+
+	// манифест больше прежних 32 КБ укладывается в лимит по умолчанию
+	large := `{"schemaVersion":2,"manifests":[` + strings.Repeat(`{"digest":"sha256:0"},`, 3000) + `{}]}`
+	resp = &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{},
+		ContentLength: int64(len(large)),
+		Body:          io.NopCloser(strings.NewReader(large)),
+	}
+	regres = &RegResponse{}
+	if err := regres.FillResponse(resp); err != nil {
+		t.Fatalf("FillResponse for %d bytes should not return error, got: %v", len(large), err)
+	}
+	if len(regres.Content) != len(large) {
+		t.Errorf("Content length = %d, want %d", len(regres.Content), len(large))
+	}
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// content digests
+// https://github.com/opencontainers/image-spec/blob/main/descriptor.md#digests
+
+const (
+	DigestSha256 = "sha256"
+	DigestSha512 = "sha512"
+)
+
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// newHash make hash for digest algorithm
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case DigestSha256:
+		return sha256.New(), nil
+	case DigestSha512:
+		return sha512.New(), nil
+	}
+	return nil, errors.New(fmt.Sprintf("unsupported digest algorithm %s", algorithm))
+}
+
+// ParseDigest split digest to algorithm and hex, check hex length
+
+func ParseDigest(digest string) (string, string, error) {
+	algorithm, encoded, found := strings.Cut(digest, StringColon)
+	if !found || len(algorithm) == 0 || len(encoded) == 0 {
+		return "", "", errors.New(fmt.Sprintf("invalid digest %s", digest))
+	}
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", "", err
+	}
+	if len(encoded) != h.Size()*2 {
+		return "", "", errors.New(fmt.Sprintf("invalid %s digest length %s", algorithm, digest))
+	}
+	if _, err := hex.DecodeString(encoded); err != nil || strings.ToLower(encoded) != encoded {
+		return "", "", errors.New(fmt.Sprintf("invalid digest hex %s", digest))
+	}
+	return algorithm, encoded, nil
+}
+
+// ComputeDigest calculate digest of content
+
+func ComputeDigest(algorithm string, content []byte) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	h.Write(content)
+	return algorithm + StringColon + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyDigest compare expected digest with digest of content
+
+func VerifyDigest(expected string, content []byte) error {
+	algorithm, _, err := ParseDigest(expected)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrDigestMismatch, err.Error())
+	}
+	actual, err := ComputeDigest(algorithm, content)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("%w: expected %s, computed %s", ErrDigestMismatch, expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+
+// TestParseDigest проверяет разбор и валидацию digest
+func TestParseDigest(t *testing.T) {
+	sha256hex := strings.Repeat("a", 64)
+	sha512hex := strings.Repeat("b", 128)
+	testcases := []struct {
+		digest  string
+		algo    string
+		wantErr bool
+	}{
+		{digest: "sha256:" + sha256hex, algo: DigestSha256},
+		{digest: "sha512:" + sha512hex, algo: DigestSha512},
+		{digest: "sha256:abc123", wantErr: true},
+		{digest: "sha256:" + strings.Repeat("A", 64), wantErr: true},
+		{digest: "sha256:" + strings.Repeat("z", 64), wantErr: true},
+		{digest: "md5:" + strings.Repeat("a", 32), wantErr: true},
+		{digest: sha256hex, wantErr: true},
+		{digest: "", wantErr: true},
+	}
+	for _, tc := range testcases {
+		algo, _, err := ParseDigest(tc.digest)
+		if tc.wantErr != (err != nil) {
+			t.Errorf("ParseDigest(%q) error = %v, wantErr %v", tc.digest, err, tc.wantErr)
+		}
+		if algo != tc.algo {
+			t.Errorf("ParseDigest(%q) algorithm = %q, want %q", tc.digest, algo, tc.algo)
+		}
+	}
+}
+
+// TestComputeDigest проверяет вычисление sha256 и sha512
+func TestComputeDigest(t *testing.T) {
+	got, err := ComputeDigest(DigestSha256, []byte(""))
+	if err != nil {
+		t.Fatalf("ComputeDigest should not return error, got: %v", err)
+	}
+	if got != "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("ComputeDigest sha256 = %q", got)
+	}
+	got, _ = ComputeDigest(DigestSha512, []byte(""))
+	if !strings.HasPrefix(got, "sha512:cf83e1357eefb8bd") || len(got) != len("sha512:")+128 {
+		t.Errorf("ComputeDigest sha512 = %q", got)
+	}
+	if _, err := ComputeDigest("md5", nil); err == nil {
+		t.Error("ComputeDigest should reject unsupported algorithm")
+	}
+}
+
+// TestVerifyDigest проверяет сравнение digest с содержимым
+func TestVerifyDigest(t *testing.T) {
+	digest, _ := ComputeDigest(DigestSha256, []byte(testManifest))
+	if err := VerifyDigest(digest, []byte(testManifest)); err != nil {
+		t.Errorf("VerifyDigest should accept matching content, got: %v", err)
+	}
+	if err := VerifyDigest(digest, []byte(testManifest+" ")); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("VerifyDigest error = %v, want ErrDigestMismatch", err)
+	}
+	if err := VerifyDigest("sha256:abc", []byte(testManifest)); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("VerifyDigest for invalid digest = %v, want ErrDigestMismatch", err)
+	}
+}
+
+// TestFillResponse_DigestVerification проверяет сверку Docker-Content-Digest с телом ответа
+func TestFillResponse_DigestVerification(t *testing.T) {
+
+	digest, _ := ComputeDigest(DigestSha256, []byte(testManifest))
+	testcases := []struct {
+		name       string
+		header     string
+		media      string
+		wantDigest string
+		wantErr    bool
+	}{
+		{name: "matching header", header: digest, media: MediaTypeOciIndex, wantDigest: digest},
+		{name: "missing header", header: "", media: MediaTypeOciIndex, wantDigest: digest},
+		{name: "mismatched header", header: "sha256:" + strings.Repeat("0", 64), media: MediaTypeOciIndex, wantErr: true},
+		{name: "signed schema 1", header: "sha256:" + strings.Repeat("0", 64), media: MediaTypeV1Pretty, wantDigest: "sha256:" + strings.Repeat("0", 64)},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{"Content-Type": {tc.media}}
+			if len(tc.header) != 0 {
+				header.Set("Docker-Content-Digest", tc.header)
+			}
+			resp := &http.Response{
+				StatusCode: 200,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(testManifest)),
+			}
+			regres := &RegResponse{}
+			err := regres.FillResponse(resp)
+			if tc.wantErr {
+				if !errors.Is(err, ErrDigestMismatch) {
+					t.Errorf("FillResponse error = %v, want ErrDigestMismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FillResponse should not return error, got: %v", err)
+			}
+			if regres.Digest != tc.wantDigest {
+				t.Errorf("Digest = %q, want %q", regres.Digest, tc.wantDigest)
+			}
+		})
+	}
+}
+
+// TestGetRegIndex_ReferenceDigestMismatch проверяет отказ, если содержимое не соответствует запрошенному digest
+func TestGetRegIndex_ReferenceDigestMismatch(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// заголовок честно описывает подменённое содержимое
+		digest, _ := ComputeDigest(DigestSha256, []byte(testManifest))
+		w.Header().Set("Content-Type", MediaTypeOciIndex)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Write([]byte(testManifest))
+	}))
+	defer server.Close()
+
+	cl := NewRegClient(&Config{Scheme: "http"}, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+	cl.Digest = "sha256:" + strings.Repeat("1", 64)
+
+	_, err := cl.GetRegIndex()
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("GetRegIndex error = %v, want ErrDigestMismatch", err)
+	}
+
+	cl.Digest, _ = ComputeDigest(DigestSha256, []byte(testManifest))
+	if _, err := cl.GetRegIndex(); err != nil {
+		t.Errorf("GetRegIndex should accept matching digest, got: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"bytes"
+	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// docker cli config
+// https://docs.docker.com/engine/reference/commandline/cli/#docker-cli-configuration-file-configjson-properties
+
+const (
+	DockerConfigEnv      = "DOCKER_CONFIG"
+	DockerConfigDir      = ".docker"
+	DockerConfigFileName = "config.json"
+	DockerHubRegistry    = "docker.io"
+	DockerHubIndexServer = "https://index.docker.io/v1/"
+	DockerHelperPrefix   = "docker-credential-"
+)
+
+type DockerAuthEntry struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+type DockerConfig struct {
+	Auths       map[string]DockerAuthEntry `json:"auths"`
+	CredsStore  string                     `json:"credsStore"`
+	CredHelpers map[string]string          `json:"credHelpers"`
+
+	logger *logging.Logger // logger of client, which reads config
+}
+
+// credential helper response
+// https://github.com/docker/docker-credential-helpers
+
+type helperResponse struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// run credential helper binary, replaceable for tests
+
+var runCredentialHelper = func(helper, server string) ([]byte, error) {
+	cmd := exec.Command(DockerHelperPrefix+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s%s: %s %s", DockerHelperPrefix, helper, err.Error(), strings.TrimSpace(stderr.String())))
+	}
+	return out, nil
+}
+
+// DockerConfigPath returns location of docker config.json
+
+func DockerConfigPath() string {
+	if dir := os.Getenv(DockerConfigEnv); len(dir) != 0 {
+		return filepath.Join(dir, DockerConfigFileName)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, DockerConfigDir, DockerConfigFileName)
+}
+
+// LoadDockerConfig read docker config.json, missing file isn't an error
+
+func LoadDockerConfig(path string) (*DockerConfig, error) {
+	dc := &DockerConfig{}
+	if len(path) == 0 {
+		return dc, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return dc, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, dc); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid docker config %s, %+v", path, err.Error()))
+	}
+	return dc, nil
+}
+
+// normalizeRegistryHost drop scheme and path from auths key
+
+func normalizeRegistryHost(server string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	host = strings.SplitN(host, StringSlash, 2)[0]
+	host = strings.ToLower(host)
+	switch host {
+	case "index.docker.io", "registry-1.docker.io":
+		return DockerHubRegistry
+	}
+	return host
+}
+
+// helperServer is server name for credential helper request
+
+func helperServer(host string) string {
+	if host == DockerHubRegistry {
+		return DockerHubIndexServer
+	}
+	return host
+}
+
+// Credentials find credentials for registry host in docker config
+
+func (dc *DockerConfig) Credentials(registry string) (data.Credentials, bool) {
+	host := normalizeRegistryHost(registry)
+
+	// credential helpers take precedence over plain auths
+	helper, ok := dc.CredHelpers[host]
+	if !ok {
+		helper, ok = dc.CredHelpers[helperServer(host)]
+	}
+	if !ok && len(dc.CredsStore) != 0 {
+		helper, ok = dc.CredsStore, true
+	}
+	if ok && len(helper) != 0 {
+		if cred, found := helperCredentials(dc.logger, helper, helperServer(host)); found {
+			return cred, true
+		}
+	}
+
+	for server, entry := range dc.Auths {
+		if normalizeRegistryHost(server) != host {
+			continue
+		}
+		if cred, found := entry.credentials(dc.logger); found {
+			return cred, true
+		}
+	}
+	return data.Credentials{}, false
+}
+
+// credentials decode auths entry
+
+func (entry DockerAuthEntry) credentials(logger *logging.Logger) (data.Credentials, bool) {
+	if len(entry.IdentityToken) != 0 {
+		logger.Debug("docker config identity token isn't supported, skip it")
+	}
+	if len(entry.Username) != 0 || len(entry.Password) != 0 {
+		return data.Credentials{Username: entry.Username, Password: entry.Password}, true
+	}
+	if len(entry.Auth) == 0 {
+		return data.Credentials{}, false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		logger.Error(fmt.Sprintf("docker config auth isn't valid base64, %+v", err.Error()))
+		return data.Credentials{}, false
+	}
+	username, password, found := strings.Cut(string(decoded), StringColon)
+	if !found {
+		logger.Error("docker config auth must be username:password")
+		return data.Credentials{}, false
+	}
+	return data.Credentials{Username: username, Password: password}, true
+}
+
+// helperCredentials ask credential helper
+
+func helperCredentials(logger *logging.Logger, helper, server string) (data.Credentials, bool) {
+	out, err := runCredentialHelper(helper, server)
+	if err != nil {
+		// helper reports "credentials not found in native keychain" as error
+		logger.Debug(fmt.Sprintf("credential helper %s has no credentials for %s, %+v", helper, server, err.Error()))
+		return data.Credentials{}, false
+	}
+	var hc helperResponse
+	if err := json.Unmarshal(out, &hc); err != nil {
+		logger.Error(fmt.Sprintf("credential helper %s returns invalid json, %+v", helper, err.Error()))
+		return data.Credentials{}, false
+	}
+	if hc.Username == "<token>" {
+		logger.Debug(fmt.Sprintf("credential helper %s returns identity token, it isn't supported", helper))
+		return data.Credentials{}, false
+	}
+	if len(hc.Username) == 0 && len(hc.Secret) == 0 {
+		return data.Credentials{}, false
+	}
+	return data.Credentials{Username: hc.Username, Password: hc.Secret}, true
+}
+
+// ResolveCredentials fill credentials from docker config if nothing was given explicitly
+
+func (cl *RegClient) ResolveCredentials() {
+	if len(cl.Credentials.Username) != 0 || len(cl.Credentials.Password) != 0 {
+		return
+	}
+	if len(cl.Registry) == 0 {
+		return
+	}
+	path := DockerConfigPath()
+	dc, err := LoadDockerConfig(path)
+	if err != nil {
+		cl.Logger.Error(fmt.Sprintf("can not load docker config, %+v", err.Error()))
+		return
+	}
+	dc.logger = cl.Logger
+	cred, ok := dc.Credentials(cl.Registry)
+	if !ok {
+		cl.Logger.Debug(fmt.Sprintf("no docker credentials for %s", cl.Registry))
+		return
+	}
+	cl.Logger.Info(fmt.Sprintf("use docker credentials from %s for %s", path, cl.Registry))
+	cl.Credentials = cred
+
+	// add sensitives to list of logger
+	cl.Logger.AddSensitive(cred.Password)
+	basicauth := []byte(cred.Username + StringColon + cred.Password)
+	cl.Logger.AddSensitive(base64.StdEncoding.EncodeToString(basicauth))
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDockerConfig создаёт временный config.json и выставляет DOCKER_CONFIG
+func writeDockerConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, DockerConfigFileName)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Cannot write docker config: %v", err)
+	}
+	t.Setenv(DockerConfigEnv, dir)
+	return path
+}
+
+// stubCredentialHelper подменяет запуск docker-credential-* на время теста
+func stubCredentialHelper(t *testing.T, fn func(helper, server string) ([]byte, error)) {
+	t.Helper()
+	orig := runCredentialHelper
+	runCredentialHelper = fn
+	t.Cleanup(func() { runCredentialHelper = orig })
+}
+
+// TestDockerConfig_Auths проверяет чтение auths с разными формами ключей
+func TestDockerConfig_Auths(t *testing.T) {
+
+	auth := base64.StdEncoding.EncodeToString([]byte("harboruser:harborpass"))
+	path := writeDockerConfig(t, `{
+		"auths": {
+			"https://harbor.example.com/v2/": {"auth": "`+auth+`"},
+			"registry.example.com:5000": {"username": "plainuser", "password": "plainpass"},
+			"https://index.docker.io/v1/": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("hubuser:hubpass"))+`"}
+		}
+	}`)
+
+	dc, err := LoadDockerConfig(path)
+	if err != nil {
+		t.Fatalf("LoadDockerConfig should not return error, got: %v", err)
+	}
+
+	testcases := []struct {
+		registry string
+		username string
+		password string
+		found    bool
+	}{
+		{registry: "harbor.example.com", username: "harboruser", password: "harborpass", found: true},
+		{registry: "registry.example.com:5000", username: "plainuser", password: "plainpass", found: true},
+		{registry: "registry-1.docker.io", username: "hubuser", password: "hubpass", found: true},
+		{registry: "other.example.com", found: false},
+	}
+	for _, tc := range testcases {
+		cred, ok := dc.Credentials(tc.registry)
+		if ok != tc.found {
+			t.Errorf("Credentials(%q) found = %v, want %v", tc.registry, ok, tc.found)
+			continue
+		}
+		if cred.Username != tc.username || cred.Password != tc.password {
+			t.Errorf("Credentials(%q) = %q/%q, want %q/%q", tc.registry, cred.Username, cred.Password, tc.username, tc.password)
+		}
+	}
+}
+
+// TestDockerConfig_CredHelpers проверяет приоритет credHelpers и credsStore над auths
+func TestDockerConfig_CredHelpers(t *testing.T) {
+
+	var called []string
+	stubCredentialHelper(t, func(helper, server string) ([]byte, error) {
+		called = append(called, helper+"|"+server)
+		switch helper {
+		case "gcloud":
+			return []byte(`{"ServerURL":"gcr.io","Username":"_json_key","Secret":"gcloud-secret"}`), nil
+		case "desktop":
+			if server == DockerHubIndexServer {
+				return []byte(`{"ServerURL":"https://index.docker.io/v1/","Username":"hubuser","Secret":"hub-secret"}`), nil
+			}
+			return nil, errors.New("credentials not found in native keychain")
+		}
+		return nil, errors.New("unknown helper")
+	})
+
+	auth := base64.StdEncoding.EncodeToString([]byte("fileuser:filepass"))
+	path := writeDockerConfig(t, `{
+		"auths": {"harbor.example.com": {"auth": "`+auth+`"}},
+		"credsStore": "desktop",
+		"credHelpers": {"gcr.io": "gcloud"}
+	}`)
+	dc, err := LoadDockerConfig(path)
+	if err != nil {
+		t.Fatalf("LoadDockerConfig should not return error, got: %v", err)
+	}
+
+	cred, ok := dc.Credentials("gcr.io")
+	if !ok || cred.Username != "_json_key" || cred.Password != "gcloud-secret" {
+		t.Errorf("Credentials(gcr.io) = %+v, %v; want credHelpers result", cred, ok)
+	}
+
+	cred, ok = dc.Credentials("docker.io")
+	if !ok || cred.Username != "hubuser" || cred.Password != "hub-secret" {
+		t.Errorf("Credentials(docker.io) = %+v, %v; want credsStore result", cred, ok)
+	}
+
+	// credsStore не знает хост — используется auths
+	cred, ok = dc.Credentials("harbor.example.com")
+	if !ok || cred.Username != "fileuser" || cred.Password != "filepass" {
+		t.Errorf("Credentials(harbor.example.com) = %+v, %v; want auths fallback", cred, ok)
+	}
+
+	if len(called) != 3 {
+		t.Errorf("helper calls = %v, want 3 calls", called)
+	}
+}
+
+// TestLoadDockerConfig_Missing проверяет, что отсутствие файла не является ошибкой
+func TestLoadDockerConfig_Missing(t *testing.T) {
+	dc, err := LoadDockerConfig(filepath.Join(t.TempDir(), "absent.json"))
+	if err != nil {
+		t.Fatalf("LoadDockerConfig for missing file should not return error, got: %v", err)
+	}
+	if _, ok := dc.Credentials("registry.example.com"); ok {
+		t.Error("empty docker config should not contain credentials")
+	}
+}
+
+// TestResolveCredentials проверяет, что явные учётные данные не перезаписываются
+func TestResolveCredentials(t *testing.T) {
+
+	writeDockerConfig(t, `{"auths": {"registry.example.com": {"username": "dockeruser", "password": "dockerpass"}}}`)
+
+	cl := &RegClient{Registry: "registry.example.com", Logger: logging.New(logging.LogDebugLevel)}
+	cl.ResolveCredentials()
+	if cl.Credentials.Username != "dockeruser" || cl.Credentials.Password != "dockerpass" {
+		t.Errorf("ResolveCredentials = %+v, want docker config credentials", cl.Credentials)
+	}
+	if cl.Logger.Mask("dockerpass") == "dockerpass" {
+		t.Error("ResolveCredentials should add password to masked literals")
+	}
+
+	cl = &RegClient{
+		Registry:    "registry.example.com",
+		Credentials: data.Credentials{Username: "flaguser", Password: "flagpass"},
+	}
+	cl.ResolveCredentials()
+	if cl.Credentials.Username != "flaguser" || cl.Credentials.Password != "flagpass" {
+		t.Errorf("ResolveCredentials overrides explicit credentials: %+v", cl.Credentials)
+	}
+}
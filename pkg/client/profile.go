@@ -0,0 +1,94 @@
+package client
+
+import (
+	"cnabtool/pkg/data"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profile find registry profile for host - exact key first, then the longest matched glob
+
+func (cc *Config) Profile(host string) (string, *data.RegistryProfile) {
+	host = strings.ToLower(host)
+	if p, ok := cc.Registries[host]; ok {
+		return host, &p
+	}
+
+	patterns := make([]string, 0, len(cc.Registries))
+	for key := range cc.Registries {
+		patterns = append(patterns, key)
+	}
+	// the longest pattern is the most specific one
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		matched, err := path.Match(strings.ToLower(pattern), host)
+		if err != nil {
+			cc.Logger.Error(fmt.Sprintf("invalid registry pattern %s, %+v", pattern, err.Error()))
+			continue
+		}
+		if matched {
+			p := cc.Registries[pattern]
+			return pattern, &p
+		}
+	}
+	return "", nil
+}
+
+// applyProfile override client settings with registry profile, settings
+// given by command line flags are kept
+
+func (cl *RegClient) applyProfile(cc *Config) {
+	key, profile := cc.Profile(cl.Registry)
+	if profile == nil {
+		return
+	}
+	cl.Logger.Info(fmt.Sprintf("use registry profile %s for %s", key, cl.Registry))
+	explicit := func(settings ...string) bool {
+		for _, setting := range settings {
+			if cc.Explicit[setting] {
+				cl.Logger.Debug(fmt.Sprintf("flag %s wins over registry profile %s", setting, key))
+				return true
+			}
+		}
+		return false
+	}
+
+	if (len(profile.Credentials.Username) != 0 || len(profile.Credentials.Password) != 0) && !explicit("username", "password") {
+		cl.Credentials = profile.Credentials
+		// add sensitives to global list
+		cl.Logger.AddSensitive(profile.Credentials.Password)
+		basicauth := []byte(profile.Credentials.Username + StringColon + profile.Credentials.Password)
+		cl.Logger.AddSensitive(base64.StdEncoding.EncodeToString(basicauth))
+	}
+	if len(profile.Scheme) != 0 && !explicit("scheme") {
+		cl.Scheme = profile.Scheme
+	}
+	if profile.Unsecure && !explicit("unsecure") {
+		cl.TLS.Unsecure = true
+	}
+	if len(profile.CACert) != 0 && !explicit("cacert") {
+		cl.TLS.CACert = profile.CACert
+	}
+	if (len(profile.Cert) != 0 || len(profile.Key) != 0) && !explicit("cert", "key") {
+		cl.TLS.Cert = profile.Cert
+		cl.TLS.Key = profile.Key
+	}
+	if profile.Timeout > 0 && !explicit("timeout") {
+		cl.WebClient.Timeout = time.Millisecond * time.Duration(profile.Timeout)
+	}
+	if len(profile.Client) != 0 && !explicit("client") {
+		cl.Client = profile.Client
+	}
+	if len(profile.RepoKey) != 0 && !explicit("repokey") {
+		cl.RepoKey = profile.RepoKey
+	}
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
+	"testing"
+	"time"
+)
+
+// TestProfile_Match проверяет выбор профиля по точному хосту и glob-шаблону
+func TestProfile_Match(t *testing.T) {
+
+	cfg := &Config{
+		Logger: logging.New(logging.LogDebugLevel),
+		Registries: map[string]data.RegistryProfile{
+			"harbor.example.com":        {Scheme: "https"},
+			"*.example.com":             {Scheme: "http"},
+			"*.artifactory.example.com": {RepoKey: "cnab-local"},
+			"localhost:5000":            {Scheme: "http"},
+		},
+	}
+
+	testcases := []struct {
+		host string
+		key  string
+	}{
+		{host: "harbor.example.com", key: "harbor.example.com"},
+		{host: "HARBOR.example.com", key: "harbor.example.com"},
+		{host: "other.example.com", key: "*.example.com"},
+		{host: "cnab.artifactory.example.com", key: "*.artifactory.example.com"},
+		{host: "localhost:5000", key: "localhost:5000"},
+		{host: "registry.other.org", key: ""},
+	}
+	for _, tc := range testcases {
+		key, profile := cfg.Profile(tc.host)
+		if key != tc.key {
+			t.Errorf("Profile(%q) key = %q, want %q", tc.host, key, tc.key)
+		}
+		if len(tc.key) == 0 && profile != nil {
+			t.Errorf("Profile(%q) should return nil profile", tc.host)
+		}
+	}
+}
+
+// TestNewRegClient_Profile проверяет применение профиля к клиенту
+func TestNewRegClient_Profile(t *testing.T) {
+
+	cfg := &Config{
+		Logger:  logging.New(logging.LogDebugLevel),
+		Scheme:  "https",
+		Timeout: 10000,
+		Client:  "cnabtool/0.1.1",
+		Credentials: data.Credentials{
+			Username: "globaluser",
+			Password: "globalpass",
+		},
+		RepoKey: "global-key",
+		Registries: map[string]data.RegistryProfile{
+			"*.art.example.com": {
+				Credentials: data.Credentials{
+					Username: "artuser",
+					Password: "artpass",
+				},
+				Scheme:   "http",
+				Unsecure: true,
+				Timeout:  3000,
+				Client:   "cnabtool-ci",
+				RepoKey:  "cnab-local",
+			},
+		},
+	}
+
+	cl := NewRegClient(cfg, "cnab.art.example.com/app/bundle:1.0")
+	if cl.Credentials.Username != "artuser" || cl.Credentials.Password != "artpass" {
+		t.Errorf("Credentials = %+v, want profile credentials", cl.Credentials)
+	}
+	if cl.Scheme != "http" {
+		t.Errorf("Scheme = %q, want %q", cl.Scheme, "http")
+	}
+	if !cl.TLS.Unsecure {
+		t.Error("Unsecure should be set by profile")
+	}
+	if cl.WebClient.Timeout != 3*time.Second {
+		t.Errorf("Timeout = %v, want 3s", cl.WebClient.Timeout)
+	}
+	if cl.Client != "cnabtool-ci" {
+		t.Errorf("Client = %q, want %q", cl.Client, "cnabtool-ci")
+	}
+	if cl.RepoKey != "cnab-local" {
+		t.Errorf("RepoKey = %q, want %q", cl.RepoKey, "cnab-local")
+	}
+
+	// реестр без профиля получает глобальные настройки
+	cl = NewRegClient(cfg, "registry.other.org/app/bundle:1.0")
+	if cl.Credentials.Username != "globaluser" {
+		t.Errorf("Credentials.Username = %q, want %q", cl.Credentials.Username, "globaluser")
+	}
+	if cl.Scheme != "https" {
+		t.Errorf("Scheme = %q, want %q", cl.Scheme, "https")
+	}
+	if cl.RepoKey != "global-key" {
+		t.Errorf("RepoKey = %q, want %q", cl.RepoKey, "global-key")
+	}
+}
+
+// TestNewRegClient_ProfileExplicitFlags проверяет, что флаги командной строки важнее профиля
+func TestNewRegClient_ProfileExplicitFlags(t *testing.T) {
+
+	cfg := &Config{
+		Logger:  logging.New(logging.LogDebugLevel),
+		Timeout: 10000,
+		Credentials: data.Credentials{
+			Username: "flaguser",
+			Password: "flagpass",
+		},
+		RepoKey:  "flag-key",
+		Unsecure: false,
+		Registries: map[string]data.RegistryProfile{
+			"*.art.example.com": {
+				Credentials: data.Credentials{
+					Username: "artuser",
+					Password: "artpass",
+				},
+				Timeout:  3000,
+				RepoKey:  "cnab-local",
+				Unsecure: true,
+			},
+		},
+		Explicit: map[string]bool{"username": true, "password": true, "repokey": true},
+	}
+
+	cl := NewRegClient(cfg, "cnab.art.example.com/app/bundle:1.0")
+	if cl.Credentials.Username != "flaguser" || cl.Credentials.Password != "flagpass" {
+		t.Errorf("Credentials = %+v, want credentials of flags", cl.Credentials)
+	}
+	if cl.RepoKey != "flag-key" {
+		t.Errorf("RepoKey = %q, want %q of flag", cl.RepoKey, "flag-key")
+	}
+	// настройки без флагов берутся из профиля
+	if cl.WebClient.Timeout != 3*time.Second || !cl.TLS.Unsecure {
+		t.Errorf("Timeout = %v, Unsecure = %v, want profile values", cl.WebClient.Timeout, cl.TLS.Unsecure)
+	}
+
+	// только пароль из командной строки тоже отменяет учётные данные профиля
+	cfg.Explicit = map[string]bool{"password": true, "timeout": true}
+	cl = NewRegClient(cfg, "cnab.art.example.com/app/bundle:1.0")
+	if cl.Credentials.Username != "flaguser" || cl.WebClient.Timeout != 10*time.Second {
+		t.Errorf("Credentials = %+v, Timeout = %v, want values of flags", cl.Credentials, cl.WebClient.Timeout)
+	}
+	if cl.RepoKey != "cnab-local" {
+		t.Errorf("RepoKey = %q, want profile %q without flag", cl.RepoKey, "cnab-local")
+	}
+}
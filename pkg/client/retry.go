@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultRetries   = 3                      // retries after the first attempt
+	DefaultRetryWait = 500 * time.Millisecond // first backoff interval
+	MaxRetryWait     = 30 * time.Second       // backoff and Retry-After limit
+)
+
+// sleep between attempts, replaceable for tests
+
+var sleep = time.Sleep
+
+// retryableStatus - server may answer correctly next time
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableError - transport errors like connection reset or timeout, but not tls problems
+
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var verr *tls.CertificateVerificationError
+	if errors.As(err, &verr) {
+		return false
+	}
+	return true
+}
+
+// repeatable - GET and HEAD may be sent again after any failure. Other requests,
+// e.g. DELETE, may be performed by server even if response is lost or is 5xx,
+// so they are repeated only if server surely didn't get them: connection was
+// not established or server refused request with 429
+
+func repeatable(req *http.Request, res *http.Response, err error) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+	if err != nil {
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	return res.StatusCode == http.StatusTooManyRequests
+}
+
+// backoff exponential interval with jitter for attempt, starts from 0
+
+func (cl *RegClient) backoff(attempt int) time.Duration {
+	wait := cl.RetryWait
+	if wait <= 0 {
+		wait = DefaultRetryWait
+	}
+	for i := 0; i < attempt && wait < MaxRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > MaxRetryWait {
+		wait = MaxRetryWait
+	}
+	// full interval is upper bound, at least half of it is waited
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter decode Retry-After header, seconds or http date
+
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			seconds = 0
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// rateLimitRemaining decode docker hub RateLimit-Remaining header, e.g. 76;w=21600
+
+func rateLimitRemaining(header http.Header) (int, bool) {
+	value := strings.TrimSpace(header.Get("RateLimit-Remaining"))
+	if len(value) == 0 {
+		return 0, false
+	}
+	count, _, _ := strings.Cut(value, ";")
+	remaining, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// send do request with retries on transient errors
+
+func (cl *RegClient) send(req *http.Request) (*http.Response, error) {
+	if cl.transportErr != nil {
+		return nil, cl.transportErr
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := cl.WebClient.Do(req.Clone(req.Context()))
+
+		var wait time.Duration
+		if err != nil {
+			if attempt >= cl.Retries || !retryableError(err) || !repeatable(req, nil, err) {
+				cl.Logger.Debug(fmt.Sprintf("%s %s failed after %d retries", req.Method, req.URL, attempt))
+				return nil, err
+			}
+			wait = cl.backoff(attempt)
+			cl.Logger.Debug(fmt.Sprintf("%s %s: %+v", req.Method, req.URL, err.Error()))
+		} else {
+			remaining, limited := rateLimitRemaining(res.Header)
+			if limited {
+				cl.Logger.Debug(fmt.Sprintf("rate limit remaining %d", remaining))
+				if remaining == 0 {
+					cl.Logger.Message(fmt.Sprintf("registry %s rate limit is exhausted", req.URL.Host))
+				}
+			}
+			if attempt >= cl.Retries || !retryableStatus(res.StatusCode) || !repeatable(req, res, nil) {
+				cl.Logger.Debug(fmt.Sprintf("%s %s status %d after %d retries", req.Method, req.URL, res.StatusCode, attempt))
+				return res, nil
+			}
+			wait = cl.backoff(attempt)
+			if after, ok := retryAfter(res.Header); ok {
+				wait = after
+			} else if res.StatusCode == http.StatusTooManyRequests && limited && remaining == 0 {
+				// quota window is hours long, backoff won't help
+				cl.Logger.Debug(fmt.Sprintf("%s %s: rate limit without Retry-After, give up", req.Method, req.URL))
+				return res, nil
+			}
+			if wait > MaxRetryWait {
+				cl.Logger.Debug(fmt.Sprintf("%s %s: Retry-After %v is too long, give up", req.Method, req.URL, wait))
+				return res, nil
+			}
+			io.Copy(io.Discard, io.LimitReader(res.Body, MaxBodySize))
+			res.Body.Close()
+		}
+
+		cl.Logger.Debug(fmt.Sprintf("retry %d of %d for %s %s in %v", attempt+1, cl.Retries, req.Method, req.URL, wait))
+		sleep(wait)
+	}
+}
@@ -0,0 +1,249 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubSleep подменяет паузу между попытками и записывает интервалы
+func stubSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = orig })
+	return &waits
+}
+
+// newRetryClient создаёт клиент для тестового сервера
+func newRetryClient(server *httptest.Server, retries int) *RegClient {
+	cfg := &Config{
+		Scheme:    "http",
+		Client:    "cnabtool/0.1.1",
+		Retries:   retries,
+		RetryWait: 100,
+	}
+	cl := NewRegClient(cfg, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+	cl.Tag = "v1"
+	return cl
+}
+
+// TestSend_RetryOnServerError проверяет повтор при 5xx с экспоненциальной паузой
+func TestSend_RetryOnServerError(t *testing.T) {
+	waits := stubSleep(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= 2 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeOciIndex)
+		w.Write([]byte(`{"schemaVersion":2,"manifests":[]}`))
+	}))
+	defer server.Close()
+
+	cl := newRetryClient(server, 3)
+	regres, err := cl.GetRegIndex()
+	if err != nil {
+		t.Fatalf("GetRegIndex should succeed after retries, got: %v", err)
+	}
+	if regres.Status != 200 {
+		t.Errorf("status = %d, want 200", regres.Status)
+	}
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+	if len(*waits) != 2 {
+		t.Fatalf("waits = %v, want 2 pauses", *waits)
+	}
+	// первая пауза в [50ms, 100ms], вторая в [100ms, 200ms]
+	if (*waits)[0] < 50*time.Millisecond || (*waits)[0] > 100*time.Millisecond {
+		t.Errorf("first wait = %v, want within [50ms, 100ms]", (*waits)[0])
+	}
+	if (*waits)[1] < 100*time.Millisecond || (*waits)[1] > 200*time.Millisecond {
+		t.Errorf("second wait = %v, want within [100ms, 200ms]", (*waits)[1])
+	}
+}
+
+// TestSend_RetryAfter проверяет соблюдение заголовка Retry-After
+func TestSend_RetryAfter(t *testing.T) {
+	waits := stubSleep(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(429)
+			return
+		}
+		w.WriteHeader(202)
+	}))
+	defer server.Close()
+
+	cl := newRetryClient(server, 3)
+	res, err := cl.WebDelete(server.URL + "/v2/repo/cnab/manifests/sha256:abc")
+	if err != nil {
+		t.Fatalf("WebDelete should succeed, got: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 202 {
+		t.Errorf("status = %d, want 202", res.StatusCode)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("waits = %v, want [7s]", *waits)
+	}
+}
+
+// TestSend_RateLimitExhausted проверяет отказ от повторов при исчерпанной квоте
+func TestSend_RateLimitExhausted(t *testing.T) {
+	waits := stubSleep(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("RateLimit-Limit", "100;w=21600")
+		w.Header().Set("RateLimit-Remaining", "0;w=21600")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(429)
+		w.Write([]byte(`{"errors":[{"code":"TOOMANYREQUESTS"}]}`))
+	}))
+	defer server.Close()
+
+	cl := newRetryClient(server, 3)
+	regres, err := cl.GetRegIndex()
+	if err == nil {
+		t.Fatal("GetRegIndex should fail on exhausted rate limit")
+	}
+	if regres.Status != 429 {
+		t.Errorf("status = %d, want 429", regres.Status)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1 (no retries without quota)", requests)
+	}
+	if len(*waits) != 0 {
+		t.Errorf("waits = %v, want none", *waits)
+	}
+}
+
+// TestSend_RetriesExhausted проверяет возврат последнего ответа после всех попыток
+func TestSend_RetriesExhausted(t *testing.T) {
+	waits := stubSleep(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(502)
+		w.Write([]byte(`{"errors":[]}`))
+	}))
+	defer server.Close()
+
+	cl := newRetryClient(server, 2)
+	regres, err := cl.GetRegIndex()
+	if err == nil {
+		t.Fatal("GetRegIndex should fail when retries are exhausted")
+	}
+	if regres.Status != 502 {
+		t.Errorf("status = %d, want 502", regres.Status)
+	}
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3 (1 + 2 retries)", requests)
+	}
+	if len(*waits) != 2 {
+		t.Errorf("waits = %v, want 2 pauses", *waits)
+	}
+}
+
+// TestSend_ConnectionError проверяет повтор при сетевой ошибке
+func TestSend_ConnectionError(t *testing.T) {
+	waits := stubSleep(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close() // соединение будет отвергнуто
+
+	cl := newRetryClient(server, 2)
+	_, err := cl.GetRegIndex()
+	if err == nil {
+		t.Fatal("GetRegIndex should fail for closed server")
+	}
+	if len(*waits) != 2 {
+		t.Errorf("waits = %v, want 2 pauses", *waits)
+	}
+}
+
+// TestRetryHeaders проверяет разбор Retry-After и RateLimit-Remaining
+func TestRetryHeaders(t *testing.T) {
+	header := http.Header{}
+	if _, ok := retryAfter(header); ok {
+		t.Error("retryAfter without header should report false")
+	}
+	header.Set("Retry-After", "12")
+	if wait, ok := retryAfter(header); !ok || wait != 12*time.Second {
+		t.Errorf("retryAfter = %v, %v; want 12s", wait, ok)
+	}
+	header.Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	if wait, ok := retryAfter(header); !ok || wait != 0 {
+		t.Errorf("retryAfter for past date = %v, %v; want 0", wait, ok)
+	}
+
+	header.Set("RateLimit-Remaining", "76;w=21600")
+	if remaining, ok := rateLimitRemaining(header); !ok || remaining != 76 {
+		t.Errorf("rateLimitRemaining = %d, %v; want 76", remaining, ok)
+	}
+	header.Set("RateLimit-Remaining", "bogus")
+	if _, ok := rateLimitRemaining(header); ok {
+		t.Error("rateLimitRemaining for invalid value should report false")
+	}
+}
+
+// TestSend_DeleteNotRepeated проверяет, что DELETE повторяется только если сервер его не получил
+func TestSend_DeleteNotRepeated(t *testing.T) {
+	stubSleep(t)
+
+	tests := []struct {
+		status   int
+		requests int
+	}{
+		// сервер мог удалить манифест и потерять ответ
+		{status: 502, requests: 1},
+		{status: 503, requests: 1},
+		// запрос отвергнут до выполнения
+		{status: 429, requests: 3},
+	}
+	for _, tt := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(tt.status)
+		}))
+
+		cl := newRetryClient(server, 2)
+		if _, _, err := cl.DeleteTag("v1"); err == nil {
+			t.Errorf("DeleteTag should fail for status %d", tt.status)
+		}
+		if requests != tt.requests {
+			t.Errorf("status %d: requests = %d, want %d", tt.status, requests, tt.requests)
+		}
+		server.Close()
+	}
+
+	// соединение не установлено, запрос не дошёл до сервера
+	waits := stubSleep(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+	cl := newRetryClient(server, 2)
+	if _, _, err := cl.DeleteTag("v1"); err == nil {
+		t.Fatal("DeleteTag should fail for closed server")
+	}
+	if len(*waits) != 2 {
+		t.Errorf("waits = %v, want 2 pauses", *waits)
+	}
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// tags list response
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-tags
+
+type TagList struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+var ErrTagListInvalid = errors.New("invalid tag list")
+
+// nextLink find rel="next" url in RFC 5988 Link header
+
+func nextLink(header http.Header) string {
+	for _, line := range header.Values("Link") {
+		for _, link := range strings.Split(line, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.ToLower(key) == "rel" && strings.Trim(value, "\"") == "next" {
+					return strings.Trim(target, "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// tagListURL make first page url, n is page size if set
+
+func (cl *RegClient) tagListURL(last string) string {
+	taglisturl := cl.Scheme + "://" + cl.Registry + "/v2/" + cl.Repository + "/tags/list/"
+	query := url.Values{}
+	if cl.PageSize > 0 {
+		query.Set("n", strconv.Itoa(cl.PageSize))
+	}
+	if len(last) != 0 {
+		query.Set("last", last)
+	}
+	if len(query) != 0 {
+		taglisturl += "?" + query.Encode()
+	}
+	return taglisturl
+}
+
+// nextPageURL resolve the next page by Link header, or by n/last parameters
+// if page size is set
+
+func (cl *RegClient) nextPageURL(res *http.Response, current string, page *TagList) (string, error) {
+	if link := nextLink(res.Header); len(link) != 0 {
+		base, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(link)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("invalid next link %s, %+v", link, err.Error()))
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+	// without Link header page of exactly n tags means there may be more tags,
+	// shorter or longer page is the whole rest of list
+	if cl.PageSize > 0 && len(page.Tags) == cl.PageSize {
+		return cl.tagListURL(page.Tags[len(page.Tags)-1]), nil
+	}
+	return "", nil
+}
+
+// GetTagList fetch all pages of tags list and merge them to one json
+
+func (cl *RegClient) GetTagList() (*RegResponse, error) {
+
+	// tune url
+	pageurl := cl.tagListURL("")
+
+	regres := &RegResponse{
+		logger:    cl.Logger,
+		Reference: strings.Replace(pageurl, cl.Scheme+"://", "", 1),
+		Media:     MediaTypeJson,
+	}
+
+	taglist := TagList{}
+	seenTags := make(map[string]bool)
+	seenPages := make(map[string]bool)
+
+	for pages := 1; len(pageurl) != 0; pages++ {
+		seenPages[pageurl] = true
+
+		res, err := cl.WebRequest(pageurl, MediaTypeJson)
+		if err != nil { // unrecoverable error
+			cl.Logger.Error(fmt.Sprintf("response is nil, %+v", err.Error()))
+			return regres, err
+		}
+		cl.Logger.Debug(fmt.Sprintf("response %+v", res))
+
+		regres.Status = res.StatusCode
+		regres.Date = res.Header.Get("Last-Modified")
+
+		if res.StatusCode != 200 {
+			bytesbody, _ := io.ReadAll(io.LimitReader(res.Body, MaxBodySize))
+			res.Body.Close()
+			regres.Content = string(bytesbody)
+			err := StatusError(res.StatusCode, fmt.Sprintf("failed to fetch data %s: %s", res.Status, strings.Join(strings.Fields(regres.Content), " ")))
+			cl.Logger.Error(err.Error())
+			return regres, err
+		}
+
+		// stream decode, page size isn't limited
+		page := &TagList{}
+		err = json.NewDecoder(res.Body).Decode(page)
+		res.Body.Close()
+		if err != nil {
+			err := fmt.Errorf("%w: failed to decode tags list page %d, %s", ErrTagListInvalid, pages, err.Error())
+			cl.Logger.Error(err.Error())
+			return regres, err
+		}
+
+		if len(taglist.Name) == 0 {
+			taglist.Name = page.Name
+		}
+		added := 0
+		for _, tag := range page.Tags {
+			if !seenTags[tag] {
+				seenTags[tag] = true
+				taglist.Tags = append(taglist.Tags, tag)
+				added++
+			}
+		}
+		cl.Logger.Debug(fmt.Sprintf("tags list page %d has %d tags, %d new", pages, len(page.Tags), added))
+
+		next, err := cl.nextPageURL(res, pageurl, page)
+		if err != nil {
+			cl.Logger.Error(err.Error())
+			return regres, err
+		}
+		if added == 0 || seenPages[next] {
+			// registry ignores pagination parameters
+			break
+		}
+		pageurl = next
+	}
+
+	if taglist.Tags == nil {
+		taglist.Tags = []string{}
+	}
+	content, err := json.Marshal(taglist)
+	if err != nil {
+		return regres, err
+	}
+	regres.Content = string(content)
+	regres.Length = len(content)
+
+	return regres, nil
+}
+
+// registry doesn't delete manifests by tag, check it with errors.Is
+
+var ErrUntagUnsupported = errors.New("tag deletion is not supported")
+
+// DeleteTag remove tag of repository, manifest itself is kept.
+// Registries without tag deletion answer 400 or 405 (OCI distribution 1.1)
+// or error code UNSUPPORTED, they are reported as ErrUntagUnsupported
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#deleting-tags
+
+func (cl *RegClient) DeleteTag(tag string) (int, string, error) {
+	url := cl.Scheme + "://" + cl.Registry + "/v2/" + cl.Repository + "/manifests/" + tag
+	res, err := cl.WebDelete(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusAccepted || res.StatusCode == http.StatusOK {
+		return res.StatusCode, "", nil
+	}
+
+	bytesbody, _ := io.ReadAll(io.LimitReader(res.Body, MaxBodySize))
+	body := string(bytesbody)
+	message := fmt.Sprintf("delete tag %s failed with status %d", tag, res.StatusCode)
+	switch {
+	case res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusMethodNotAllowed ||
+		res.StatusCode == http.StatusNotImplemented || strings.Contains(body, "UNSUPPORTED"):
+		return res.StatusCode, body, fmt.Errorf("%w: %s", ErrUntagUnsupported, message)
+	}
+	return res.StatusCode, body, StatusError(res.StatusCode, message)
+}
@@ -0,0 +1,250 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// makeTags создаёт отсортированный список тегов
+func makeTags(count int) []string {
+	tags := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		tags = append(tags, fmt.Sprintf("v1.0.%05d", i))
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// paginatedServer отдаёт теги страницами по n/last, с заголовком Link или без него
+func paginatedServer(t *testing.T, tags []string, withLink bool, requests *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		last := r.URL.Query().Get("last")
+		start := sort.SearchStrings(tags, last)
+		if len(last) != 0 && start < len(tags) && tags[start] == last {
+			start++
+		}
+		end := len(tags)
+		if n > 0 && start+n < end {
+			end = start + n
+		}
+		if withLink && end < len(tags) {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/repo/cnab/tags/list/?n=%d&last=%s>; rel="next"`, n, tags[end-1]))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(TagList{Name: "repo/cnab", Tags: tags[start:end]})
+	}))
+}
+
+// TestGetTagList_LinkPagination проверяет переход по заголовку Link rel="next"
+func TestGetTagList_LinkPagination(t *testing.T) {
+
+	tags := makeTags(25)
+	requests := 0
+	server := paginatedServer(t, tags, true, &requests)
+	defer server.Close()
+
+	cl := NewRegClient(&Config{Scheme: "http", PageSize: 10}, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	regres, err := cl.GetTagList()
+	if err != nil {
+		t.Fatalf("GetTagList should not return error, got: %v", err)
+	}
+	var result TagList
+	if err := json.Unmarshal([]byte(regres.Content), &result); err != nil {
+		t.Fatalf("merged content is not valid json: %v", err)
+	}
+	if len(result.Tags) != 25 {
+		t.Errorf("tags = %d, want 25", len(result.Tags))
+	}
+	if result.Name != "repo/cnab" {
+		t.Errorf("name = %q, want %q", result.Name, "repo/cnab")
+	}
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3 pages", requests)
+	}
+}
+
+// TestGetTagList_LastPagination проверяет пагинацию по n/last без заголовка Link
+func TestGetTagList_LastPagination(t *testing.T) {
+
+	tags := makeTags(20)
+	requests := 0
+	server := paginatedServer(t, tags, false, &requests)
+	defer server.Close()
+
+	cl := NewRegClient(&Config{Scheme: "http", PageSize: 10}, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	regres, err := cl.GetTagList()
+	if err != nil {
+		t.Fatalf("GetTagList should not return error, got: %v", err)
+	}
+	var result TagList
+	json.Unmarshal([]byte(regres.Content), &result)
+	if len(result.Tags) != 20 {
+		t.Errorf("tags = %d, want 20", len(result.Tags))
+	}
+	// третья страница пустая и завершает обход
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+}
+
+// TestGetTagList_IgnoredPageSize проверяет реестр, игнорирующий параметр n
+func TestGetTagList_IgnoredPageSize(t *testing.T) {
+
+	tags := makeTags(10)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(TagList{Name: "repo/cnab", Tags: tags})
+	}))
+	defer server.Close()
+
+	cl := NewRegClient(&Config{Scheme: "http", PageSize: 10}, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	regres, err := cl.GetTagList()
+	if err != nil {
+		t.Fatalf("GetTagList should not return error, got: %v", err)
+	}
+	var result TagList
+	json.Unmarshal([]byte(regres.Content), &result)
+	if len(result.Tags) != 10 {
+		t.Errorf("tags = %d, want 10 without duplicates", len(result.Tags))
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+}
+
+// TestGetTagList_WholeListInOnePage проверяет, что список длиннее n без Link не запрашивается повторно
+func TestGetTagList_WholeListInOnePage(t *testing.T) {
+	tags := makeTags(25)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(TagList{Name: "repo/cnab", Tags: tags})
+	}))
+	defer server.Close()
+
+	cl := NewRegClient(&Config{Scheme: "http", PageSize: 10}, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	regres, err := cl.GetTagList()
+	if err != nil {
+		t.Fatalf("GetTagList should not return error, got: %v", err)
+	}
+	var result TagList
+	json.Unmarshal([]byte(regres.Content), &result)
+	if len(result.Tags) != 25 {
+		t.Errorf("tags = %d, want 25", len(result.Tags))
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
+
+// TestGetTagList_LargeList проверяет список тегов больше прежнего лимита 32 КБ
+func TestGetTagList_LargeList(t *testing.T) {
+
+	tags := makeTags(5000)
+	requests := 0
+	server := paginatedServer(t, tags, false, &requests)
+	defer server.Close()
+
+	cl := NewRegClient(&Config{Scheme: "http"}, "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	regres, err := cl.GetTagList()
+	if err != nil {
+		t.Fatalf("GetTagList should not return error, got: %v", err)
+	}
+	if regres.Length <= 32384 {
+		t.Errorf("content length = %d, test list must exceed 32 KB", regres.Length)
+	}
+	var result TagList
+	json.Unmarshal([]byte(regres.Content), &result)
+	if len(result.Tags) != 5000 {
+		t.Errorf("tags = %d, want 5000", len(result.Tags))
+	}
+}
+
+// TestNextLink проверяет разбор заголовка Link
+func TestNextLink(t *testing.T) {
+	testcases := []struct {
+		link string
+		want string
+	}{
+		{link: `</v2/repo/tags/list?n=10&last=b>; rel="next"`, want: "/v2/repo/tags/list?n=10&last=b"},
+		{link: `<https://r.example.com/v2/repo/tags/list?last=b>; rel=next`, want: "https://r.example.com/v2/repo/tags/list?last=b"},
+		{link: `</first>; rel="prev", </second>; rel="next"`, want: "/second"},
+		{link: `</first>; rel="prev"`, want: ""},
+		{link: ``, want: ""},
+	}
+	for _, tc := range testcases {
+		header := http.Header{}
+		if len(tc.link) != 0 {
+			header.Set("Link", tc.link)
+		}
+		if got := nextLink(header); got != tc.want {
+			t.Errorf("nextLink(%q) = %q, want %q", tc.link, got, tc.want)
+		}
+	}
+}
+
+// TestDeleteTag проверяет удаление тега и распознавание реестров без поддержки удаления тегов
+func TestDeleteTag(t *testing.T) {
+
+	testcases := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{status: 202},
+		{status: 400, body: `{"errors":[{"code":"DIGEST_INVALID"}]}`, want: ErrUntagUnsupported},
+		{status: 405, body: `{"errors":[{"code":"UNSUPPORTED"}]}`, want: ErrUntagUnsupported},
+		{status: 403, body: `{"errors":[{"code":"UNSUPPORTED","message":"tag deletion is disabled"}]}`, want: ErrUntagUnsupported},
+		{status: 404, body: `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`, want: ErrManifestNotFound},
+	}
+	for _, tc := range testcases {
+		var method, path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		}))
+
+		cl := NewRegClient(&Config{Scheme: "http"}, "test")
+		cl.Registry = strings.TrimPrefix(server.URL, "http://")
+		cl.Repository = "test/repo"
+
+		status, body, err := cl.DeleteTag("v1")
+		if method != http.MethodDelete || path != "/v2/test/repo/manifests/v1" {
+			t.Errorf("request = %s %s, want DELETE of tag", method, path)
+		}
+		if status != tc.status || body != tc.body {
+			t.Errorf("status %d: DeleteTag = %d, %q", tc.status, status, body)
+		}
+		if (tc.want == nil && err != nil) || (tc.want != nil && !errors.Is(err, tc.want)) {
+			t.Errorf("status %d: DeleteTag error = %v, want %v", tc.status, err, tc.want)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"cnabtool/pkg/logging"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// docker certs.d layout
+// https://docs.docker.com/engine/security/certificates/
+
+const (
+	CertsDirCAExt   = ".crt"  // CA certificate
+	CertsDirCertExt = ".cert" // client certificate
+	CertsDirKeyExt  = ".key"  // client certificate key
+)
+
+// ErrTLSConfig is broken CA bundle, client certificate or certs.d of registry client
+
+var ErrTLSConfig = errors.New("invalid tls settings")
+
+// TLSOptions tls settings of registry client
+
+type TLSOptions struct {
+	Unsecure bool   // skip server certificate verification
+	CACert   string // extra CA bundle
+	Cert     string // client certificate
+	Key      string // client certificate key
+	CertsDir string // root of <host>/ directories
+}
+
+// appendCA add pem bundle to pool
+
+func appendCA(pool *x509.CertPool, file string) error {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return errors.New(fmt.Sprintf("can not read CA %s, %+v", file, err.Error()))
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New(fmt.Sprintf("CA %s has no valid pem certificates", file))
+	}
+	return nil
+}
+
+// loadCertsDir read CA and client certificates from certs.d/<host>/
+
+func loadCertsDir(dir string, pool *x509.CertPool) (int, []tls.Certificate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil, nil
+		}
+		return 0, nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	cas := 0
+	var certs []tls.Certificate
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		switch filepath.Ext(name) {
+		case CertsDirCAExt:
+			if err := appendCA(pool, file); err != nil {
+				return cas, certs, err
+			}
+			cas++
+		case CertsDirCertExt:
+			keyfile := strings.TrimSuffix(file, CertsDirCertExt) + CertsDirKeyExt
+			cert, err := tls.LoadX509KeyPair(file, keyfile)
+			if err != nil {
+				return cas, certs, errors.New(fmt.Sprintf("can not load client certificate %s, %+v", file, err.Error()))
+			}
+			certs = append(certs, cert)
+		}
+	}
+	return cas, certs, nil
+}
+
+// NewTransport make http transport with tls settings for registry host
+
+func NewTransport(opts TLSOptions, host string, logger *logging.Logger) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsconf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.Unsecure,
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	cas := 0
+
+	if len(opts.CACert) != 0 {
+		if err := appendCA(pool, opts.CACert); err != nil {
+			return nil, err
+		}
+		cas++
+	}
+	if len(opts.Cert) != 0 || len(opts.Key) != 0 {
+		cert, err := tls.LoadX509KeyPair(opts.Cert, opts.Key)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("can not load client certificate %s, %+v", opts.Cert, err.Error()))
+		}
+		tlsconf.Certificates = append(tlsconf.Certificates, cert)
+	}
+	if len(opts.CertsDir) != 0 && len(host) != 0 {
+		dir := filepath.Join(opts.CertsDir, host)
+		n, certs, err := loadCertsDir(dir, pool)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 || len(certs) > 0 {
+			logger.Info(fmt.Sprintf("use certificates from %s", dir))
+		}
+		cas += n
+		tlsconf.Certificates = append(tlsconf.Certificates, certs...)
+	}
+	if cas > 0 {
+		tlsconf.RootCAs = pool
+	}
+	if opts.Unsecure {
+		logger.Info(fmt.Sprintf("tls verification is disabled for %s", host))
+	}
+
+	transport.TLSClientConfig = tlsconf
+	return transport, nil
+}
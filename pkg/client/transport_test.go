@@ -0,0 +1,177 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeServerCA сохраняет сертификат тестового TLS-сервера в pem-файл
+func writeServerCA(t *testing.T, server *httptest.Server, path string) {
+	t.Helper()
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0644); err != nil {
+		t.Fatalf("Cannot write CA: %v", err)
+	}
+}
+
+// writeClientCert создаёт самоподписанный клиентский сертификат и ключ
+func writeClientCert(t *testing.T, certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "cnabtool-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Cannot create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Cannot marshal key: %v", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("Cannot write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatalf("Cannot write key: %v", err)
+	}
+}
+
+// getWithTLS выполняет GET через транспорт с заданными настройками
+func getWithTLS(t *testing.T, opts TLSOptions, server *httptest.Server) error {
+	t.Helper()
+	host := strings.TrimPrefix(server.URL, "https://")
+	transport, err := NewTransport(opts, host, nil)
+	if err != nil {
+		t.Fatalf("NewTransport should not return error, got: %v", err)
+	}
+	webclient := http.Client{Transport: transport, Timeout: 5 * time.Second}
+	res, err := webclient.Get(server.URL + "/v2/")
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// TestNewTransport_Verification проверяет unsecure и дополнительный CA
+func TestNewTransport_Verification(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	if err := getWithTLS(t, TLSOptions{}, server); err == nil {
+		t.Error("request to server with unknown CA should fail")
+	}
+	if err := getWithTLS(t, TLSOptions{Unsecure: true}, server); err != nil {
+		t.Errorf("unsecure request should pass, got: %v", err)
+	}
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	writeServerCA(t, server, caPath)
+	if err := getWithTLS(t, TLSOptions{CACert: caPath}, server); err != nil {
+		t.Errorf("request with CA bundle should pass, got: %v", err)
+	}
+}
+
+// TestNewTransport_CertsDir проверяет CA и клиентский сертификат из certs.d/<host>/
+func TestNewTransport_CertsDir(t *testing.T) {
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(403)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	certsDir := t.TempDir()
+	hostDir := filepath.Join(certsDir, strings.TrimPrefix(server.URL, "https://"))
+	if err := os.MkdirAll(hostDir, 0755); err != nil {
+		t.Fatalf("Cannot create host dir: %v", err)
+	}
+	writeServerCA(t, server, filepath.Join(hostDir, "ca.crt"))
+
+	// без клиентского сертификата сервер рвёт соединение
+	if err := getWithTLS(t, TLSOptions{CertsDir: certsDir}, server); err == nil {
+		t.Error("request without client certificate should fail")
+	}
+
+	writeClientCert(t, filepath.Join(hostDir, "client.cert"), filepath.Join(hostDir, "client.key"))
+	if err := getWithTLS(t, TLSOptions{CertsDir: certsDir}, server); err != nil {
+		t.Errorf("request with certs.d should pass, got: %v", err)
+	}
+}
+
+// TestNewTransport_InvalidFiles проверяет ошибки загрузки сертификатов
+func TestNewTransport_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.pem")
+	if err := os.WriteFile(broken, []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+
+	if _, err := NewTransport(TLSOptions{CACert: broken}, "registry.example.com", nil); err == nil {
+		t.Error("NewTransport should fail for invalid CA bundle")
+	}
+	if _, err := NewTransport(TLSOptions{CACert: filepath.Join(dir, "absent.pem")}, "registry.example.com", nil); err == nil {
+		t.Error("NewTransport should fail for missing CA bundle")
+	}
+	if _, err := NewTransport(TLSOptions{Cert: broken, Key: broken}, "registry.example.com", nil); err == nil {
+		t.Error("NewTransport should fail for invalid client certificate")
+	}
+	// отсутствующий каталог certs.d не является ошибкой
+	if _, err := NewTransport(TLSOptions{CertsDir: filepath.Join(dir, "absent")}, "registry.example.com", nil); err != nil {
+		t.Errorf("NewTransport should ignore missing certs.d, got: %v", err)
+	}
+}
+
+// TestNewRegClient_InvalidTLS проверяет, что клиент с неверными настройками TLS не отправляет запросы
+func TestNewRegClient_InvalidTLS(t *testing.T) {
+
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	cfg := &Config{Scheme: "https", Timeout: 10000, Unsecure: true, CACert: filepath.Join(t.TempDir(), "absent.pem")}
+	cl := NewRegClient(cfg, strings.TrimPrefix(server.URL, "https://")+"/repo/cnab:v1")
+	if _, err := cl.GetRegIndex(); !errors.Is(err, ErrTLSConfig) {
+		t.Errorf("GetRegIndex error = %v, want ErrTLSConfig", err)
+	}
+	if _, err := cl.Clone().HeadManifest(); !errors.Is(err, ErrTLSConfig) {
+		t.Errorf("HeadManifest of clone error = %v, want ErrTLSConfig", err)
+	}
+	if requests != 0 {
+		t.Errorf("requests = %d, want none without configured CA", requests)
+	}
+}
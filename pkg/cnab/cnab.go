@@ -0,0 +1,306 @@
+/*
+Copyright © 2023 Aleksey Barabanov <alekseybb@gmail.com>
+*/
+
+// Package cnab is library api of cnabtool: inspect graph of cnab project
+// and delete its parts. Functions return typed results and errors, never
+// print results and never exit. Log messages go through logger of config,
+// which is quiet until Config.Logger.SetVerbosity is called.
+package cnab
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/config"
+	"cnabtool/pkg/content"
+	"cnabtool/pkg/data"
+	"errors"
+	"fmt"
+)
+
+// errors of registry and content, check them with errors.Is
+
+var (
+	ErrUnauthorized     = client.ErrUnauthorized
+	ErrManifestNotFound = client.ErrManifestNotFound
+	ErrDigestMismatch   = client.ErrDigestMismatch
+	ErrUnavailable      = client.ErrUnavailable
+	ErrNotCnabIndex     = content.ErrNotCnabIndex
+	ErrTagListInvalid   = content.ErrTagListInvalid
+	ErrPartial          = content.ErrPartial
+	ErrDanglingLinks    = content.ErrDanglingLinks
+	ErrFieldNotFound    = content.ErrFieldNotFound
+	ErrUntagUnsupported = client.ErrUntagUnsupported
+	ErrPolicyInvalid    = content.ErrPolicyInvalid
+	ErrSharedTags       = content.ErrSharedTags
+	ErrTLSConfig        = client.ErrTLSConfig
+)
+
+// type tricks
+
+type Config data.Config
+
+// typed results are shared with content package
+
+type (
+	Item         = data.RegIndex
+	Manifest     = client.RegResponse
+	Report       = content.Report
+	ReportItem   = content.ReportItem
+	DeleteEntry  = content.DeleteEntry
+	DeleteResult = content.DeleteResult
+	RetainEntry  = content.RetainEntry
+	UntagResult  = content.UntagResult
+	DeletePlan   = content.DeletePlan // items to delete in order of deletion and kept items with reasons
+	PrunePlan    = content.PrunePlan  // retention decisions per cnab and delete plan of pruned cnab
+)
+
+// inspected graph of cnab project
+
+type Graph struct {
+	Reference  string           // root reference
+	Scheme     string           // registry url scheme
+	Registry   string           // registry host
+	Repository string           // project repository
+	Items      []*Item          // items in order of discovery, also untagged
+	Tags       []string         // sorted tags of registry
+	ByTag      map[string]*Item // items by tag of registry
+	Report     Report           // short summary
+}
+
+// Lost return count of cnab links not found in registry
+
+func (g *Graph) Lost() int {
+	lost := 0
+	for _, item := range g.Items {
+		lost += item.Lost
+	}
+	return lost
+}
+
+// Check return ErrDanglingLinks if some cnab links were not found
+
+func (g *Graph) Check() error {
+	if lost := g.Lost(); lost != 0 {
+		return fmt.Errorf("%w: %d links of cnab indexes were not found", ErrDanglingLinks, lost)
+	}
+	return nil
+}
+
+// outcome of executed plan, results go in plan order
+
+type DeleteOutcome struct {
+	Results []DeleteResult `json:"results" yaml:"results"`
+	Deleted int            `json:"deleted" yaml:"deleted"`
+	Failed  int            `json:"failed" yaml:"failed"`
+}
+
+// opened cnab project
+
+type Project struct {
+	session *content.Session
+}
+
+// DefaultConfig make config with cli defaults
+
+func DefaultConfig() *Config {
+	return (*Config)(config.New())
+}
+
+// GetManifest get manifest by reference
+
+func GetManifest(cnf *Config, reference string) (*Manifest, error) {
+	regres, _, err := (*content.Config)(cnf).GetManifest(reference)
+	return regres, err
+}
+
+// HeadManifest get digest, media type and size of manifest by reference
+// without downloading its content
+
+func HeadManifest(cnf *Config, reference string) (*Manifest, error) {
+	return (*content.Config)(cnf).HeadManifest(reference)
+}
+
+// GetBundle get bundle.json of cnab by reference as canonical json,
+// blob is checked by digest before encoding
+
+func GetBundle(cnf *Config, reference string) ([]byte, error) {
+	blob, err := (*content.Config)(cnf).GetBundle(reference)
+	if err != nil {
+		return nil, err
+	}
+	return content.CanonicalJSON(blob)
+}
+
+// BundleField pull out value of bundle.json by dot separated path,
+// strings are returned without quotes, other values as canonical json
+
+func BundleField(bundle []byte, path string) ([]byte, error) {
+	return content.JSONField(bundle, path)
+}
+
+// Open get root manifest by reference, it must be cnab index
+
+func Open(cnf *Config, reference string) (*Project, error) {
+	session, regres, err := (*content.Config)(cnf).OpenSession(reference)
+	if err != nil {
+		return nil, err
+	}
+	if regres.Media != client.MediaTypeOciIndex {
+		cnf.Logger.Debug(fmt.Sprintf("unexpected content %+v", regres.Content))
+		return nil, fmt.Errorf("%w: unexpected media type %+v", ErrNotCnabIndex, regres.Media)
+	}
+	// add first index
+	if err := session.AddCnab(regres, session.Client.Tag); err != nil {
+		return nil, fmt.Errorf("can't create first index, %w", err)
+	}
+	return &Project{session: session}, nil
+}
+
+// OpenRepository open repository without tag and digest, e.g. for prune,
+// project is empty until Inspect
+
+func OpenRepository(cnf *Config, repository string) (*Project, error) {
+	session, err := (*content.Config)(cnf).OpenRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+	return &Project{session: session}, nil
+}
+
+// Inspect walk all tags of project repository and link items of cnab indexes.
+// If some tags were not fetched, graph is returned with ErrPartial
+
+func (p *Project) Inspect() (*Graph, error) {
+	err := p.session.InspectCnab()
+	if err != nil && !errors.Is(err, ErrPartial) {
+		return nil, err
+	}
+	return p.Graph(), err
+}
+
+// Graph return current state of project graph
+
+func (p *Project) Graph() *Graph {
+	project := p.session.Project
+	graph := &Graph{
+		Reference:  p.session.Client.Reference,
+		Scheme:     project.Scheme,
+		Registry:   project.Registry,
+		Repository: project.Repository,
+		Items:      append([]*Item(nil), project.ProjectList...),
+		ByTag:      make(map[string]*Item, len(project.ItemByTag)),
+		Report:     p.session.Report(),
+	}
+	// untagged items are listed in Items only
+	for _, tag := range p.session.SortedTags() {
+		graph.Tags = append(graph.Tags, tag)
+		graph.ByTag[tag] = project.ItemByTag[tag]
+	}
+	return graph
+}
+
+// PlanDelete collect items of inspected project for deletion, project of
+// partial inspect is refused with ErrPartial
+
+func (p *Project) PlanDelete() (*DeletePlan, error) {
+	return p.session.PlanDelete()
+}
+
+// PlanPrune apply retention policy of config to inspected project, plan is
+// executed with Delete(plan.Delete). Project of partial inspect is refused
+// with ErrPartial
+
+func (p *Project) PlanPrune() (*PrunePlan, error) {
+	return p.session.PlanPrune()
+}
+
+// Delete execute plan, error is returned if any item was not deleted:
+// ErrPartial if some items were deleted, otherwise the first failure.
+// In dry run mode nothing is requested and outcome is empty
+
+func (p *Project) Delete(plan *DeletePlan) (*DeleteOutcome, error) {
+	outcome := &DeleteOutcome{}
+	if plan == nil {
+		return outcome, nil
+	}
+	outcome.Results = p.session.ExecuteDelete(plan.Entries)
+	for _, result := range outcome.Results {
+		if result.Deleted {
+			outcome.Deleted++
+		} else {
+			outcome.Failed++
+		}
+	}
+	return outcome, outcomeError(outcome.Deleted, outcome.Failed, func(i int) error {
+		return outcome.Results[i].Err
+	})
+}
+
+// outcomeError return ErrPartial if some items were done and others failed,
+// otherwise the first failure
+
+func outcomeError(done int, failed int, errAt func(i int) error) error {
+	if failed == 0 {
+		return nil
+	}
+	if done != 0 {
+		return fmt.Errorf("%w: %d of %d items were not deleted", ErrPartial, failed, done+failed)
+	}
+	// nothing was deleted, first failure explains the reason
+	for i := 0; i < done+failed; i++ {
+		if err := errAt(i); err != nil {
+			return fmt.Errorf("nothing was deleted, %w", err)
+		}
+	}
+	return nil
+}
+
+// untagError summarize untag results like Delete
+
+func untagError(results []UntagResult) error {
+	untagged := 0
+	for _, result := range results {
+		if result.Untagged {
+			untagged++
+		}
+	}
+	return outcomeError(untagged, len(results)-untagged, func(i int) error {
+		return results[i].Err
+	})
+}
+
+// Untag remove tag of reference from repository, manifest is kept. If registry
+// rejects tag deletion, manifest is deleted by digest when no other tag points to it
+
+func Untag(cnf *Config, reference string) ([]UntagResult, error) {
+	session, err := (*content.Config)(cnf).RepositorySession(reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(session.Client.Tag) == 0 {
+		return nil, errors.New(fmt.Sprintf("reference %s has no tag to delete", reference))
+	}
+	results := session.Untag([]string{session.Client.Tag})
+	return results, untagError(results)
+}
+
+// PlanUntag return tags of delete targets - the tag of reference and other tags
+// of the same manifest, or tags of every cnab with AllBundles
+
+func (p *Project) PlanUntag() ([]string, error) {
+	return p.session.TargetTags()
+}
+
+// Untag remove tags of inspected project, see Untag
+
+func (p *Project) Untag(tags []string) ([]UntagResult, error) {
+	results := p.session.Untag(tags)
+	return results, untagError(results)
+}
+
+// PurgeEmptyFolders remove empty parent folders via Artifactory API, if purge is enabled,
+// the first failure stops purge and is returned
+
+func (p *Project) PurgeEmptyFolders() error {
+	return p.session.PurgeEmptyFolders()
+}
@@ -0,0 +1,237 @@
+package cnab
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/logging"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRegistry отдаёт cnab-индекс v1 с компонентом config и принимает DELETE
+type testRegistry struct {
+	mu        sync.Mutex
+	manifests map[string]string
+	media     map[string]string
+	deleted   []string
+	denied    map[string]bool // digests refused on delete
+}
+
+func newTestRegistry(t *testing.T) (*testRegistry, *httptest.Server) {
+	t.Helper()
+	configManifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"sha256:config"}}`
+	configDigest, _ := client.ComputeDigest(client.DigestSha256, []byte(configManifest))
+	index := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"` + configDigest +
+		`","mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"io.cnab.manifest.type":"config"}}]}`
+
+	reg := &testRegistry{
+		manifests: map[string]string{"v1": index, configDigest: configManifest},
+		media:     map[string]string{"v1": client.MediaTypeOciIndex, configDigest: client.MediaTypeOciManifest},
+		denied:    map[string]bool{},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list/") {
+			tagsList, _ := json.Marshal(map[string]interface{}{"name": "repo/cnab", "tags": []string{"v1"}})
+			w.Write(tagsList)
+			return
+		}
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		if r.Method == "DELETE" {
+			if reg.denied[reference] {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":[{"code":"DENIED"}]}`))
+				return
+			}
+			reg.mu.Lock()
+			reg.deleted = append(reg.deleted, reference)
+			reg.mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		content, ok := reg.manifests[reference]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", reg.media[reference])
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	return reg, server
+}
+
+func newTestConfig() *Config {
+	cnf := DefaultConfig()
+	cnf.Scheme = "http"
+	cnf.Retries = 0
+	return cnf
+}
+
+// TestProject_InspectAndDelete проверяет полный цикл через библиотечный API
+func TestProject_InspectAndDelete(t *testing.T) {
+	reg, server := newTestRegistry(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	project, err := Open(newTestConfig(), host+"/repo/cnab:v1")
+	if err != nil {
+		t.Fatalf("Open should not return error, got: %v", err)
+	}
+	graph, err := project.Inspect()
+	if err != nil {
+		t.Fatalf("Inspect should not return error, got: %v", err)
+	}
+	if graph.Registry != host || graph.Repository != "repo/cnab" {
+		t.Errorf("graph root = %s/%s", graph.Registry, graph.Repository)
+	}
+	// компонент без тега есть только в Items
+	if len(graph.Items) != 2 || len(graph.Tags) != 1 || graph.Tags[0] != "v1" || graph.ByTag["v1"] == nil {
+		t.Fatalf("graph = %+v, want cnab index and config", graph)
+	}
+	if _, ok := graph.ByTag[""]; ok {
+		t.Errorf("ByTag should not contain empty tag, got %+v", graph.ByTag)
+	}
+	if len(graph.Report.Shortlist) != 1 || graph.Report.Shortlist[0].Tag != "v1" || graph.Report.Shortlist[0].Links != 1 {
+		t.Errorf("report = %+v", graph.Report)
+	}
+
+	plan, err := project.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	if len(plan.Entries) != 2 || plan.Entries[1].Digest != graph.ByTag["v1"].Digest {
+		t.Fatalf("plan = %+v, want config then cnab index", plan.Entries)
+	}
+
+	outcome, err := project.Delete(plan)
+	if err != nil {
+		t.Fatalf("Delete should not return error, got: %v", err)
+	}
+	if outcome.Deleted != 2 || outcome.Failed != 0 || len(reg.deleted) != 2 {
+		t.Errorf("outcome = %+v, registry deletes %v", outcome, reg.deleted)
+	}
+}
+
+// TestProject_DryRun проверяет, что в режиме dry-run запросы DELETE не выполняются
+func TestProject_DryRun(t *testing.T) {
+	reg, server := newTestRegistry(t)
+	cnf := newTestConfig()
+	cnf.DryRun = true
+
+	project, err := Open(cnf, strings.TrimPrefix(server.URL, "http://")+"/repo/cnab:v1")
+	if err != nil {
+		t.Fatalf("Open should not return error, got: %v", err)
+	}
+	if _, err := project.Inspect(); err != nil {
+		t.Fatalf("Inspect should not return error, got: %v", err)
+	}
+	plan, _ := project.PlanDelete()
+	outcome, err := project.Delete(plan)
+	if err != nil || len(outcome.Results) != 0 || len(reg.deleted) != 0 {
+		t.Errorf("dry run outcome = %+v, err %v, registry deletes %v", outcome, err, reg.deleted)
+	}
+}
+
+// TestProject_DeleteFailures проверяет классификацию частичного и полного отказа удаления
+func TestProject_DeleteFailures(t *testing.T) {
+	reg, server := newTestRegistry(t)
+
+	project, err := Open(newTestConfig(), strings.TrimPrefix(server.URL, "http://")+"/repo/cnab:v1")
+	if err != nil {
+		t.Fatalf("Open should not return error, got: %v", err)
+	}
+	graph, err := project.Inspect()
+	if err != nil {
+		t.Fatalf("Inspect should not return error, got: %v", err)
+	}
+	plan, _ := project.PlanDelete()
+
+	// индекс удалить нельзя, компонент удаляется
+	reg.denied[graph.ByTag["v1"].Digest] = true
+	outcome, err := project.Delete(plan)
+	if !errors.Is(err, ErrPartial) || outcome.Deleted != 1 || outcome.Failed != 1 {
+		t.Errorf("Delete = %+v, %v; want ErrPartial with 1 deleted and 1 failed", outcome, err)
+	}
+
+	// ничего не удалено - причина берётся из первого отказа
+	for _, entry := range plan.Entries {
+		reg.denied[entry.Digest] = true
+	}
+	if _, err := project.Delete(plan); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Delete error = %v, want ErrUnauthorized", err)
+	}
+}
+
+// TestGraph_Check проверяет обнаружение потерянных ссылок
+func TestGraph_Check(t *testing.T) {
+	v1, v2, platforms := &Item{Tag: "v1"}, &Item{Tag: "v2"}, &Item{}
+	graph := &Graph{Items: []*Item{v1, v2, platforms}, ByTag: map[string]*Item{"v1": v1, "v2": v2}}
+	if err := graph.Check(); err != nil {
+		t.Errorf("Check should not return error, got: %v", err)
+	}
+	v2.Lost = 2
+	// потерянные ссылки индекса образа без тега тоже считаются
+	platforms.Lost = 1
+	if graph.Lost() != 3 {
+		t.Errorf("Lost() = %d, want 3", graph.Lost())
+	}
+	if err := graph.Check(); !errors.Is(err, ErrDanglingLinks) {
+		t.Errorf("Check error = %v, want ErrDanglingLinks", err)
+	}
+}
+
+// TestOpen_NotCnabIndex проверяет отказ, если корневой манифест не является cnab-индексом
+func TestOpen_NotCnabIndex(t *testing.T) {
+	reg, server := newTestRegistry(t)
+	reg.media["v1"] = client.MediaTypeOciManifest
+
+	_, err := Open(newTestConfig(), strings.TrimPrefix(server.URL, "http://")+"/repo/cnab:v1")
+	if !errors.Is(err, ErrNotCnabIndex) {
+		t.Errorf("Open error = %v, want media type error", err)
+	}
+}
+
+// TestLibrary_Quiet проверяет, что по умолчанию библиотека не пишет логи
+func TestLibrary_Quiet(t *testing.T) {
+	cnf := DefaultConfig()
+	if cnf.Logger.Verbosity() != logging.LogQuietLevel {
+		t.Errorf("default verbosity = %d, want %d", cnf.Logger.Verbosity(), logging.LogQuietLevel)
+	}
+	// каждая конфигурация получает свой логгер
+	if cnf.Logger == nil || cnf.Logger == DefaultConfig().Logger {
+		t.Error("DefaultConfig should make own logger")
+	}
+}
+
+// TestOpenRepository_Prune проверяет удаление cnab, не покрытых политикой хранения
+func TestOpenRepository_Prune(t *testing.T) {
+	reg, server := newTestRegistry(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+	cnf := newTestConfig()
+	cnf.Prune.Allowlist = []string{"stable"}
+
+	if _, err := OpenRepository(cnf, host+"/repo/cnab:v1"); err == nil {
+		t.Errorf("OpenRepository should reject reference with tag")
+	}
+	project, err := OpenRepository(cnf, host+"/repo/cnab")
+	if err != nil {
+		t.Fatalf("OpenRepository should not return error, got: %v", err)
+	}
+	if _, err := project.Inspect(); err != nil {
+		t.Fatalf("Inspect should not return error, got: %v", err)
+	}
+	plan, err := project.PlanPrune()
+	if err != nil {
+		t.Fatalf("PlanPrune should not return error, got: %v", err)
+	}
+	if len(plan.Decisions) != 1 || plan.Decisions[0].Keep || len(plan.Delete.Entries) != 2 {
+		t.Fatalf("plan = %+v, want v1 with config pruned", plan)
+	}
+	outcome, err := project.Delete(plan.Delete)
+	if err != nil || outcome.Deleted != 2 || len(reg.deleted) != 2 {
+		t.Errorf("outcome = %+v, err %v, registry deletes %v", outcome, err, reg.deleted)
+	}
+}
@@ -7,6 +7,7 @@ package config
 import (
 	"cnabtool/pkg/data"
 	"cnabtool/pkg/logging"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -17,42 +18,68 @@ import (
 )
 
 const (
-	ConfigFileName         = "config"
-	ConfigFileExt          = "yaml"
-	ConfigFileDir          = "cnabtool"
-	ConfigEnvPrefix        = "CNAB"
-	ConfigDefaultVerbosity = logging.LogNormalLevel
-	ConfigDefaultTimeout   = 10000
-	ConfigDefaultClient    = "curl/7.79.1"
-	ConfigDefaultScheme    = "https"
+	ConfigFileName            = "config"
+	ConfigFileExt             = "yaml"
+	ConfigFileDir             = "cnabtool"
+	ConfigEnvPrefix           = "CNAB"
+	ConfigDefaultVerbosity    = logging.LogNormalLevel
+	ConfigDefaultTimeout      = 10000
+	ConfigDefaultClient       = "curl/7.79.1"
+	ConfigDefaultScheme       = "https"
+	ConfigDefaultCertsDir     = "/etc/docker/certs.d"
+	ConfigDefaultRetries      = 3
+	ConfigDefaultRetryWait    = 500
+	ConfigDefaultManifestSize = 4 << 20 // bytes
+	ConfigDefaultConcurrency  = 4
 )
 
 type Config data.Config
 
+// flags named not like their settings
+var flagSettings = map[string]string{
+	"url":      "timeout",
+	"repo-key": "repokey",
+}
+
+// SettingName return config setting of command line flag
+
+func SettingName(flag string) string {
+	if setting, ok := flagSettings[flag]; ok {
+		return setting
+	}
+	return flag
+}
+
 // make new default config
 
 func New() *Config {
-	if data.Gc == nil {
-		cnf := &Config{}
-		cnf.Verbosity = ConfigDefaultVerbosity // set default
-		cnf.Timeout = ConfigDefaultTimeout
-		cnf.Client = ConfigDefaultClient
-		cnf.Unsecure = false
-		cnf.Raw = false
-		cnf.Scheme = ConfigDefaultScheme
-		data.Gc = (*data.Config)(cnf)
-	}
-	return (*Config)(data.Gc)
+	cnf := &Config{}
+	cnf.Verbosity = ConfigDefaultVerbosity // set default
+	cnf.Timeout = ConfigDefaultTimeout
+	cnf.Client = ConfigDefaultClient
+	cnf.Unsecure = false
+	cnf.Raw = false
+	cnf.Scheme = ConfigDefaultScheme
+	cnf.CertsDir = ConfigDefaultCertsDir
+	cnf.Retries = ConfigDefaultRetries
+	cnf.RetryWait = ConfigDefaultRetryWait
+	cnf.MaxManifestSize = ConfigDefaultManifestSize
+	cnf.Concurrency = ConfigDefaultConcurrency
+	cnf.Logger = logging.New(logging.LogQuietLevel) // quiet until verbosity is set
+	return cnf
 }
 
 // Initial config from file, environment and etc.
 
 func (cnf *Config) InitConfig(cmd *cobra.Command) error {
 
+	// flag value until config file is read
+	cnf.Logger.SetVerbosity(cnf.Verbosity)
+
 	// try apply custom config
 	customconfig := cmd.Flags().Lookup("config").Value.String()
 	if len(customconfig) != 0 {
-		logging.Info("use custom config " + customconfig)
+		cnf.Logger.Info("use custom config " + customconfig)
 		file_extension := filepath.Ext(customconfig)
 		viper.SetConfigName(strings.TrimSuffix(filepath.Base(customconfig), file_extension))
 		viper.SetConfigType(strings.TrimPrefix(file_extension, "."))
@@ -70,11 +97,13 @@ func (cnf *Config) InitConfig(cmd *cobra.Command) error {
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			// Config file was found but another error was produced
-			logging.Error(fmt.Sprintf("fatal error config file: %s", err.Error()))
+			cnf.Logger.Error(fmt.Sprintf("fatal error config file: %s", err.Error()))
 			return err
 		} else {
 			if len(customconfig) != 0 {
-				logging.Fatal("can not found custom config file " + customconfig)
+				errLine := "can not found custom config file " + customconfig
+				cnf.Logger.Error(errLine)
+				return errors.New(errLine)
 			}
 		}
 	}
@@ -86,10 +115,25 @@ func (cnf *Config) InitConfig(cmd *cobra.Command) error {
 
 	// fetch root level values
 	if err := viper.Unmarshal(cnf); err != nil {
-		logging.Error(fmt.Sprintf("unable to decode into config struct, %s", err.Error()))
+		cnf.Logger.Error(fmt.Sprintf("unable to decode into config struct, %s", err.Error()))
+		return err
+	}
+
+	// registry hosts contain dots, which viper takes as key delimiter in Unmarshal,
+	// so decode profiles from the raw map
+	cnf.Registries = nil
+	if err := viper.UnmarshalKey("registries", &cnf.Registries); err != nil {
+		cnf.Logger.Error(fmt.Sprintf("unable to decode registries profiles, %s", err.Error()))
 		return err
 	}
 
+	// remember flags of command line before config values are copied into flags,
+	// copying marks flags as changed
+	cnf.Explicit = make(map[string]bool)
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		cnf.Explicit[SettingName(f.Name)] = true
+	})
+
 	cmd.Flags().VisitAll(func(f *pflag.Flag) {
 		configName := f.Name
 
@@ -99,5 +143,8 @@ func (cnf *Config) InitConfig(cmd *cobra.Command) error {
 		}
 	})
 
+	// log level is known only now
+	cnf.Logger.SetVerbosity(cnf.Verbosity)
+
 	return nil
 }
@@ -1,7 +1,6 @@
 package config
 
 import (
-	"cnabtool/pkg/data"
 	"cnabtool/pkg/logging"
 	"os"
 	"path/filepath"
@@ -41,9 +40,6 @@ func TestConfigConstants(t *testing.T) {
 
 // TestNew_Defaults проверяет значения по умолчанию при создании нового конфига
 func TestNew_Defaults(t *testing.T) {
-	// Очищаем глобальный конфиг перед тестом
-	data.Gc = nil
-
 	cfg := New()
 
 	if cfg.Verbosity != ConfigDefaultVerbosity {
@@ -65,58 +61,42 @@ func TestNew_Defaults(t *testing.T) {
 		t.Errorf("New().Scheme = %q, want %q", cfg.Scheme, ConfigDefaultScheme)
 	}
 
-	// Проверяем, что data.Gc установлен
-	if data.Gc == nil {
-		t.Error("New() should set data.Gc")
-	}
-	if data.Gc != (*data.Config)(cfg) {
-		t.Error("data.Gc should point to the same Config as returned by New()")
-	}
 }
 
-// TestNew_Reuse проверяет, что повторный вызов New() не пересоздаёт конфиг
-func TestNew_Reuse(t *testing.T) {
-	// Очищаем и создаём конфиг
-	data.Gc = nil
+// TestNew_Independent проверяет, что каждый вызов New() создаёт отдельный конфиг
+func TestNew_Independent(t *testing.T) {
 	cfg1 := New()
 	cfg1.Verbosity = 99
 
-	// Повторный вызов должен вернуть тот же конфиг
 	cfg2 := New()
 
-	if cfg1 != cfg2 {
-		t.Error("New() should return the same Config instance (singleton)")
+	if cfg1 == cfg2 {
+		t.Error("New() should return a new Config instance")
 	}
-	if cfg2.Verbosity != 99 {
-		t.Errorf("Reuse: Verbosity = %d, want 99 (unchanged)", cfg2.Verbosity)
+	if cfg2.Verbosity != ConfigDefaultVerbosity {
+		t.Errorf("Independent: Verbosity = %d, want %d", cfg2.Verbosity, ConfigDefaultVerbosity)
 	}
 }
 
 // TestNew_Reset проверяет создание нового конфига после очистки
 func TestNew_Reset(t *testing.T) {
 	// Создаём и модифицируем
-	data.Gc = nil
 	cfg1 := New()
 	cfg1.Verbosity = 42
 
-	// Очищаем и создаём новый
-	data.Gc = nil
+	// Создаём новый
 	cfg2 := New()
 
 	if cfg2.Verbosity != ConfigDefaultVerbosity {
 		t.Errorf("After reset: Verbosity = %d, want %d", cfg2.Verbosity, ConfigDefaultVerbosity)
 	}
 	if cfg2.Verbosity == cfg1.Verbosity {
-		t.Error("After reset, new config should have default values")
+		t.Error("New config should have default values")
 	}
 }
 
 // TestInitConfig_NoFile_NoEnv проверяет конфиг без файла и переменных окружения
 func TestInitConfig_NoFile_NoEnv(t *testing.T) {
-	// Очищаем глобальный конфиг
-	data.Gc = nil
-	data.Sensitives = nil
-
 	// Создаём временную директорию (пустую, без config.yaml)
 	tmpDir, err := os.MkdirTemp("", "cnabtool-test-*")
 	if err != nil {
@@ -155,6 +135,10 @@ func TestInitConfig_NoFile_NoEnv(t *testing.T) {
 	if cfg.Scheme != ConfigDefaultScheme {
 		t.Errorf("InitConfig Scheme = %q, want %q (default)", cfg.Scheme, ConfigDefaultScheme)
 	}
+	// уровень логирования передаётся логгеру
+	if cfg.Logger.Verbosity() != cfg.Verbosity {
+		t.Errorf("cfg.Logger.Verbosity() = %d, want %d", cfg.Logger.Verbosity(), cfg.Verbosity)
+	}
 }
 
 // TestInitConfig_WithEnvVars проверяет загрузку простых переменных окружения
@@ -165,8 +149,6 @@ func TestInitConfig_NoFile_NoEnv(t *testing.T) {
 func TestInitConfig_WithEnvVars(t *testing.T) {
 	// Очищаем глобальные состояния
 	viper.Reset()
-	data.Gc = nil
-	data.Sensitives = nil
 
 	// Создаём временную директорию
 	tmpDir, err := os.MkdirTemp("", "cnabtool-test-*")
@@ -223,8 +205,6 @@ func TestInitConfig_WithEnvVars(t *testing.T) {
 func TestInitConfig_WithConfigFile(t *testing.T) {
 	// Очищаем глобальные состояния
 	viper.Reset()
-	data.Gc = nil
-	data.Sensitives = nil
 
 	// Создаём временную директорию
 	tmpDir, err := os.MkdirTemp("", "cnabtool-test-*")
@@ -290,10 +270,6 @@ scheme: "http"
 
 // TestInitConfig_FlagOverridesEnv проверяет приоритет флагов над env
 func TestInitConfig_FlagOverridesEnv(t *testing.T) {
-	// Очищаем глобальный конфиг
-	data.Gc = nil
-	data.Sensitives = nil
-
 	// Создаём временную директорию
 	tmpDir, err := os.MkdirTemp("", "cnabtool-test-*")
 	if err != nil {
@@ -334,10 +310,6 @@ func TestInitConfig_FlagOverridesEnv(t *testing.T) {
 
 // TestInitConfig_CustomConfigPath проверяет загрузку из пользовательского пути
 func TestInitConfig_CustomConfigPath(t *testing.T) {
-	// Очищаем глобальный конфиг
-	data.Gc = nil
-	data.Sensitives = nil
-
 	// Создаём временную директорию
 	tmpDir, err := os.MkdirTemp("", "cnabtool-test-*")
 	if err != nil {
@@ -395,8 +367,6 @@ verbosity: 4
 func TestInitConfig_EnvOverridesFile(t *testing.T) {
 	// Очищаем глобальные состояния
 	viper.Reset()
-	data.Gc = nil
-	data.Sensitives = nil
 
 	// Создаём временную директорию
 	tmpDir, err := os.MkdirTemp("", "cnabtool-test-*")
@@ -461,7 +431,6 @@ verbosity: 1
 
 // TestConfig_Credentials проверяет структуру Credentials
 func TestConfig_Credentials(t *testing.T) {
-	data.Gc = nil
 	cfg := New()
 
 	// Проверяем, что Credentials инициализированы
@@ -484,7 +453,6 @@ func TestConfig_Credentials(t *testing.T) {
 
 // TestConfig_Fields проверяет все поля Config
 func TestConfig_Fields(t *testing.T) {
-	data.Gc = nil
 	cfg := New()
 
 	// Устанавливаем все поля
@@ -524,24 +492,8 @@ func TestConfig_Fields(t *testing.T) {
 	}
 }
 
-// TestConfig_ErrorField проверяет поле Error
-func TestConfig_ErrorField(t *testing.T) {
-	data.Gc = nil
-	cfg := New()
-
-	if cfg.Error != 0 {
-		t.Errorf("Config.Error initial = %d, want 0", cfg.Error)
-	}
-
-	cfg.Error = 5
-	if cfg.Error != 5 {
-		t.Errorf("Config.Error after set = %d, want 5", cfg.Error)
-	}
-}
-
 // TestConfig_RepoKey_Purge проверяет поля RepoKey и Purge
 func TestConfig_RepoKey_Purge(t *testing.T) {
-	data.Gc = nil
 	cfg := New()
 
 	if cfg.RepoKey != "" {
@@ -561,3 +513,157 @@ func TestConfig_RepoKey_Purge(t *testing.T) {
 		t.Errorf("Config.Purge after set = %v, want true", cfg.Purge)
 	}
 }
+
+// TestInitConfig_RegistriesProfiles проверяет чтение профилей registries с точками в ключах
+func TestInitConfig_RegistriesProfiles(t *testing.T) {
+	viper.Reset()
+
+	tmpDir := t.TempDir()
+	configContent := `
+credentials:
+  username: "globaluser"
+  password: "globalpass"
+registries:
+  harbor.example.com:
+    credentials:
+      username: "harboruser"
+      password: "harborpass"
+    timeout: 5000
+  "*.artifactory.example.com":
+    scheme: "https"
+    repokey: "cnab-local"
+    client: "cnabtool-ci"
+  "localhost:5000":
+    scheme: "http"
+    unsecure: true
+`
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Cannot write config file: %v", err)
+	}
+
+	cmd := &cobra.Command{
+		Use: "test",
+	}
+	cmd.Flags().String("config", configPath, "")
+	cmd.Flags().Int("verbosity", 0, "")
+
+	cfg := New()
+	if err := cfg.InitConfig(cmd); err != nil {
+		t.Fatalf("InitConfig should not return error, got: %v", err)
+	}
+
+	if len(cfg.Registries) != 3 {
+		t.Fatalf("Registries = %+v, want 3 profiles", cfg.Registries)
+	}
+	harbor, ok := cfg.Registries["harbor.example.com"]
+	if !ok {
+		t.Fatalf("Registries should contain harbor.example.com, got %+v", cfg.Registries)
+	}
+	if harbor.Credentials.Username != "harboruser" || harbor.Credentials.Password != "harborpass" {
+		t.Errorf("harbor credentials = %+v", harbor.Credentials)
+	}
+	if harbor.Timeout != 5000 {
+		t.Errorf("harbor timeout = %d, want 5000", harbor.Timeout)
+	}
+	art := cfg.Registries["*.artifactory.example.com"]
+	if art.RepoKey != "cnab-local" || art.Client != "cnabtool-ci" || art.Scheme != "https" {
+		t.Errorf("artifactory profile = %+v", art)
+	}
+	local := cfg.Registries["localhost:5000"]
+	if local.Scheme != "http" || !local.Unsecure {
+		t.Errorf("localhost profile = %+v", local)
+	}
+	if cfg.Credentials.Username != "globaluser" {
+		t.Errorf("global credentials = %+v", cfg.Credentials)
+	}
+}
+
+// TestInitConfig_MissingCustomConfig проверяет, что отсутствующий явный конфиг возвращается ошибкой, а не завершает процесс
+func TestInitConfig_MissingCustomConfig(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	cmd := &cobra.Command{
+		Use: "test",
+	}
+	cmd.Flags().String("config", "", "")
+	cmd.Flags().Set("config", filepath.Join(t.TempDir(), "absent.yaml"))
+
+	cfg := New()
+	if err := cfg.InitConfig(cmd); err == nil {
+		t.Error("InitConfig should return error when custom config file is missing")
+	}
+}
+
+// TestInitConfig_PrunePolicy проверяет чтение политики хранения из файла
+func TestInitConfig_PrunePolicy(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	configContent := `
+prune:
+  keeplast: 5
+  keepdays: 30
+  keeptags: ["release-.*"]
+  keepsemver: [">=2.0.0 <3.0.0", "^1.4"]
+  allowlist: ["stable"]
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Cannot write config file: %v", err)
+	}
+
+	cmd := &cobra.Command{
+		Use: "test",
+	}
+	cmd.Flags().String("config", configPath, "")
+	cmd.Flags().Int("verbosity", 0, "")
+
+	cfg := New()
+	if err := cfg.InitConfig(cmd); err != nil {
+		t.Fatalf("InitConfig should not return error, got: %v", err)
+	}
+	prune := cfg.Prune
+	if prune.KeepLast != 5 || prune.KeepDays != 30 {
+		t.Errorf("prune = %+v, want keeplast 5 and keepdays 30", prune)
+	}
+	if len(prune.KeepTags) != 1 || prune.KeepTags[0] != "release-.*" || len(prune.KeepSemver) != 2 ||
+		prune.KeepSemver[1] != "^1.4" || len(prune.Allowlist) != 1 || prune.Allowlist[0] != "stable" {
+		t.Errorf("prune = %+v", prune)
+	}
+}
+
+// TestInitConfig_ExplicitFlags проверяет, что явными считаются только флаги командной строки
+func TestInitConfig_ExplicitFlags(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("username: fileuser\n"), 0644); err != nil {
+		t.Fatalf("Cannot write config file: %v", err)
+	}
+
+	cmd := &cobra.Command{
+		Use: "test",
+	}
+	cmd.Flags().String("config", configPath, "")
+	cmd.Flags().Int("verbosity", 0, "")
+	cmd.Flags().String("username", "", "")
+	cmd.Flags().String("repo-key", "", "")
+	cmd.Flags().Int("url", 10000, "")
+	_ = cmd.Flags().Set("repo-key", "flag-key")
+	_ = cmd.Flags().Set("url", "5000")
+
+	cfg := New()
+	if err := cfg.InitConfig(cmd); err != nil {
+		t.Fatalf("InitConfig should not return error, got: %v", err)
+	}
+	if !cfg.Explicit["repokey"] || !cfg.Explicit["timeout"] {
+		t.Errorf("Explicit = %v, want repokey and timeout", cfg.Explicit)
+	}
+	// значение из файла копируется во флаг, но не делает его явным
+	if cfg.Explicit["username"] || cmd.Flags().Lookup("username").Value.String() != "fileuser" {
+		t.Errorf("Explicit = %v, username flag = %q", cfg.Explicit, cmd.Flags().Lookup("username").Value.String())
+	}
+}
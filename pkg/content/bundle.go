@@ -0,0 +1,217 @@
+package content
+
+import (
+	"bytes"
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/data"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+// cnab index annotation of config manifest
+// https://github.com/cnabio/cnab-spec/blob/main/201-representing-CNAB-in-OCI.md
+
+const AnnotationConfig = "config"
+
+var ErrFieldNotFound = errors.New("field not found")
+
+// ConfigManifest return digest of config manifest listed in cnab index
+
+func ConfigManifest(index string) (string, error) {
+	digest := ""
+	_, err := jsonparser.ArrayEach([]byte(index), func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		annotation, _ := jsonparser.GetString(value, "annotations", "io.cnab.manifest.type")
+		if annotation == AnnotationConfig && len(digest) == 0 {
+			digest, _ = jsonparser.GetString(value, "digest")
+		}
+	}, "manifests")
+	if err != nil {
+		return "", fmt.Errorf("%w: json isn't contain manifests array, %+v", ErrNotCnabIndex, err.Error())
+	}
+	if len(digest) == 0 {
+		return "", fmt.Errorf("%w: index has no manifest with io.cnab.manifest.type=config", ErrNotCnabIndex)
+	}
+	return digest, nil
+}
+
+// ConfigBlob return digest of bundle.json referenced by config manifest
+
+func ConfigBlob(manifest string) (string, error) {
+	media, err := jsonparser.GetString([]byte(manifest), "config", "mediaType")
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("config manifest has no config descriptor, %+v", err.Error()))
+	}
+	if media != client.MediaTypeCnabConfig && media != client.MediaTypeCnabBConfig {
+		return "", errors.New(fmt.Sprintf("config descriptor has media type %s, must be cnab config", media))
+	}
+	digest, err := jsonparser.GetString([]byte(manifest), "config", "digest")
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("config descriptor has no digest, %+v", err.Error()))
+	}
+	return digest, nil
+}
+
+// DecodeBundle decode bundle.json
+
+func DecodeBundle(content []byte) (*data.Bundle, error) {
+	bundle := &data.Bundle{}
+	if err := json.Unmarshal(content, bundle); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid bundle.json, %+v", err.Error()))
+	}
+	return bundle, nil
+}
+
+// GetBundle get bundle.json of cnab by reference, blob is checked by digest
+
+func (cc *Config) GetBundle(reference string) ([]byte, error) {
+	regres, cl, err := cc.GetManifest(reference)
+	if err != nil {
+		return nil, err
+	}
+	if regres.Media != client.MediaTypeOciIndex {
+		return nil, fmt.Errorf("%w: unexpected media type %+v", ErrNotCnabIndex, regres.Media)
+	}
+	digest, err := ConfigManifest(regres.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	// config manifest is usually untagged
+	cl.Tag, cl.Digest = "", digest
+	config, err := cl.GetRegIndex()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := ConfigBlob(config.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotCnabIndex, err.Error())
+	}
+	cc.Logger.Debug(fmt.Sprintf("bundle.json of %s is blob %s", reference, blob))
+	return cl.GetBlob(blob)
+}
+
+// CanonicalJSON encode json with sorted keys and without insignificant whitespace
+// http://wiki.laptop.org/go/Canonical_JSON
+
+func CanonicalJSON(content []byte) ([]byte, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber() // keep numbers as is
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid json, %+v", err.Error()))
+	}
+	return canonical(value)
+}
+
+// canonical encode decoded value, maps are encoded with sorted keys
+
+func canonical(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// JSONField pull out value by dot separated path, e.g. images.web.image or invocationImages.0.image.
+// Strings are returned without quotes, other values as canonical json
+
+func JSONField(content []byte, path string) ([]byte, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid json, %+v", err.Error()))
+	}
+	for _, key := range strings.Split(path, client.StringDot) {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+			}
+			value = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+		}
+	}
+	if str, ok := value.(string); ok {
+		return []byte(str), nil
+	}
+	return canonical(value)
+}
+
+// configItem return config manifest of cnab index, if it was fetched
+
+func (s *Session) configItem(cnab *data.RegIndex) *data.RegIndex {
+	for _, link := range cnab.DownLinks {
+		if link.Annotation == AnnotationConfig {
+			return s.Project.ItemByDigest[link.Digest]
+		}
+	}
+	return nil
+}
+
+// ReadBundles fetch and decode bundle.json of every cnab index,
+// return count of bundles, which were not read
+
+func (s *Session) ReadBundles() int {
+	// several cnab indexes may share one config
+	var blobs []string
+	byBlob := make(map[string][]*data.RegIndex)
+	for _, item := range s.rootItems() {
+		if item.Annotation != data.ItemTypeCnab {
+			continue
+		}
+		config := s.configItem(item)
+		if config == nil {
+			s.Config.Logger.Debug(fmt.Sprintf("cnab %s has no config manifest", item.Tag))
+			continue
+		}
+		digest, err := ConfigBlob(config.Content)
+		if err != nil {
+			s.Config.Logger.Debug(fmt.Sprintf("cnab %s: %+v", item.Tag, err.Error()))
+			continue
+		}
+		if _, ok := byBlob[digest]; !ok {
+			blobs = append(blobs, digest)
+		}
+		byBlob[digest] = append(byBlob[digest], item)
+	}
+
+	bundles := make([]*data.Bundle, len(blobs))
+	errs := make([]error, len(blobs))
+	s.forEach(len(blobs), func(cl *client.RegClient, i int) {
+		content, err := cl.GetBlob(blobs[i])
+		if err == nil {
+			bundles[i], err = DecodeBundle(content)
+		}
+		errs[i] = err
+	})
+
+	failed := 0
+	for i, digest := range blobs {
+		if errs[i] != nil {
+			s.Config.Logger.Error(fmt.Sprintf("can't read bundle.json %s, %+v", digest, errs[i].Error()))
+			failed++
+			continue
+		}
+		for _, item := range byBlob[digest] {
+			item.Bundle = bundles[i]
+		}
+	}
+	return failed
+}
@@ -0,0 +1,216 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/data"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testBundle = `{
+	"schemaVersion": "v1.0.0",
+	"name": "helloworld",
+	"version": "0.1.0",
+	"invocationImages": [{"image": "registry.example.com/helloworld-installer:0.1.0", "imageType": "docker", "contentDigest": "sha256:aaa"}],
+	"images": {"web": {"image": "registry.example.com/web:1.0", "imageType": "docker", "size": 1024}},
+	"parameters": {"port": {"definition": "port", "destination": {"env": "PORT"}}},
+	"credentials": {"kubeconfig": {"path": "/root/.kube/config"}},
+	"outputs": {"url": {"definition": "url", "path": "/cnab/app/outputs/url"}},
+	"actions": {"status": {"modifies": false, "stateless": true}},
+	"custom": {"io.example.meta": {"team": "platform"}}
+}`
+
+// TestConfigBlob проверяет разбор config-манифеста cnab
+func TestConfigBlob(t *testing.T) {
+	testcases := []struct {
+		manifest string
+		want     string
+		wantErr  bool
+	}{
+		{manifest: `{"config":{"mediaType":"application/vnd.cnab.config.v1+json","digest":"sha256:abc"}}`, want: "sha256:abc"},
+		{manifest: `{"config":{"mediaType":"application/vnd.cnab.bundle.config.v1+json","digest":"sha256:def"}}`, want: "sha256:def"},
+		{manifest: `{"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:abc"}}`, wantErr: true},
+		{manifest: `{"config":{"mediaType":"application/vnd.cnab.config.v1+json"}}`, wantErr: true},
+		{manifest: `{"schemaVersion":2}`, wantErr: true},
+	}
+	for _, tc := range testcases {
+		got, err := ConfigBlob(tc.manifest)
+		if tc.wantErr != (err != nil) {
+			t.Errorf("ConfigBlob(%s) error = %v, wantErr %v", tc.manifest, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("ConfigBlob(%s) = %q, want %q", tc.manifest, got, tc.want)
+		}
+	}
+}
+
+// TestDecodeBundle проверяет декодирование полей bundle.json
+func TestDecodeBundle(t *testing.T) {
+	bundle, err := DecodeBundle([]byte(testBundle))
+	if err != nil {
+		t.Fatalf("DecodeBundle should not return error, got: %v", err)
+	}
+	if bundle.Name != "helloworld" || bundle.Version != "0.1.0" || bundle.SchemaVersion != "v1.0.0" {
+		t.Errorf("bundle header = %s %s %s", bundle.Name, bundle.Version, bundle.SchemaVersion)
+	}
+	if len(bundle.InvocationImages) != 1 || bundle.InvocationImages[0].ContentDigest != "sha256:aaa" {
+		t.Errorf("InvocationImages = %+v", bundle.InvocationImages)
+	}
+	if bundle.Images["web"].Size != 1024 {
+		t.Errorf("Images = %+v", bundle.Images)
+	}
+	if bundle.Parameters["port"]["definition"] != "port" || bundle.Credentials["kubeconfig"]["path"] != "/root/.kube/config" {
+		t.Errorf("Parameters = %+v, Credentials = %+v", bundle.Parameters, bundle.Credentials)
+	}
+	if bundle.Outputs["url"] == nil || bundle.Actions["status"]["stateless"] != true || bundle.Custom["io.example.meta"] == nil {
+		t.Errorf("Outputs = %+v, Actions = %+v, Custom = %+v", bundle.Outputs, bundle.Actions, bundle.Custom)
+	}
+
+	if _, err := DecodeBundle([]byte(`{"name":`)); err == nil {
+		t.Error("DecodeBundle should reject invalid json")
+	}
+}
+
+// newBundleRegistry создаёт реестр с cnab v1, его config-манифестом и bundle.json
+func newBundleRegistry(t *testing.T, bundle string) *Session {
+	t.Helper()
+	blobDigest := digestOf(bundle)
+	configManifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.cnab.config.v1+json","digest":"` + blobDigest + `","size":1}}`
+	configDigest := digestOf(configManifest)
+	index := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"` + configDigest +
+		`","mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"io.cnab.manifest.type":"config"}}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/tags/list/"):
+			w.Write([]byte(`{"name":"repo/cnab","tags":["v1"]}`))
+		case strings.HasSuffix(r.URL.Path, "/manifests/v1"):
+			w.Header().Set("Content-Type", client.MediaTypeOciIndex)
+			w.Write([]byte(index))
+		case strings.HasSuffix(r.URL.Path, "/manifests/"+configDigest):
+			w.Header().Set("Content-Type", client.MediaTypeOciManifest)
+			w.Write([]byte(configManifest))
+		case strings.HasSuffix(r.URL.Path, "/blobs/"+blobDigest):
+			w.Write([]byte(bundle))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &data.Config{Scheme: "http", Timeout: 10000, Concurrency: 2}
+	cl := client.NewRegClient((*client.Config)(cfg), "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+	return NewSession((*Config)(cfg), cl)
+}
+
+// TestInspectCnab_Bundle проверяет, что bundle.json попадает в граф и отчёт
+func TestInspectCnab_Bundle(t *testing.T) {
+	s := newBundleRegistry(t, testBundle)
+
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
+	bundle := s.Project.ItemByTag["v1"].Bundle
+	if bundle == nil || bundle.Name != "helloworld" {
+		t.Fatalf("cnab bundle = %+v, want helloworld", bundle)
+	}
+
+	report, err := json.Marshal(s.Report())
+	if err != nil {
+		t.Fatalf("report should be marshaled, got: %v", err)
+	}
+	if !strings.Contains(string(report), `"bundle":{"schemaVersion":"v1.0.0","name":"helloworld"`) {
+		t.Errorf("report should contain bundle, got %s", report)
+	}
+}
+
+// TestInspectCnab_BundleUnreadable проверяет, что нечитаемый bundle.json даёт ErrPartial
+func TestInspectCnab_BundleUnreadable(t *testing.T) {
+	s := newBundleRegistry(t, `not json`)
+
+	if err := s.InspectCnab(); !errors.Is(err, ErrPartial) {
+		t.Errorf("InspectCnab error = %v, want ErrPartial", err)
+	}
+	if s.Project.ItemByTag["v1"].Bundle != nil {
+		t.Error("cnab bundle should stay empty")
+	}
+}
+
+// TestConfigManifest проверяет поиск config-манифеста по аннотации
+func TestConfigManifest(t *testing.T) {
+	index := `{"manifests":[
+		{"digest":"sha256:inv","annotations":{"io.cnab.manifest.type":"invocation"}},
+		{"digest":"sha256:cfg","annotations":{"io.cnab.manifest.type":"config"}}]}`
+	if got, err := ConfigManifest(index); err != nil || got != "sha256:cfg" {
+		t.Errorf("ConfigManifest = %q, %v; want sha256:cfg", got, err)
+	}
+	if _, err := ConfigManifest(`{"manifests":[{"digest":"sha256:inv"}]}`); !errors.Is(err, ErrNotCnabIndex) {
+		t.Errorf("ConfigManifest error = %v, want ErrNotCnabIndex", err)
+	}
+	if _, err := ConfigManifest(`{"schemaVersion":2}`); !errors.Is(err, ErrNotCnabIndex) {
+		t.Errorf("ConfigManifest error = %v, want ErrNotCnabIndex", err)
+	}
+}
+
+// TestCanonicalJSON проверяет сортировку ключей, удаление пробелов и сохранение чисел
+func TestCanonicalJSON(t *testing.T) {
+	got, err := CanonicalJSON([]byte(`{ "b": 1.50, "a": {"y": "<x>", "x": [3, 2]} }`))
+	if err != nil {
+		t.Fatalf("CanonicalJSON should not return error, got: %v", err)
+	}
+	want := `{"a":{"x":[3,2],"y":"<x>"},"b":1.50}`
+	if string(got) != want {
+		t.Errorf("CanonicalJSON = %s, want %s", got, want)
+	}
+	if _, err := CanonicalJSON([]byte(`{`)); err == nil {
+		t.Error("CanonicalJSON should reject invalid json")
+	}
+}
+
+// TestJSONField проверяет извлечение поля по пути
+func TestJSONField(t *testing.T) {
+	testcases := []struct {
+		path string
+		want string
+	}{
+		{path: "name", want: "helloworld"},
+		{path: "images.web.image", want: "registry.example.com/web:1.0"},
+		{path: "images.web.size", want: "1024"},
+		{path: "invocationImages.0.imageType", want: "docker"},
+		{path: "actions.status", want: `{"modifies":false,"stateless":true}`},
+	}
+	for _, tc := range testcases {
+		got, err := JSONField([]byte(testBundle), tc.path)
+		if err != nil || string(got) != tc.want {
+			t.Errorf("JSONField(%s) = %s, %v; want %s", tc.path, got, err, tc.want)
+		}
+	}
+	for _, path := range []string{"absent", "invocationImages.1", "invocationImages.x", "name.first"} {
+		if _, err := JSONField([]byte(testBundle), path); !errors.Is(err, ErrFieldNotFound) {
+			t.Errorf("JSONField(%s) error = %v, want ErrFieldNotFound", path, err)
+		}
+	}
+}
+
+// TestGetBundle проверяет получение bundle.json по ссылке на cnab
+func TestGetBundle(t *testing.T) {
+	s := newBundleRegistry(t, testBundle)
+
+	got, err := s.Config.GetBundle(s.Client.Registry + "/repo/cnab:v1")
+	if err != nil {
+		t.Fatalf("GetBundle should not return error, got: %v", err)
+	}
+	if string(got) != testBundle {
+		t.Errorf("GetBundle should return blob as stored, got %s", got)
+	}
+
+	if _, err := s.Config.GetBundle(s.Client.Registry + "/repo/cnab:v2"); !errors.Is(err, client.ErrManifestNotFound) {
+		t.Errorf("GetBundle error = %v, want ErrManifestNotFound", err)
+	}
+}
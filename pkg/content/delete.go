@@ -3,120 +3,331 @@ package content
 import (
 	"cnabtool/pkg/client"
 	"cnabtool/pkg/data"
-	"cnabtool/pkg/logging"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
-func (cc *Config) DeleteCnab(cl *client.RegClient) {
+// ErrSharedTags is target of delete, which has other tags besides the reference one
+
+var ErrSharedTags = errors.New("target has other tags")
+
+// reasons of delete plan
+
+const (
+	ReasonTarget  = "target"           // cnab index of reference
+	ReasonOrphan  = "orphan"           // reachable from targets only
+	ReasonKeptTag = "kept tag"         // reachable from tag, which is not deleted
+	ReasonForeign = "other repository" // image of other repository is never deleted
+)
+
+// item of delete plan, items are deleted by digest in plan order
+
+type DeleteEntry struct {
+	Annotation string   `json:"annotation" yaml:"annotation"`
+	Digest     string   `json:"digest" yaml:"digest"`
+	Media      string   `json:"media,omitempty" yaml:"media,omitempty"`
+	URL        string   `json:"url" yaml:"url"`
+	Reason     string   `json:"reason" yaml:"reason"`
+	Size       int64    `json:"size" yaml:"size"`                               // manifest, its config and layers, bytes
+	Referrers  []string `json:"referrers,omitempty" yaml:"referrers,omitempty"` // digests of items, which link to entry
+	Tags       []string `json:"tags,omitempty" yaml:"tags,omitempty"`           // tags of target, which are removed with it
+}
+
+// item reachable from targets, which is kept
+
+type RetainEntry struct {
+	Annotation string   `json:"annotation" yaml:"annotation"`
+	Digest     string   `json:"digest" yaml:"digest"`
+	Reason     string   `json:"reason" yaml:"reason"`
+	Tags       []string `json:"tags,omitempty" yaml:"tags,omitempty"`             // kept tags, which reach item
+	Repository string   `json:"repository,omitempty" yaml:"repository,omitempty"` // registry/repository of image out of project
+}
+
+// full plan computed before any delete request
+
+type DeletePlan struct {
+	Entries  []DeleteEntry `json:"entries" yaml:"entries"`
+	Retained []RetainEntry `json:"retained" yaml:"retained"`
+}
+
+// outcome of one delete request
+
+type DeleteResult struct {
+	Entry     DeleteEntry `json:"entry" yaml:"entry"`
+	Status    int         `json:"status" yaml:"status"`
+	Deleted   bool        `json:"deleted" yaml:"deleted"`
+	Body      string      `json:"body,omitempty" yaml:"body,omitempty"`
+	Error     string      `json:"error,omitempty" yaml:"error,omitempty"` // text of Err
+	Started   time.Time   `json:"started" yaml:"started"`
+	ElapsedMs int64       `json:"elapsedMs" yaml:"elapsedMs"` // request with reading of error body
+	Err       error       `json:"-" yaml:"-"`
+}
+
+// PlanDelete make mark-and-sweep plan for inspected project: everything reachable
+// from kept tags is marked, everything else reachable from targets is swept.
+// Children go before their parents. Graph of partial inspect is not planned,
+// see checkComplete. Target of reference tag, which has other tags, is refused
+// with ErrSharedTags, unless AllBundles or Force is set
+
+func (s *Session) PlanDelete() (*DeletePlan, error) {
+	if err := s.checkComplete(); err != nil {
+		return nil, err
+	}
 
 	// parse the first reference to get the project metadata
-	if err := cl.ParseReference(); err != nil {
-		logging.Fatal(fmt.Sprintf("can not parse reference %+v", err.Error()))
+	if err := s.Client.ParseReference(); err != nil {
+		errLine := fmt.Sprintf("can not parse reference %+v", err.Error())
+		s.Config.Logger.Error(errLine)
+		return nil, errors.New(errLine)
 	}
 
-	// Collect all items to delete: for every CNAB index, delete its DownLinks first, then the index itself.
-	// This ensures we delete by digest (not by tag), which is critical for untagged manifests
-	// (config, invocation, etc.) that were fetched directly by digest during InspectCnab.
-	type deleteEntry struct {
-		annotation string
-		digest     string
-		url        string
+	targets, err := s.deleteTargets()
+	if err != nil {
+		return nil, err
 	}
+	plan := s.planTargets(targets)
+	if !s.Config.AllBundles && !s.Config.Force && len(s.Client.Tag) != 0 {
+		if err := s.checkSharedTags(plan); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
 
-	var toDelete []deleteEntry
-	deletedDigests := make(map[string]bool) // avoid duplicate deletions
+// checkSharedTags refuse target, which has tags besides the reference tag:
+// delete by digest removes every tag of manifest
 
-	for _, item := range data.ItemByTag {
-		if item.Annotation != data.ItemTypeCnab {
+func (s *Session) checkSharedTags(plan *DeletePlan) error {
+	for _, entry := range plan.Entries {
+		var others []string
+		for _, tag := range entry.Tags {
+			if tag != s.Client.Tag {
+				others = append(others, tag)
+			}
+		}
+		if len(others) != 0 {
+			err := fmt.Errorf("%w: manifest %s has tags %s, they would be deleted too, use --force or --untag-only", ErrSharedTags, entry.Digest, strings.Join(others, ","))
+			s.Config.Logger.Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// checkComplete refuse graph of partial inspect: not fetched tag, bundle or image
+// may keep items, which would be swept otherwise
+
+func (s *Session) checkComplete() error {
+	if s.partial == nil {
+		return nil
+	}
+	err := fmt.Errorf("%w, graph is incomplete and is not planned", s.partial)
+	s.Config.Logger.Error(err.Error())
+	return err
+}
+
+// planTargets make mark-and-sweep plan to delete targets, see PlanDelete
+
+func (s *Session) planTargets(targets []*data.RegIndex) *DeletePlan {
+
+	// mark - every item remembers kept tags, which reach it
+	kept := make(map[string][]string)
+	removed := make(map[string][]string) // tags of targets
+	for _, tag := range s.SortedTags() {
+		item := s.Project.ItemByTag[tag]
+		if isTarget(targets, item) {
+			removed[item.Digest] = append(removed[item.Digest], tag)
 			continue
 		}
+		reached := make(map[string]bool)
+		s.reach(item, reached)
+		for digest := range reached {
+			kept[digest] = append(kept[digest], tag)
+		}
+	}
 
-		// Delete all DownLinks (child components) first
-		for _, link := range item.DownLinks {
-			_, ok := data.ItemByDigest[link.Digest]
-			if !ok {
-				// This link was not found during inspection — skip it
-				continue
-			}
-			// Only delete leaf nodes (referenced by exactly one parent) or all if no uplink info
-			ri := data.ItemByDigest[link.Digest]
-			if len(ri.UpLinks) > 1 {
-				// Referenced by multiple parents — skip to avoid deleting shared components
-				continue
-			}
-			if deletedDigests[link.Digest] {
-				continue
+	// sweep - delete by digest (not by tag), which is critical for untagged manifests
+	// (config, invocation, etc.) that were fetched directly by digest during InspectCnab
+	plan := &DeletePlan{}
+	visited := make(map[string]bool)
+	for _, item := range targets {
+		s.sweep(item, item.Annotation, ReasonTarget, kept, visited, plan)
+	}
+	for i := range plan.Entries {
+		plan.Entries[i].Tags = removed[plan.Entries[i].Digest]
+	}
+
+	for _, entry := range plan.Retained {
+		s.Config.Logger.Message(fmt.Sprintf("Keep %s %s, %s %s%s", entry.Annotation, entry.Digest, entry.Reason, strings.Join(entry.Tags, ","), entry.Repository))
+	}
+	s.Config.Logger.Info(fmt.Sprintf("Items to delete: %d, items to keep: %d", len(plan.Entries), len(plan.Retained)))
+	return plan
+}
+
+// sweep add item to plan after its children, or retain it with reason
+
+func (s *Session) sweep(item *data.RegIndex, annotation string, reason string, kept map[string][]string, visited map[string]bool, plan *DeletePlan) {
+	if visited[item.Digest] {
+		return
+	}
+	visited[item.Digest] = true
+
+	if tags, ok := kept[item.Digest]; ok {
+		plan.Retained = append(plan.Retained, RetainEntry{Annotation: annotation, Digest: item.Digest, Reason: ReasonKeptTag, Tags: tags})
+		return
+	}
+	// images of other repositories are not touched, but they stay in graph
+	if len(item.Repository) != 0 {
+		plan.Retained = append(plan.Retained, RetainEntry{Annotation: annotation, Digest: item.Digest, Reason: ReasonForeign, Repository: item.Repository})
+		return
+	}
+
+	for _, link := range item.DownLinks {
+		child, ok := s.Project.ItemByDigest[link.Digest]
+		if !ok {
+			// This link was not found during inspection — skip it
+			continue
+		}
+		s.sweep(child, link.Annotation, ReasonOrphan, kept, visited, plan)
+	}
+	var referrers []string
+	for _, link := range item.UpLinks {
+		referrers = append(referrers, link.Digest)
+	}
+	plan.Entries = append(plan.Entries, DeleteEntry{
+		Annotation: annotation,
+		Digest:     item.Digest,
+		Media:      item.Media,
+		URL:        s.manifestURL(item.Digest),
+		Reason:     reason,
+		Size:       sum(item.Blobs),
+		Referrers:  referrers,
+	})
+}
+
+// deleteTargets return cnab indexes to delete - the item of session reference,
+// or every cnab index of repository with AllBundles
+
+func (s *Session) deleteTargets() ([]*data.RegIndex, error) {
+	var targets []*data.RegIndex
+	if s.Config.AllBundles {
+		for _, tag := range s.SortedTags() {
+			item := s.Project.ItemByTag[tag]
+			if item.Annotation == data.ItemTypeCnab && !isTarget(targets, item) {
+				targets = append(targets, item)
 			}
-			deletedDigests[link.Digest] = true
-
-			url := data.Scheme + "://" + data.Registry + "/v2/" + data.Repository + "/manifests/" + link.Digest
-			toDelete = append(toDelete, deleteEntry{
-				annotation: link.Annotation,
-				digest:     link.Digest,
-				url:        url,
-			})
 		}
+		return targets, nil
+	}
 
-		// Delete the CNAB index itself
-		if deletedDigests[item.Digest] {
-			continue
+	item, ok := s.Project.ItemByTag[s.Client.Tag]
+	if len(s.Client.Digest) != 0 {
+		byTag := item
+		item, ok = s.Project.ItemByDigest[s.Client.Digest]
+		// stale tag of name:tag@digest must not select other cnab
+		if ok && len(s.Client.Tag) != 0 && byTag != item {
+			err := fmt.Errorf("%w: tag %s doesn't point to digest %s", client.ErrDigestMismatch, s.Client.Tag, s.Client.Digest)
+			s.Config.Logger.Error(err.Error())
+			return nil, err
 		}
-		deletedDigests[item.Digest] = true
-		url := data.Scheme + "://" + data.Registry + "/v2/" + data.Repository + "/manifests/" + item.Digest
-		toDelete = append(toDelete, deleteEntry{
-			annotation: item.Annotation,
-			digest:     item.Digest,
-			url:        url,
-		})
 	}
+	if !ok {
+		err := fmt.Errorf("%w: %s is not in inspected project", client.ErrManifestNotFound, s.Client.Reference)
+		s.Config.Logger.Error(err.Error())
+		return nil, err
+	}
+	return append(targets, item), nil
+}
 
-	logging.Info(fmt.Sprintf("Items to delete: %d", len(toDelete)))
+// isTarget check if item is in targets
 
-	// Perform deletions
-	for _, entry := range toDelete {
-		logging.Message(fmt.Sprintf("Delete %s %s", entry.annotation, entry.url))
-		if cc.DryRun {
-			continue
+func isTarget(targets []*data.RegIndex, item *data.RegIndex) bool {
+	for _, target := range targets {
+		if target == item {
+			return true
+		}
+	}
+	return false
+}
+
+// reach mark digests of item and all items reachable from it
+
+func (s *Session) reach(item *data.RegIndex, marked map[string]bool) {
+	if marked[item.Digest] {
+		return
+	}
+	marked[item.Digest] = true
+	for _, link := range item.DownLinks {
+		if child, ok := s.Project.ItemByDigest[link.Digest]; ok {
+			s.reach(child, marked)
 		}
-		res, err := cl.WebDelete(entry.url)
-		if err != nil {
-			logging.Error(fmt.Sprintf("response is nil, %+v", err.Error()))
+	}
+}
+
+// manifestURL make manifest address of project repository
+
+func (s *Session) manifestURL(digest string) string {
+	return s.Project.Scheme + "://" + s.Project.Registry + "/v2/" + s.Project.Repository + "/manifests/" + digest
+}
+
+// ExecuteDelete delete items of plan, in dry run mode nothing is requested
+
+func (s *Session) ExecuteDelete(plan []DeleteEntry) []DeleteResult {
+	var results []DeleteResult
+	for _, entry := range plan {
+		s.Config.Logger.Message(fmt.Sprintf("Delete %s %s", entry.Annotation, entry.URL))
+		if s.Config.DryRun {
 			continue
 		}
-		if res != nil {
-			if res.StatusCode == 202 {
-				logging.Message(fmt.Sprintf("Item %s was deleted successfully", entry.digest))
-			} else {
-				logging.Error(fmt.Sprintf("Error %d", res.StatusCode))
-
-				// get body if there was an error
-				reader := res.Body
-				bytesbody, readErr := io.ReadAll(io.LimitReader(reader, client.MaxBodySize))
-				if readErr != nil {
-					errLine := fmt.Sprintf("failed to fetch response body %s", readErr)
-					logging.Error(errLine)
-				}
-				res.Body.Close()
-
-				// body must be json
-				if !json.Valid(bytesbody) {
-					errLine := fmt.Sprintf("response body is not valid json, status %d, headers %+v", res.StatusCode, res.Header)
-					logging.Error(errLine)
-					logging.Debug(fmt.Sprintf("response body  %+v", string(bytesbody)))
-				}
-				jsonres, err := logging.PrettyString(string(bytesbody))
-				if err != nil {
-					errLine := fmt.Sprintf("response body is unvalid json, status %d, headers %+v", res.StatusCode, res.Header)
-					logging.Error(errLine)
-					logging.Debug(fmt.Sprintf("response body  %+v", string(bytesbody)))
-				} else {
-					if data.Gc.Verbosity >= logging.LogNormalLevel {
-						fmt.Printf("%s\n", jsonres)
-					}
-				}
-			}
+		results = append(results, s.deleteEntry(entry))
+	}
+	return results
+}
+
+// deleteEntry delete one manifest by digest, result keeps start time and duration
+
+func (s *Session) deleteEntry(entry DeleteEntry) (result DeleteResult) {
+	result = DeleteResult{Entry: entry, Started: timeNow()}
+	defer func() {
+		result.ElapsedMs = time.Since(result.Started).Milliseconds()
+		if result.Err != nil {
+			result.Error = result.Err.Error()
 		}
+	}()
+	res, err := s.Client.WebDelete(entry.URL)
+	if err != nil {
+		s.Config.Logger.Error(fmt.Sprintf("response is nil, %+v", err.Error()))
+		result.Err = err
+		return result
+	}
+	result.Status = res.StatusCode
+	if res.StatusCode == 202 {
+		s.Config.Logger.Message(fmt.Sprintf("Item %s was deleted successfully", entry.Digest))
+		result.Deleted = true
+		res.Body.Close()
+		return result
+	}
+	s.Config.Logger.Error(fmt.Sprintf("Error %d", res.StatusCode))
+
+	// get body if there was an error
+	bytesbody, readErr := io.ReadAll(io.LimitReader(res.Body, client.MaxBodySize))
+	if readErr != nil {
+		errLine := fmt.Sprintf("failed to fetch response body %s", readErr)
+		s.Config.Logger.Error(errLine)
+	}
+	res.Body.Close()
+	result.Body = string(bytesbody)
+	result.Err = client.StatusError(res.StatusCode, fmt.Sprintf("delete %s failed with status %d", entry.Digest, res.StatusCode))
+
+	// body must be json
+	if !json.Valid(bytesbody) {
+		errLine := fmt.Sprintf("response body is not valid json, status %d, headers %+v", res.StatusCode, res.Header)
+		s.Config.Logger.Error(errLine)
+		s.Config.Logger.Debug(fmt.Sprintf("response body  %+v", string(bytesbody)))
 	}
+	return result
 }
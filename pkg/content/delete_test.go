@@ -0,0 +1,341 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newDeleteSession создаёт сессию с cnab, у которого один собственный и один общий с тегом other компонент
+func newDeleteSession(t *testing.T, registry string) *Session {
+	t.Helper()
+	s := newTestSession(t)
+	s.Client = client.NewRegClient((*client.Config)(s.Config), registry+"/repo/cnab:v1")
+	s.Project.Scheme = "http"
+	s.Project.Registry = registry
+	s.Project.Repository = "repo/cnab"
+
+	cnab := &data.RegIndex{
+		Tag:        "v1",
+		Digest:     "sha256:cnab",
+		Annotation: data.ItemTypeCnab,
+		DownLinks: []data.CnabItem{
+			{Digest: "sha256:own", Annotation: "config"},
+			{Digest: "sha256:shared", Annotation: "invocation"},
+			{Digest: "sha256:lost", Annotation: "component"},
+		},
+	}
+	own := &data.RegIndex{Digest: "sha256:own", Media: client.MediaTypeOciManifest, UpLinks: []data.CnabItem{{Digest: "sha256:cnab"}},
+		Blobs: map[string]int64{"sha256:own": 300, "sha256:config": 20, "sha256:layer": 1000}}
+	shared := &data.RegIndex{Digest: "sha256:shared", UpLinks: []data.CnabItem{{Digest: "sha256:cnab"}, {Digest: "sha256:other"}}}
+	other := &data.RegIndex{
+		Tag:        "other",
+		Digest:     "sha256:other",
+		Annotation: data.ItemTypeCnab,
+		DownLinks:  []data.CnabItem{{Digest: "sha256:shared", Annotation: "invocation"}},
+	}
+	s.Project.ItemByTag["v1"] = cnab
+	s.Project.ItemByTag["other"] = other
+	for _, ri := range []*data.RegIndex{cnab, own, shared, other} {
+		s.Project.ItemByDigest[ri.Digest] = ri
+		s.Project.ProjectList = append(s.Project.ProjectList, ri)
+	}
+	return s
+}
+
+// TestPlanDelete_SkipsSharedAndLost проверяет, что общие и ненайденные компоненты не попадают в план
+func TestPlanDelete_SkipsSharedAndLost(t *testing.T) {
+	s := newDeleteSession(t, "registry.example.com")
+
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	entries := plan.Entries
+	if len(entries) != 2 || entries[0].Digest != "sha256:own" || entries[1].Digest != "sha256:cnab" {
+		t.Fatalf("plan = %+v, want own component then cnab", entries)
+	}
+	if entries[0].URL != "http://registry.example.com/v2/repo/cnab/manifests/sha256:own" {
+		t.Errorf("URL = %q", entries[0].URL)
+	}
+	if entries[0].Reason != ReasonOrphan || entries[1].Reason != ReasonTarget {
+		t.Errorf("reasons = %s, %s, want orphan and target", entries[0].Reason, entries[1].Reason)
+	}
+	if entries[0].Media != client.MediaTypeOciManifest || entries[0].Size != 1320 ||
+		len(entries[0].Referrers) != 1 || entries[0].Referrers[0] != "sha256:cnab" {
+		t.Errorf("own entry = %+v, want media, size of blobs and cnab referrer", entries[0])
+	}
+	retained := plan.Retained
+	if len(retained) != 1 || retained[0].Digest != "sha256:shared" || retained[0].Reason != ReasonKeptTag ||
+		len(retained[0].Tags) != 1 || retained[0].Tags[0] != "other" {
+		t.Errorf("retained = %+v, want shared component kept by tag other", retained)
+	}
+}
+
+// TestExecuteDelete_Results проверяет результаты удаления и режим dry-run
+func TestExecuteDelete_Results(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("method = %s, want DELETE", r.Method)
+		}
+		deleted = append(deleted, r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "sha256:cnab") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":[{"code":"DENIED"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	s := newDeleteSession(t, strings.TrimPrefix(server.URL, "http://"))
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+
+	s.Config.DryRun = true
+	if results := s.ExecuteDelete(plan.Entries); len(results) != 0 || len(deleted) != 0 {
+		t.Errorf("dry run made %d requests, results %+v", len(deleted), results)
+	}
+
+	s.Config.DryRun = false
+	results := s.ExecuteDelete(plan.Entries)
+	if len(results) != 2 {
+		t.Fatalf("results length = %d, want 2", len(results))
+	}
+	if !results[0].Deleted || results[0].Status != 202 || results[0].Err != nil {
+		t.Errorf("first result = %+v, want deleted", results[0])
+	}
+	if results[1].Deleted || results[1].Status != 403 || results[1].Err == nil || !strings.Contains(results[1].Body, "DENIED") {
+		t.Errorf("second result = %+v, want failure with body", results[1])
+	}
+	if results[1].Error != results[1].Err.Error() || results[0].Error != "" {
+		t.Errorf("error texts = %q, %q", results[0].Error, results[1].Error)
+	}
+	for _, result := range results {
+		if result.Started.IsZero() || result.ElapsedMs < 0 {
+			t.Errorf("result %s has no timing, %+v", result.Entry.Digest, result)
+		}
+	}
+}
+
+// TestPlanDelete_NestedIndexes проверяет удаление вложенных индексов после их потомков
+func TestPlanDelete_NestedIndexes(t *testing.T) {
+	s, names := newNestedSession(t)
+
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	position := make(map[string]int)
+	for i, entry := range plan.Entries {
+		position[entry.Digest] = i
+	}
+	for _, name := range []string{"config", "amd64", "armv8", "arm", "list", "cnab"} {
+		if _, ok := position[names[name]]; !ok {
+			t.Fatalf("plan has no %s, plan %+v", name, plan)
+		}
+	}
+	before := [][2]string{{"armv8", "arm"}, {"arm", "list"}, {"amd64", "list"}, {"list", "cnab"}, {"config", "cnab"}}
+	for _, pair := range before {
+		if position[names[pair[0]]] > position[names[pair[1]]] {
+			t.Errorf("%s must be deleted before %s, plan %+v", pair[0], pair[1], plan)
+		}
+	}
+	if len(plan.Entries) != 6 {
+		t.Errorf("plan length = %d, want 6", len(plan.Entries))
+	}
+}
+
+// newTwoBundlesSession создаёт два cnab с общим компонентом, у v1 есть второй тег latest
+func newTwoBundlesSession(t *testing.T, reference string) *Session {
+	t.Helper()
+	s := newTestSession(t)
+	s.Client = client.NewRegClient((*client.Config)(s.Config), reference)
+	s.Project.Scheme = "http"
+	s.Project.Registry = "registry.example.com"
+	s.Project.Repository = "repo/cnab"
+
+	add := func(ri *data.RegIndex, tags ...string) {
+		s.Project.ItemByDigest[ri.Digest] = ri
+		s.Project.ProjectList = append(s.Project.ProjectList, ri)
+		for _, tag := range tags {
+			s.Project.ItemByTag[tag] = ri
+		}
+	}
+	add(&data.RegIndex{Digest: "sha256:cnab1", Annotation: data.ItemTypeCnab, DownLinks: []data.CnabItem{
+		{Digest: "sha256:own1", Annotation: "config"}, {Digest: "sha256:shared", Annotation: "invocation"}}}, "v1", "latest")
+	add(&data.RegIndex{Digest: "sha256:cnab2", Annotation: data.ItemTypeCnab, DownLinks: []data.CnabItem{
+		{Digest: "sha256:own2", Annotation: "config"}, {Digest: "sha256:shared", Annotation: "invocation"}}}, "v2")
+	add(&data.RegIndex{Digest: "sha256:own1", UpLinks: []data.CnabItem{{Digest: "sha256:cnab1"}}})
+	add(&data.RegIndex{Digest: "sha256:own2", UpLinks: []data.CnabItem{{Digest: "sha256:cnab2"}}})
+	add(&data.RegIndex{Digest: "sha256:shared", UpLinks: []data.CnabItem{{Digest: "sha256:cnab1"}, {Digest: "sha256:cnab2"}}})
+	return s
+}
+
+// planDigests возвращает digest элементов плана по порядку
+func planDigests(plan *DeletePlan) []string {
+	var digests []string
+	for _, entry := range plan.Entries {
+		digests = append(digests, entry.Digest)
+	}
+	return digests
+}
+
+// TestPlanDelete_TargetOnly проверяет, что удаляется только граф заданной ссылки
+func TestPlanDelete_TargetOnly(t *testing.T) {
+	s := newTwoBundlesSession(t, "registry.example.com/repo/cnab:v1")
+	s.Config.Force = true
+
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	if got := strings.Join(planDigests(plan), ","); got != "sha256:own1,sha256:cnab1" {
+		t.Errorf("plan = %s, want own component and cnab of v1 only", got)
+	}
+	if len(plan.Retained) != 1 || plan.Retained[0].Digest != "sha256:shared" || strings.Join(plan.Retained[0].Tags, ",") != "v2" {
+		t.Errorf("retained = %+v, want shared component kept by v2", plan.Retained)
+	}
+
+	// ссылка по digest
+	s.Client = client.NewRegClient((*client.Config)(s.Config), "registry.example.com/repo/cnab@sha256:cnab2")
+	plan, err = s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	if got := strings.Join(planDigests(plan), ","); got != "sha256:own2,sha256:cnab2" {
+		t.Errorf("plan = %s, want own component and cnab of v2 only", got)
+	}
+	if tags := strings.Join(plan.Entries[1].Tags, ","); tags != "v2" {
+		t.Errorf("target tags = %s, want v2", tags)
+	}
+	if len(plan.Retained) != 1 || strings.Join(plan.Retained[0].Tags, ",") != "latest,v1" {
+		t.Errorf("retained = %+v, want shared component kept by latest and v1", plan.Retained)
+	}
+
+	s.Client = client.NewRegClient((*client.Config)(s.Config), "registry.example.com/repo/cnab:v3")
+	if _, err := s.PlanDelete(); !errors.Is(err, client.ErrManifestNotFound) {
+		t.Errorf("PlanDelete error = %v, want ErrManifestNotFound for unknown tag", err)
+	}
+
+	// тег и digest ссылки должны указывать на один cnab
+	s.Client = client.NewRegClient((*client.Config)(s.Config), "registry.example.com/repo/cnab:v2@sha256:cnab2")
+	if plan, err := s.PlanDelete(); err != nil || strings.Join(planDigests(plan), ",") != "sha256:own2,sha256:cnab2" {
+		t.Errorf("PlanDelete = %+v, %v, want cnab of v2", plan, err)
+	}
+	for _, reference := range []string{"registry.example.com/repo/cnab:v1@sha256:cnab2", "registry.example.com/repo/cnab:v3@sha256:cnab2"} {
+		s.Client = client.NewRegClient((*client.Config)(s.Config), reference)
+		if plan, err := s.PlanDelete(); !errors.Is(err, client.ErrDigestMismatch) || plan != nil {
+			t.Errorf("PlanDelete(%s) = %+v, %v, want ErrDigestMismatch", reference, plan, err)
+		}
+	}
+}
+
+// TestPlanDelete_SharedTags проверяет, что cnab с двумя тегами не удаляется по одному из них без force
+func TestPlanDelete_SharedTags(t *testing.T) {
+	s := newTwoBundlesSession(t, "registry.example.com/repo/cnab:v1")
+
+	if plan, err := s.PlanDelete(); !errors.Is(err, ErrSharedTags) || plan != nil || !strings.Contains(err.Error(), "latest") {
+		t.Fatalf("PlanDelete = %+v, %v, want ErrSharedTags naming latest", plan, err)
+	}
+
+	// с force оба тега попадают в план вместе с cnab
+	s.Config.Force = true
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	target := plan.Entries[len(plan.Entries)-1]
+	if target.Digest != "sha256:cnab1" || strings.Join(target.Tags, ",") != "latest,v1" {
+		t.Errorf("target = %+v, want cnab1 with tags latest and v1", target)
+	}
+	if len(plan.Entries[0].Tags) != 0 {
+		t.Errorf("component = %+v, want no tags", plan.Entries[0])
+	}
+
+	// тег v2 у cnab единственный
+	s.Config.Force = false
+	s.Client = client.NewRegClient((*client.Config)(s.Config), "registry.example.com/repo/cnab:v2")
+	if _, err := s.PlanDelete(); err != nil {
+		t.Errorf("PlanDelete should not return error for single tag, got: %v", err)
+	}
+}
+
+// TestPlanDelete_AllBundles проверяет, что общий компонент удаляемых cnab тоже удаляется
+func TestPlanDelete_AllBundles(t *testing.T) {
+	s := newTwoBundlesSession(t, "registry.example.com/repo/cnab:v1")
+	s.Config.AllBundles = true
+
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	if got := strings.Join(planDigests(plan), ","); got != "sha256:own1,sha256:shared,sha256:cnab1,sha256:own2,sha256:cnab2" {
+		t.Errorf("plan = %s, want both bundles with shared component", got)
+	}
+	if len(plan.Retained) != 0 {
+		t.Errorf("retained = %+v, want nothing", plan.Retained)
+	}
+}
+
+// TestPlanDelete_PartialGraph проверяет, что граф с непрочитанными тегами или bundle.json не планируется к удалению
+func TestPlanDelete_PartialGraph(t *testing.T) {
+
+	config := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.cnab.config.v1+json","digest":"sha256:bundle"}}`
+	cnab := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"%s","mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"io.cnab.manifest.type":"config"}}]}`, digestOf(config))
+	tests := []struct {
+		name string
+		tags string
+	}{
+		// v2 может ссылаться на компоненты v1
+		{name: "tag", tags: `["v1","v2"]`},
+		// bundle.json v1 не прочитан
+		{name: "bundle", tags: `["v1"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/tags/list/"):
+					w.Write([]byte(`{"name":"repo/cnab","tags":` + tt.tags + `}`))
+				case strings.HasSuffix(r.URL.Path, "/manifests/v1"):
+					w.Header().Set("Content-Type", client.MediaTypeOciIndex)
+					w.Write([]byte(cnab))
+				case strings.HasSuffix(r.URL.Path, "/manifests/"+digestOf(config)):
+					w.Header().Set("Content-Type", client.MediaTypeOciManifest)
+					w.Write([]byte(config))
+				default:
+					w.WriteHeader(500)
+				}
+			}))
+			defer server.Close()
+
+			s := newTestSession(t)
+			s.Config.Logger.SetVerbosity(logging.LogQuietLevel)
+			s.Config.Scheme = "http"
+			s.Client = client.NewRegClient((*client.Config)(s.Config), strings.TrimPrefix(server.URL, "http://")+"/repo/cnab:v1")
+			if err := s.Client.ParseReference(); err != nil {
+				t.Fatalf("ParseReference should not return error, got: %v", err)
+			}
+			if err := s.InspectCnab(); !errors.Is(err, ErrPartial) {
+				t.Fatalf("InspectCnab error = %v, want ErrPartial", err)
+			}
+
+			if plan, err := s.PlanDelete(); !errors.Is(err, ErrPartial) || plan != nil {
+				t.Errorf("PlanDelete = %+v, %v, want ErrPartial without plan", plan, err)
+			}
+			if plan, err := s.PlanPrune(); !errors.Is(err, ErrPartial) || plan != nil {
+				t.Errorf("PlanPrune = %+v, %v, want ErrPartial without plan", plan, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AnnotationInvocation is annotation of invocation image of bundle
+
+const AnnotationInvocation = "invocation"
+
+// image referenced by bundle.json
+
+type bundleImage struct {
+	Annotation string // invocation or key of images map
+	Registry   string
+	Repository string
+	Digest     string
+}
+
+// bundleImages list images of bundle which are pinned by digest,
+// invocation images go first, then images sorted by name
+
+func bundleImages(bundle *data.Bundle, logger *logging.Logger) []bundleImage {
+	var images []bundleImage
+	add := func(annotation string, image data.BundleImage) {
+		// only parse reference, client is not used
+		cl := &client.RegClient{Reference: image.Image}
+		if err := cl.ParseReference(); err != nil {
+			logger.Info(fmt.Sprintf("image %s of bundle is not followed, %+v", image.Image, err.Error()))
+			return
+		}
+		digest := image.ContentDigest
+		if len(digest) == 0 {
+			digest = cl.Digest
+		}
+		if len(digest) == 0 {
+			logger.Info(fmt.Sprintf("image %s of bundle is not pinned by digest, it is not followed", image.Image))
+			return
+		}
+		images = append(images, bundleImage{
+			Annotation: annotation,
+			Registry:   cl.Registry,
+			Repository: cl.Repository,
+			Digest:     digest,
+		})
+	}
+
+	for _, image := range bundle.InvocationImages {
+		add(AnnotationInvocation, image)
+	}
+	names := make([]string, 0, len(bundle.Images))
+	for name := range bundle.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(name, bundle.Images[name])
+	}
+	return images
+}
+
+// foreign check if image lives out of project repository
+
+func (s *Session) foreign(image bundleImage) bool {
+	return image.Registry != s.Project.Registry || image.Repository != s.Project.Repository
+}
+
+// origin return registry and repository of item, items of other repositories
+// keep them in Repository
+
+func (s *Session) origin(item *data.RegIndex) bundleImage {
+	if registry, repository, found := strings.Cut(item.Repository, "/"); found {
+		return bundleImage{Registry: registry, Repository: repository}
+	}
+	return bundleImage{Registry: s.Project.Registry, Repository: s.Project.Repository}
+}
+
+// fetchImages get manifests of images by digest from their repositories concurrently
+
+func (s *Session) fetchImages(images []bundleImage) []fetchResult {
+	results := make([]fetchResult, len(images))
+	s.forEach(len(images), func(cl *client.RegClient, i int) {
+		image := images[i]
+		icl := cl
+		if image.Registry != s.Project.Registry {
+			// other registry has own credentials
+			icl = client.NewRegClient((*client.Config)(s.Config), image.Registry+"/"+image.Repository+"@"+image.Digest)
+			icl.ResolveCredentials()
+		}
+		icl.Repository, icl.Tag, icl.Digest = image.Repository, "", image.Digest
+		regres, err := icl.GetRegIndex()
+		results[i] = fetchResult{regres: regres, err: err}
+	})
+	return results
+}
+
+// FollowImages resolve images of bundle.json for every cnab index and link them
+// as down links of the cnab. Images of other repositories are registered with
+// their repository. Return count of images, which were not fetched because of errors
+
+func (s *Session) FollowImages() int {
+	type link struct {
+		cnab  *data.RegIndex
+		image bundleImage
+	}
+	var links []link
+	var missing []bundleImage
+	seen := make(map[string]bool)
+	for _, item := range s.Project.ProjectList {
+		if item.Bundle == nil {
+			continue
+		}
+		for _, image := range bundleImages(item.Bundle, s.Config.Logger) {
+			links = append(links, link{cnab: item, image: image})
+			if _, ok := s.Project.ItemByDigest[image.Digest]; ok || seen[image.Digest] {
+				continue
+			}
+			seen[image.Digest] = true
+			missing = append(missing, image)
+		}
+	}
+
+	// images are fetched by digest from their repositories
+	results := s.fetchImages(missing)
+
+	failed := 0
+	var indexes []*data.RegIndex // multi-arch images, which children are fetched too
+	lost := make(map[string]bool)
+	for i, image := range missing {
+		result := results[i]
+		if result.err != nil {
+			if errors.Is(result.err, client.ErrManifestNotFound) {
+				lost[image.Digest] = true
+				continue
+			}
+			s.Config.Logger.Error(fmt.Sprintf("can't fetch image %s/%s@%s, %+v", image.Registry, image.Repository, image.Digest, result.err.Error()))
+			failed++
+			continue
+		}
+		ri, err := s.AddIndex(result.regres, "")
+		if err != nil {
+			failed++
+			continue
+		}
+		if s.foreign(image) {
+			ri.Repository = image.Registry + "/" + image.Repository
+		}
+		if isIndexMedia(ri.Media) {
+			ri.Annotation = data.ItemTypeIndex
+			s.addDownLinks(ri, false)
+			indexes = append(indexes, ri)
+		}
+	}
+
+	// platforms of multi-arch images are walked like nested indexes of cnab
+	nested := s.expandIndexes(indexes, seen)
+	s.linkPlatforms(append(indexes, nested...))
+
+	for _, link := range links {
+		image := link.image
+		ri, ok := s.Project.ItemByDigest[image.Digest]
+		if !ok {
+			if lost[image.Digest] {
+				s.Config.Logger.Error(fmt.Sprintf("For cnab %s image %s was not found by digest %s", link.cnab.Tag, image.Annotation, image.Digest))
+				link.cnab.Lost++
+			}
+			continue
+		}
+		if !hasLink(link.cnab.DownLinks, image.Digest) {
+			link.cnab.DownLinks = append(link.cnab.DownLinks, data.CnabItem{Digest: image.Digest, Annotation: image.Annotation})
+		}
+		if !hasLink(ri.UpLinks, link.cnab.Digest) {
+			ri.UpLinks = append(ri.UpLinks, data.CnabItem{Digest: link.cnab.Digest, Annotation: link.cnab.Annotation})
+			ri.Annotation = image.Annotation
+		}
+	}
+	return failed
+}
@@ -0,0 +1,187 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/buger/jsonparser"
+)
+
+// TestFollowImages проверяет связывание образов bundle.json из своего и чужого репозитория
+func TestFollowImages(t *testing.T) {
+
+	invocation := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[{"digest":"sha256:invocation"}]}`
+	web := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[{"digest":"sha256:web"}]}`
+	lost := "sha256:" + strings.Repeat("0", 64)
+	manifests := map[string]string{
+		"/v2/repo/cnab/manifests/" + digestOf(invocation): invocation,
+		"/v2/other/images/manifests/" + digestOf(web):     web,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content, ok := manifests[r.URL.Path]; ok {
+			w.Header().Set("Content-Type", client.MediaTypeOciManifest)
+			w.Header().Set("Docker-Content-Digest", digestOf(content))
+			w.Write([]byte(content))
+			return
+		}
+		w.WriteHeader(404)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	bundle := `{"schemaVersion":"v1.0.0","name":"app","version":"1.0.0",` +
+		`"invocationImages":[{"image":"` + host + `/repo/cnab@` + digestOf(invocation) + `","imageType":"docker"}],` +
+		`"images":{"web":{"image":"` + host + `/other/images@` + digestOf(web) + `"},` +
+		`"db":{"image":"` + host + `/repo/cnab:db","contentDigest":"` + lost + `"},` +
+		`"cache":{"image":"` + host + `/repo/cnab:cache"}}}`
+
+	s := newTestSession(t)
+	s.Config.Logger.SetVerbosity(logging.LogQuietLevel)
+	s.Config.Scheme = "http"
+	s.Client = client.NewRegClient((*client.Config)(s.Config), host+"/repo/cnab:v1")
+	s.Project.Registry = host
+	s.Project.Repository = "repo/cnab"
+	decoded, err := DecodeBundle([]byte(bundle))
+	if err != nil {
+		t.Fatalf("bundle should be decoded, got: %v", err)
+	}
+	cnab := &data.RegIndex{Tag: "v1", Digest: "sha256:cnab", Annotation: data.ItemTypeCnab, Bundle: decoded}
+	s.Project.ItemByTag["v1"] = cnab
+	s.Project.ItemByDigest[cnab.Digest] = cnab
+	s.Project.ProjectList = append(s.Project.ProjectList, cnab)
+
+	if failed := s.FollowImages(); failed != 0 {
+		t.Fatalf("FollowImages failed = %d, want 0", failed)
+	}
+
+	if len(cnab.DownLinks) != 2 || !hasLink(cnab.DownLinks, digestOf(invocation)) || !hasLink(cnab.DownLinks, digestOf(web)) {
+		t.Errorf("cnab downlinks = %+v, want invocation and web", cnab.DownLinks)
+	}
+	if cnab.Lost != 1 {
+		t.Errorf("cnab lost = %d, want 1 for db image", cnab.Lost)
+	}
+	inv := s.Project.ItemByDigest[digestOf(invocation)]
+	if inv.Annotation != AnnotationInvocation || len(inv.Repository) != 0 || !hasLink(inv.UpLinks, cnab.Digest) {
+		t.Errorf("invocation item = %+v, want project item linked to cnab", inv)
+	}
+	other := s.Project.ItemByDigest[digestOf(web)]
+	if other.Annotation != "web" || other.Repository != host+"/other/images" || !hasLink(other.UpLinks, cnab.Digest) {
+		t.Errorf("web item = %+v, want item of other repository linked to cnab", other)
+	}
+
+	// образ чужого репозитория не удаляется
+	s.Project.Scheme = "http"
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	if len(plan.Entries) != 2 || plan.Entries[0].Digest != digestOf(invocation) || plan.Entries[1].Digest != cnab.Digest {
+		t.Errorf("plan = %+v, want invocation then cnab", plan.Entries)
+	}
+	if len(plan.Retained) != 1 || plan.Retained[0].Reason != ReasonForeign || plan.Retained[0].Repository != host+"/other/images" {
+		t.Errorf("retained = %+v, want image of other repository", plan.Retained)
+	}
+}
+
+// TestFollowImages_MultiArch проверяет обход платформ многоархитектурных образов bundle.json
+func TestFollowImages_MultiArch(t *testing.T) {
+
+	amd64 := `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","layers":[{"digest":"sha256:amd64"}]}`
+	arm64 := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[{"digest":"sha256:arm64"}]}`
+	list := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","manifests":[{"digest":"%s","mediaType":"application/vnd.docker.distribution.manifest.v2+json","platform":{"os":"linux","architecture":"amd64"}},{"digest":"%s","mediaType":"application/vnd.oci.image.manifest.v1+json","platform":{"os":"linux","architecture":"arm64"}}]}`, digestOf(amd64), digestOf(arm64))
+	web := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[{"digest":"sha256:web"}]}`
+	webIndex := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"%s","mediaType":"application/vnd.oci.image.manifest.v1+json","platform":{"os":"linux","architecture":"amd64"}}]}`, digestOf(web))
+	manifests := map[string]string{
+		"/v2/repo/cnab/manifests/" + digestOf(list):        list,
+		"/v2/repo/cnab/manifests/" + digestOf(amd64):       amd64,
+		"/v2/repo/cnab/manifests/" + digestOf(arm64):       arm64,
+		"/v2/other/images/manifests/" + digestOf(webIndex): webIndex,
+		"/v2/other/images/manifests/" + digestOf(web):      web,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := manifests[r.URL.Path]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		media, _ := jsonparser.GetString([]byte(content), "mediaType")
+		w.Header().Set("Content-Type", media)
+		w.Header().Set("Docker-Content-Digest", digestOf(content))
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	bundle := `{"schemaVersion":"v1.0.0","name":"app","version":"1.0.0",` +
+		`"invocationImages":[{"image":"` + host + `/repo/cnab@` + digestOf(list) + `","imageType":"docker"}],` +
+		`"images":{"web":{"image":"` + host + `/other/images@` + digestOf(webIndex) + `"}}}`
+
+	s := newTestSession(t)
+	s.Config.Logger.SetVerbosity(logging.LogQuietLevel)
+	s.Config.Scheme = "http"
+	s.Client = client.NewRegClient((*client.Config)(s.Config), host+"/repo/cnab:v1")
+	s.Project.Registry = host
+	s.Project.Repository = "repo/cnab"
+	decoded, err := DecodeBundle([]byte(bundle))
+	if err != nil {
+		t.Fatalf("bundle should be decoded, got: %v", err)
+	}
+	cnab := &data.RegIndex{Tag: "v1", Digest: "sha256:cnab", Annotation: data.ItemTypeCnab, Bundle: decoded}
+	s.Project.ItemByTag["v1"] = cnab
+	s.Project.ItemByDigest[cnab.Digest] = cnab
+	s.Project.ProjectList = append(s.Project.ProjectList, cnab)
+
+	if failed := s.FollowImages(); failed != 0 {
+		t.Fatalf("FollowImages failed = %d, want 0", failed)
+	}
+
+	inv := s.Project.ItemByDigest[digestOf(list)]
+	if inv == nil || inv.Annotation != AnnotationInvocation || len(inv.DownLinks) != 2 || inv.Lost != 0 || !hasLink(inv.UpLinks, cnab.Digest) {
+		t.Fatalf("invocation list = %+v, want manifest list with 2 platforms linked to cnab", inv)
+	}
+	platforms := map[string]string{amd64: "linux/amd64", arm64: "linux/arm64"}
+	for content, want := range platforms {
+		ri, ok := s.Project.ItemByDigest[digestOf(content)]
+		if !ok {
+			t.Errorf("platform %s was not registered", want)
+			continue
+		}
+		if ri.Platform != want || len(ri.Repository) != 0 || !hasLink(ri.UpLinks, inv.Digest) {
+			t.Errorf("platform item = %+v, want %s of project linked to invocation list", ri, want)
+		}
+	}
+	// платформа образа чужого репозитория берётся из его репозитория
+	platform, ok := s.Project.ItemByDigest[digestOf(web)]
+	if !ok || platform.Repository != host+"/other/images" || !hasLink(platform.UpLinks, digestOf(webIndex)) {
+		t.Errorf("web platform = %+v, want item of other repository linked to web index", platform)
+	}
+
+	s.Project.Scheme = "http"
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	position := make(map[string]int)
+	for i, entry := range plan.Entries {
+		position[entry.Digest] = i
+	}
+	for _, content := range []string{amd64, arm64} {
+		if i, ok := position[digestOf(content)]; !ok || i > position[inv.Digest] {
+			t.Errorf("platform %s must be deleted before invocation list, plan %+v", digestOf(content), plan.Entries)
+		}
+	}
+	if len(plan.Entries) != 4 || plan.Entries[3].Digest != cnab.Digest {
+		t.Errorf("plan = %+v, want platforms, invocation list, cnab", plan.Entries)
+	}
+	if _, ok := position[digestOf(web)]; ok || len(plan.Retained) != 1 || plan.Retained[0].Digest != digestOf(webIndex) {
+		t.Errorf("retained = %+v, want web index of other repository with its platform", plan.Retained)
+	}
+}
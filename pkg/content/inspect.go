@@ -4,19 +4,32 @@ import (
 	"cnabtool/pkg/client"
 	"cnabtool/pkg/data"
 	"cnabtool/pkg/logging"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/buger/jsonparser"
 )
 
+// content problems, check them with errors.Is
+
+var (
+	ErrNotCnabIndex   = errors.New("not a cnab index")
+	ErrTagListInvalid = client.ErrTagListInvalid
+	ErrPartial        = errors.New("partial failure")
+	ErrDanglingLinks  = errors.New("dangling links")
+)
+
 // AddCnab add cnab to ItemByDigest collection
 
-func AddCnab(regres *client.RegResponse, tag string) error {
+func (s *Session) AddCnab(regres *client.RegResponse, tag string) error {
 
-	ri, err := AddIndex(regres, tag)
+	ri, err := s.AddIndex(regres, tag)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotCnabIndex, err)
+	}
 	js := ri.Content
 	// drop old list, if exists
 	ri.DownLinks = nil
@@ -26,65 +39,109 @@ func AddCnab(regres *client.RegResponse, tag string) error {
 	// at first check if manifests is exists
 	manifests, keytype, _, err := jsonparser.Get(([]byte)(js), "manifests")
 	if err != nil {
-		errLine := fmt.Sprintf("json isn't contain manifests key, %+v", err.Error())
-		logging.Error(errLine)
-		return errors.New(errLine)
+		err := fmt.Errorf("%w: json isn't contain manifests key, %+v", ErrNotCnabIndex, err.Error())
+		s.Config.Logger.Error(err.Error())
+		return err
 	}
-	//logging.Info(fmt.Sprintf("manifests %+v, %+v", string(manifests), keytype))
+	//s.Config.Logger.Info(fmt.Sprintf("manifests %+v, %+v", string(manifests), keytype))
 	if keytype.String() != "array" {
-		errLine := fmt.Sprintf("manifests key must contain array %+v", string(manifests))
-		logging.Error(errLine)
-		return errors.New(errLine)
+		err := fmt.Errorf("%w: manifests key must contain array %+v", ErrNotCnabIndex, string(manifests))
+		s.Config.Logger.Error(err.Error())
+		return err
 	}
 
-	// parse manifests
-	jsonparser.ArrayEach(([]byte)(js), func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+	s.addDownLinks(ri, true)
+
+	s.Config.Logger.Debug(fmt.Sprintf("new registry index %+v", ri))
+
+	return nil
+}
+
+// addDownLinks parse manifests of index to DownLinks and push them to queue.
+// Children of cnab index are named by cnab annotations, children of nested
+// image index or manifest list by their media type
+
+func (s *Session) addDownLinks(ri *data.RegIndex, cnab bool) {
+	jsonparser.ArrayEach(([]byte)(ri.Content), func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
 		digest, _, _, err := jsonparser.Get(value, "digest")
 		if err != nil {
 			errLine := fmt.Sprintf("manifests digest is invalid, %+v", err.Error())
-			logging.Error(errLine)
+			s.Config.Logger.Error(errLine)
 		}
 		media, _, _, err := jsonparser.Get(value, "mediaType")
 		if err != nil {
 			errLine := fmt.Sprintf("manifests mediaType is invalid, %+v", err.Error())
-			logging.Error(errLine)
-		}
-		annotation, _, _, err := jsonparser.Get(value, "annotations", "io.cnab.manifest.type")
-		if err != nil {
-			errLine := fmt.Sprintf("manifests annotations is invalid, %+v", err.Error())
-			logging.Error(errLine)
+			s.Config.Logger.Error(errLine)
 		}
 
-		// for component item continue decode
-		realAnnotation := string(annotation)
-		if realAnnotation == "component" {
-			annotation, _, _, err := jsonparser.Get(value, "annotations", "io.cnab.component.name")
+		realAnnotation := data.ItemTypeImage
+		if isIndexMedia(string(media)) {
+			realAnnotation = data.ItemTypeIndex
+		}
+		if cnab {
+			annotation, _, _, err := jsonparser.Get(value, "annotations", "io.cnab.manifest.type")
 			if err != nil {
 				errLine := fmt.Sprintf("manifests annotations is invalid, %+v", err.Error())
-				logging.Error(errLine)
+				s.Config.Logger.Error(errLine)
 			}
+
+			// for component item continue decode
 			realAnnotation = string(annotation)
+			if realAnnotation == "component" {
+				annotation, _, _, err := jsonparser.Get(value, "annotations", "io.cnab.component.name")
+				if err != nil {
+					errLine := fmt.Sprintf("manifests annotations is invalid, %+v", err.Error())
+					s.Config.Logger.Error(errLine)
+				}
+				realAnnotation = string(annotation)
+			}
 		}
+		platform := platformOf(value)
 
-		logging.Debug(fmt.Sprintf("Found media %s, annotation %s, digest %s", media, realAnnotation, digest))
+		s.Config.Logger.Debug(fmt.Sprintf("Found media %s, annotation %s, digest %s, platform %s", media, realAnnotation, digest, platform))
 
-		if string(media) == client.MediaTypeOciManifest || string(media) == client.MediaTypeV2Manifest {
-			data.ItemsQueue = append(data.ItemsQueue, string(digest))
-			ri.DownLinks = append(ri.DownLinks, data.CnabItem{Digest: string(digest), Annotation: realAnnotation})
+		switch string(media) {
+		case client.MediaTypeOciManifest, client.MediaTypeV2Manifest, client.MediaTypeOciIndex, client.MediaTypeV2List:
+			s.Project.ItemsQueue = append(s.Project.ItemsQueue, string(digest))
+			ri.DownLinks = append(ri.DownLinks, data.CnabItem{Digest: string(digest), Annotation: realAnnotation, Platform: platform})
 		}
 
 	}, "manifests")
+}
 
-	logging.Debug(fmt.Sprintf("new registry index %+v", ri))
+// platformOf make os/architecture/variant string from platform of descriptor, empty if not set
 
-	return nil
+func platformOf(descriptor []byte) string {
+	var parts []string
+	for _, key := range []string{"os", "architecture", "variant"} {
+		if value, err := jsonparser.GetString(descriptor, "platform", key); err == nil && len(value) != 0 {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// isIndexMedia check if manifest is oci image index or docker manifest list
+
+func isIndexMedia(media string) bool {
+	return media == client.MediaTypeOciIndex || media == client.MediaTypeV2List
 }
 
-func AddIndex(regres *client.RegResponse, tag string) (*data.RegIndex, error) {
-	ri, ok := data.ItemByDigest[regres.Digest]
+// AddIndex add item to project graph, registered item is reused by digest
+
+func (s *Session) AddIndex(regres *client.RegResponse, tag string) (*data.RegIndex, error) {
+	ri, ok := s.Project.ItemByDigest[regres.Digest]
 	if ok {
-		logging.Debug(fmt.Sprintf("already has %+v", ri))
+		s.Config.Logger.Debug(fmt.Sprintf("already has %+v", ri))
 	} else {
+		// check content before registration, invalid manifest is not a part of graph
+		js, err := logging.PrettyString(regres.Content)
+		if err != nil {
+			errLine := fmt.Sprintf("invalid context, %+v", err.Error())
+			s.Config.Logger.Error(errLine)
+			return nil, errors.New(errLine)
+		}
+
 		// otherwise make new
 		ri = &data.RegIndex{
 			Reference: regres.Reference,
@@ -100,195 +157,431 @@ func AddIndex(regres *client.RegResponse, tag string) (*data.RegIndex, error) {
 			ri.Annotation = data.ItemTypeImage
 		case client.MediaTypeOciManifest:
 			ri.Annotation = data.ItemTypeConfig
+		case client.MediaTypeV2List:
+			ri.Annotation = data.ItemTypeIndex
 		case client.MediaTypeOciIndex:
 			ri.Annotation = data.ItemTypeCnab
 		default:
 			ri.Annotation = data.ItemTypeStuff
 		}
 
-		data.ItemByDigest[regres.Digest] = ri
-		data.ProjectList = append(data.ProjectList, ri)
+		s.Project.ItemByDigest[regres.Digest] = ri
+		s.Project.ProjectList = append(s.Project.ProjectList, ri)
 
-		// scan context and push digests to queue
+		ri.Content = js
+		ri.Blobs = manifestBlobs(ri, len(regres.Content))
+		s.Config.Logger.Debug(fmt.Sprintf("new index %+v", ri))
+	}
+	if len(tag) != 0 {
+		s.Project.ItemByTag[tag] = ri
+	}
+	return ri, nil
 
-		// check entry call - it must be correct cnab index
-		js, err := logging.PrettyString(regres.Content)
+}
+
+// fetched manifest or error
+
+type fetchResult struct {
+	regres *client.RegResponse
+	err    error
+}
+
+// forEach run job for numbers 0..count-1 with bounded worker pool,
+// every worker has own copy of client
+
+func (s *Session) forEach(count int, job func(cl *client.RegClient, i int)) {
+	workers := s.Config.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > count {
+		workers = count
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// every worker has own reference fields
+			wcl := s.Client.Clone()
+			for i := range jobs {
+				job(wcl, i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// fetchIndexes get manifests by tags concurrently,
+// results are in order of tags
+
+func (s *Session) fetchIndexes(tags []string) []fetchResult {
+	results := make([]fetchResult, len(tags))
+	s.forEach(len(tags), func(cl *client.RegClient, i int) {
+		cl.Tag, cl.Digest = tags[i], ""
+		regres, err := cl.GetRegIndex()
+		results[i] = fetchResult{regres: regres, err: err}
+	})
+	return results
+}
+
+// headIndexes get digests of manifests by tags concurrently,
+// digest is empty if registry doesn't report it
+
+func (s *Session) headIndexes(tags []string) []string {
+	digests := make([]string, len(tags))
+	s.forEach(len(tags), func(cl *client.RegClient, i int) {
+		cl.Tag, cl.Digest = tags[i], ""
+		regres, err := cl.HeadManifest()
 		if err != nil {
-			errLine := fmt.Sprintf("invalid context, %+v", err.Error())
-			logging.Error(errLine)
-			return nil, errors.New(errLine)
+			// GET tells more about the problem
+			s.Config.Logger.Debug(fmt.Sprintf("can't check manifest for tag %s, %+v", tags[i], err.Error()))
+			return
 		}
-		ri.Content = js
-		logging.Debug(fmt.Sprintf("new index %+v", ri))
+		digests[i] = regres.Digest
+	})
+	return digests
+}
+
+// fetchTags get manifests of tags. Tags registered already, e.g. the reference tag,
+// are not requested. Other tags are resolved with cheap HEAD requests, and only
+// manifests which are not known yet are downloaded, once per digest.
+// Result is nil for tag of manifest, which digest is returned instead
+
+func (s *Session) fetchTags(taglist []string) ([]*fetchResult, []string) {
+	results := make([]*fetchResult, len(taglist))
+	digests := make([]string, len(taglist))
+
+	var rest []string
+	var positions []int // positions of rest in taglist
+	for i, tag := range taglist {
+		if item, ok := s.Project.ItemByTag[tag]; ok {
+			digests[i] = item.Digest
+			continue
+		}
+		positions = append(positions, i)
+		rest = append(rest, tag)
 	}
-	data.ItemByTag[tag] = ri
-	return ri, nil
 
+	var references []string
+	var fetched []int // positions of references in taglist
+	seen := make(map[string]bool)
+	for i, digest := range s.headIndexes(rest) {
+		if len(digest) != 0 {
+			_, known := s.Project.ItemByDigest[digest]
+			if known || seen[digest] {
+				digests[positions[i]] = digest
+				continue
+			}
+			seen[digest] = true
+		}
+		fetched = append(fetched, positions[i])
+		references = append(references, rest[i])
+	}
+	for i, result := range s.fetchIndexes(references) {
+		results[fetched[i]] = &fetchResult{regres: result.regres, err: result.err}
+	}
+	return results, digests
 }
 
-func (cc *Config) InspectCnab(cl *client.RegClient) {
+// listTags get current tags list of project repository
+
+func (s *Session) listTags() ([]string, error) {
 
 	// do request and get current tags list of cnab project
-	regres, err := cl.GetTagList()
+	regres, err := s.Client.GetTagList()
 	if err != nil {
-		errLine := fmt.Sprintf("failed to fetch tag list %s", err.Error())
-		logging.Fatal(errLine)
+		err = fmt.Errorf("failed to fetch tag list %w", err)
+		s.Config.Logger.Error(err.Error())
+		return nil, err
 	}
-	logging.Debug(fmt.Sprintf("Response with Tag List %+v", regres))
+	s.Config.Logger.Debug(fmt.Sprintf("Response with Tag List %+v", regres))
 
 	// check entry call - it must be correct cnab index
 	js, err := logging.PrettyString(regres.Content)
 	if err != nil {
-		errLine := fmt.Sprintf("invalid context, %+v", err.Error())
-		logging.Fatal(errLine)
+		err = fmt.Errorf("%w: invalid context, %+v", ErrTagListInvalid, err.Error())
+		s.Config.Logger.Error(err.Error())
+		return nil, err
 	}
 
 	// at first check if manifests is exists
 	tags, keytype, _, err := jsonparser.Get(([]byte)(js), "tags")
 	if err != nil {
-		errLine := fmt.Sprintf("json isn't contain tags key, %+v", err.Error())
-		logging.Fatal(errLine)
+		err = fmt.Errorf("%w: json isn't contain tags key, %+v", ErrTagListInvalid, err.Error())
+		s.Config.Logger.Error(err.Error())
+		return nil, err
 	}
-	if data.Gc.Verbosity <= logging.LogInfoLevel {
+	if s.Config.Logger.Verbosity() <= logging.LogInfoLevel {
 		// avoid double logging
-		logging.Info(fmt.Sprintf("Project tags list %+v", string(tags)))
+		s.Config.Logger.Info(fmt.Sprintf("Project tags list %+v", string(tags)))
 	}
 	if keytype.String() != "array" {
-		errLine := fmt.Sprintf("manifests key must contain array %+v", string(tags))
-		logging.Fatal(errLine)
+		err := fmt.Errorf("%w: tags key must contain array %+v", ErrTagListInvalid, string(tags))
+		s.Config.Logger.Error(err.Error())
+		return nil, err
 	}
 
 	// parse tags
-	i := 0
-	for true {
+	var taglist []string
+	for i := 0; ; i++ {
 		val, err := jsonparser.GetString(([]byte)(js), "tags", "["+strconv.Itoa(i)+"]")
 		if err != nil {
 			break
 		}
-		logging.Debug(fmt.Sprintf("Get item %d with tag %+v\n", i, val))
+		s.Config.Logger.Debug(fmt.Sprintf("Get item %d with tag %+v\n", i, val))
+		taglist = append(taglist, val)
+	}
+	return taglist, nil
+}
 
-		i++
-		cl.Digest = ""
-		cl.Tag = val
-		// get index by tag
-		regres, err := cl.GetRegIndex()
+// InspectCnab walk all tags of project repository and link items of cnab indexes
+
+func (s *Session) InspectCnab() error {
+
+	taglist, err := s.listTags()
+	if err != nil {
+		return err
+	}
+
+	// get indexes by tags concurrently, but register them in tags order
+	results, digests := s.fetchTags(taglist)
+	failed := 0
+	var invalid error // the first tag, which content is not valid
+	for i, val := range taglist {
+		result := results[i]
+		if result == nil {
+			ri, ok := s.Project.ItemByDigest[digests[i]]
+			if !ok {
+				s.Config.Logger.Error(fmt.Sprintf("can't fetch index for tar %s, manifest %s was not fetched", val, digests[i]))
+				failed++
+				continue
+			}
+			s.Config.Logger.Debug(fmt.Sprintf("tag %s is known manifest %s", val, digests[i]))
+			s.Project.ItemByTag[val] = ri
+			continue
+		}
+		if result.err != nil {
+			errLine := fmt.Sprintf("can't fetch index for tar %s, %+v", val, result.err.Error())
+			s.Config.Logger.Error(errLine)
+			failed++
+			continue
+		}
+		regres := result.regres
+		s.Config.Logger.Debug(fmt.Sprintf("Content %+v", regres))
+		switch regres.Media {
+		case client.MediaTypeOciIndex:
+			// cnab
+			err = s.AddCnab(regres, val)
+		case client.MediaTypeV2List:
+			// multi-arch image
+			var ri *data.RegIndex
+			if ri, err = s.AddIndex(regres, val); err == nil && len(ri.DownLinks) == 0 {
+				s.addDownLinks(ri, false)
+			}
+		default:
+			_, err = s.AddIndex(regres, val)
+		}
 		if err != nil {
-			errLine := fmt.Sprintf("can't fetch index for tar %s, %+v", val, err.Error())
-			logging.Error(errLine)
-		} else {
-			logging.Debug(fmt.Sprintf("Content %+v", regres))
-			switch regres.Media {
-			case client.MediaTypeOciIndex:
-				// cnab
-				AddCnab(regres, val)
-			default:
-				AddIndex(regres, val)
+			s.Config.Logger.Error(fmt.Sprintf("can't add index for tag %s, %+v", val, err.Error()))
+			failed++
+			if invalid == nil {
+				invalid = fmt.Errorf("tag %s: %w", val, err)
 			}
 		}
 	}
 
+	// DownLinks not found by digest are fetched directly from the registry.
+	// This handles "untagged" manifests (config, invocation, etc.) that exist in the
+	// OCI Image Index but have no corresponding tag. Nested image indexes and manifest
+	// lists (e.g. multi-arch invocation images) are walked level by level to any depth.
+	var parents, nested []*data.RegIndex
+	seen := make(map[string]bool)
+	for _, item := range s.rootItems() {
+		if item.Annotation != data.ItemTypeCnab && item.Annotation != data.ItemTypeIndex {
+			continue
+		}
+		seen[item.Digest] = true
+		parents = append(parents, item)
+		if item.Annotation == data.ItemTypeIndex {
+			nested = append(nested, item)
+		}
+	}
+	nested = append(nested, s.expandIndexes(parents, seen)...)
+	s.linkPlatforms(nested)
+
 	// scan cnab indexes and mark used resources
-	for _, item := range data.ItemByTag { // for all tags
+	for _, item := range s.rootItems() { // for all tagged items
 		if item.Annotation == data.ItemTypeCnab { // chose cnab only
 			for _, link := range item.DownLinks { // for all down links from selected cnab
-				cri, ok := data.ItemByDigest[link.Digest] // try to get item by digest from down link
-				if ok {
-					// if the uplink has already been registered, it does not need to be re-registered
-					needed := true
-					for _, uplink := range cri.UpLinks {
-						if uplink.Digest == item.Digest {
-							needed = false
-						}
-					}
-					if needed {
-						//logging.Info(fmt.Sprintf("For cnab %s component %s add uplink", item.Tag, link.Digest))
-						cri.UpLinks = append(cri.UpLinks, data.CnabItem{Digest: item.Digest, Annotation: item.Annotation})
-						cri.Annotation = link.Annotation
-					}
-				} else {
-					// DownLink not found by digest — try fetching it directly from the registry.
-					// This handles "untagged" manifests (config, invocation, etc.) that exist in the
-					// OCI Image Index but have no corresponding tag.
-					cl.Digest = link.Digest
-					cl.Tag = ""
-					regres, err := cl.GetRegIndex()
-					if err != nil || regres.Status != 200 {
-						logging.Error(fmt.Sprintf("For cnab %s component %s was not found by digest %s: %v (status %d)", item.Tag, link.Digest, link.Digest, err, regres.Status))
-						item.Lost++
-						continue
-					}
-
-					// Register the fetched manifest in the global maps
-					AddIndex(regres, "")
-					// Re-lookup by the response digest (may differ from request digest)
-					cri, ok = data.ItemByDigest[regres.Digest]
-					if ok {
-						cri.UpLinks = append(cri.UpLinks, data.CnabItem{Digest: item.Digest, Annotation: item.Annotation})
-						cri.Annotation = link.Annotation
-					}
+				cri, ok := s.Project.ItemByDigest[link.Digest] // try to get item by digest from down link
+				if !ok {
+					s.Config.Logger.Error(fmt.Sprintf("For cnab %s component %s was not found by digest %s", item.Tag, link.Annotation, link.Digest))
+					item.Lost++
+					continue
+				}
+				// if the uplink has already been registered, it does not need to be re-registered
+				if !hasLink(cri.UpLinks, item.Digest) {
+					//s.Config.Logger.Info(fmt.Sprintf("For cnab %s component %s add uplink", item.Tag, link.Digest))
+					cri.UpLinks = append(cri.UpLinks, data.CnabItem{Digest: item.Digest, Annotation: item.Annotation})
+					cri.Annotation = link.Annotation
 				}
 			}
 		}
 	}
-	// here data.ProjectList made completely!
-}
+	// here s.Project.ProjectList made completely!
 
-func (cc *Config) ShowCnabReport(cl *client.RegClient) {
+	// bundle.json of every cnab goes to report
+	unread := s.ReadBundles()
 
-	if data.Gc.Verbosity >= logging.LogNormalLevel {
+	// images of bundle.json complete the graph
+	unfollowed := 0
+	if s.Config.FollowImages {
+		unfollowed = s.FollowImages()
+	}
 
-		if cc.Raw { // very long output
-			i := 1
-			for tag, item := range data.ItemByTag {
-				fmt.Printf("Project item %d: %s ---- %+v\n\n\n", i, tag, item)
-				i++
+	s.partial = nil
+	if failed != 0 || unread != 0 || unfollowed != 0 {
+		s.partial = fmt.Errorf("%w: %d of %d tags were not fetched, %d bundles were not read, %d images were not fetched", ErrPartial, failed, len(taglist), unread, unfollowed)
+		if invalid != nil {
+			s.partial = fmt.Errorf("%w, %w", s.partial, invalid)
+		}
+	}
+	return s.partial
+}
+
+// expandIndexes fetch children of parents, which are not known yet, and walk nested
+// image indexes and manifest lists level by level to any depth. Children live in
+// repository of their parent. Return walked nested indexes, parents are not included
+
+func (s *Session) expandIndexes(parents []*data.RegIndex, seen map[string]bool) []*data.RegIndex {
+	var nested []*data.RegIndex
+	for len(parents) != 0 {
+		var missing []bundleImage
+		for _, item := range parents {
+			origin := s.origin(item)
+			for _, link := range item.DownLinks {
+				if _, ok := s.Project.ItemByDigest[link.Digest]; !ok && !seen[link.Digest] {
+					seen[link.Digest] = true
+					missing = append(missing, bundleImage{Registry: origin.Registry, Repository: origin.Repository, Digest: link.Digest})
+				}
 			}
-		} else {
-			// translate ProjectList to shortOutput
-			type shortListItem struct {
-				Tag        string `json:"tag"`
-				Digest     string `json:"digest"`
-				Annotation string `json:"annotation"`
-				Date       string `json:"date"`
-				Media      string `json:"media"`
-				Count      int    `json:"count"`
-				Links      int    `json:"links"`
-				Lost       int    `json:"lost"`
+		}
+		parents = nil
+		for i, result := range s.fetchImages(missing) {
+			if result.err != nil || result.regres.Status != 200 {
+				s.Config.Logger.Debug(fmt.Sprintf("component was not fetched by digest %s: %v (status %d)", missing[i].Digest, result.err, result.regres.Status))
+				continue
 			}
-			type shortOutput struct {
-				Reference string          `json:"reference"`
-				Shortlist []shortListItem `json:"itemList"`
+			// Register the fetched manifest in the global maps
+			ri, err := s.AddIndex(result.regres, "")
+			if err != nil {
+				continue
 			}
-			i := 1
-			sl := shortOutput{
-				Reference: data.ProjectList[0].Reference,
-				Shortlist: nil,
+			if s.foreign(missing[i]) {
+				ri.Repository = missing[i].Registry + "/" + missing[i].Repository
 			}
-			for tag, item := range data.ItemByTag {
-				i++
-				sl.Shortlist = append(sl.Shortlist, shortListItem{
-					Tag:        tag,
-					Digest:     item.Digest,
-					Annotation: item.Annotation,
-					Date:       item.Date,
-					Media:      item.Media,
-					Count:      len(item.UpLinks),
-					Links:      len(item.DownLinks),
-					Lost:       item.Lost,
-				})
+			if !isIndexMedia(ri.Media) {
+				continue
 			}
-			// print shortOutput
-			slout, err := json.Marshal(sl)
-			if err == nil {
-				if jsonres, err := logging.PrettyString(string(slout)); err == nil {
-					fmt.Println(jsonres)
-				} else {
-					// print as is
-					fmt.Printf("%+v\n", sl)
-				}
-			} else {
-				logging.Fatal(fmt.Sprintf("can not convert list item - %+v\n", err.Error()))
+			// children of nested index go to the next level
+			ri.Annotation = data.ItemTypeIndex
+			s.addDownLinks(ri, false)
+			parents = append(parents, ri)
+			nested = append(nested, ri)
+		}
+	}
+	return nested
+}
+
+// linkPlatforms link children of nested indexes with their platforms
+
+func (s *Session) linkPlatforms(nested []*data.RegIndex) {
+	for _, item := range nested {
+		for _, link := range item.DownLinks {
+			cri, ok := s.Project.ItemByDigest[link.Digest]
+			if !ok {
+				s.Config.Logger.Error(fmt.Sprintf("For image index %s platform %s was not found by digest %s", item.Digest, link.Platform, link.Digest))
+				item.Lost++
+				continue
+			}
+			if !hasLink(cri.UpLinks, item.Digest) {
+				cri.UpLinks = append(cri.UpLinks, data.CnabItem{Digest: item.Digest, Annotation: item.Annotation, Platform: link.Platform})
+				cri.Annotation = link.Annotation
+				cri.Platform = link.Platform
 			}
-		} //format
-	} //logging
+		}
+	}
+}
+
+// hasLink check if digest is in links
+
+func hasLink(links []data.CnabItem, digest string) bool {
+	for _, link := range links {
+		if link.Digest == digest {
+			return true
+		}
+	}
+	return false
+}
+
+// short report item
+
+type ReportItem struct {
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+	Annotation string `json:"annotation"`
+	Date       string `json:"date"`
+	Media      string `json:"media"`
+	Count      int    `json:"count"`
+	Links      int    `json:"links"`
+	Lost       int    `json:"lost"`
+	Platform   string `json:"platform,omitempty"` // child of image index only
+	Size       int64  `json:"size"`               // bytes reachable from item
+	Unique     int64  `json:"unique"`             // bytes not shared with other tags
+
+	Bundle *data.Bundle `json:"bundle,omitempty"` // cnab index only
+}
+
+// short report of inspected project
+
+type Report struct {
+	Reference string       `json:"reference"`
+	Total     int64        `json:"total"` // bytes of repository, blobs are counted once
+	Shortlist []ReportItem `json:"itemList"`
+}
+
+// Report translate ProjectList to short report, items are sorted by tag
+
+func (s *Session) Report() Report {
+	var report Report
+	if len(s.Project.ProjectList) != 0 {
+		report.Reference = s.Project.ProjectList[0].Reference
+	}
+	sizes, total := s.Sizes()
+	report.Total = total
+	for _, tag := range s.SortedTags() {
+		item := s.Project.ItemByTag[tag]
+		report.Shortlist = append(report.Shortlist, ReportItem{
+			Tag:        tag,
+			Digest:     item.Digest,
+			Annotation: item.Annotation,
+			Date:       item.Date,
+			Media:      item.Media,
+			Count:      len(item.UpLinks),
+			Links:      len(item.DownLinks),
+			Lost:       item.Lost,
+			Platform:   item.Platform,
+			Size:       sizes[tag].Size,
+			Unique:     sizes[tag].Unique,
+			Bundle:     item.Bundle,
+		})
+	}
+	return report
 }
@@ -3,79 +3,43 @@ package content
 import (
 	"cnabtool/pkg/client"
 	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
-)
+	"time"
 
-// saveGlobalState сохраняет текущее состояние data для восстановления
-func saveGlobalState() *globalStateSnapshot {
-	return &globalStateSnapshot{
-		Gc:           data.Gc,
-		Sensitives:   data.Sensitives,
-		ItemsQueue:   data.ItemsQueue,
-		ProjectList:  data.ProjectList,
-		ItemByDigest: data.ItemByDigest,
-		ItemByTag:    data.ItemByTag,
-		Scheme:       data.Scheme,
-		Registry:     data.Registry,
-		Repository:   data.Repository,
-	}
-}
-
-// globalStateSnapshot хранит снимок глобального состояния
-type globalStateSnapshot struct {
-	Gc           *data.Config
-	Sensitives   []string
-	ItemsQueue   []string
-	ProjectList  []*data.RegIndex
-	ItemByDigest map[string]*data.RegIndex
-	ItemByTag    map[string]*data.RegIndex
-	Scheme       string
-	Registry     string
-	Repository   string
-}
+	"github.com/buger/jsonparser"
+)
 
-func restoreGlobalState(snap *globalStateSnapshot) {
-	data.Gc = snap.Gc
-	data.Sensitives = snap.Sensitives
-	data.ItemsQueue = snap.ItemsQueue
-	data.ProjectList = snap.ProjectList
-	data.ItemByDigest = snap.ItemByDigest
-	data.ItemByTag = snap.ItemByTag
-	data.Scheme = snap.Scheme
-	data.Registry = snap.Registry
-	data.Repository = snap.Repository
+// digestOf вычисляет sha256 digest тестового манифеста
+func digestOf(content string) string {
+	digest, _ := client.ComputeDigest(client.DigestSha256, []byte(content))
+	return digest
 }
 
-// resetGlobalState очищает глобальное состояние data перед тестом
-func resetGlobalState(t *testing.T) {
+// newTestSession создаёт сессию с пустым графом проекта
+func newTestSession(t *testing.T) *Session {
 	t.Helper()
-	data.Gc = &data.Config{
-		Verbosity: 4,
-		Timeout:   10000,
+	cfg := &Config{
+		Logger:  logging.New(logging.LogDebugLevel),
+		Timeout: 10000,
 		Credentials: data.Credentials{
 			Username: "testuser",
 			Password: "testpass",
 		},
 	}
-	data.ItemsQueue = nil
-	data.ProjectList = nil
-	data.ItemByDigest = make(map[string]*data.RegIndex)
-	data.ItemByTag = make(map[string]*data.RegIndex)
-	data.Sensitives = []string{"testpass"}
-	data.Scheme = "http"
-	data.Registry = ""
-	data.Repository = ""
+	return NewSession(cfg, client.NewRegClient((*client.Config)(cfg), "test"))
 }
 
 // TestAddIndex_NewIndex проверяет создание нового RegIndex
 func TestAddIndex_NewIndex(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	regres := &client.RegResponse{
 		Reference: "registry.example.com/repo/image:v1",
@@ -85,7 +49,7 @@ func TestAddIndex_NewIndex(t *testing.T) {
 		Content:   `{"schemaVersion":2,"manifests":[]}`,
 	}
 
-	ri, err := AddIndex(regres, "v1")
+	ri, err := s.AddIndex(regres, "v1")
 	if err != nil {
 		t.Fatalf("AddIndex should not return error, got: %v", err)
 	}
@@ -112,22 +76,29 @@ func TestAddIndex_NewIndex(t *testing.T) {
 		t.Errorf("ri.Date = %q, want %q", ri.Date, "Mon, 01 Jan 2024 00:00:00 GMT")
 	}
 
-	if data.ItemByDigest["sha256:abc123"] != ri {
+	if s.Project.ItemByDigest["sha256:abc123"] != ri {
 		t.Error("ItemByDigest['sha256:abc123'] should contain the RegIndex")
 	}
-	if data.ItemByTag["v1"] != ri {
+	if s.Project.ItemByTag["v1"] != ri {
 		t.Error("ItemByTag['v1'] should contain the RegIndex")
 	}
-	if len(data.ProjectList) != 1 {
-		t.Errorf("ProjectList length = %d, want 1", len(data.ProjectList))
+	if len(s.Project.ProjectList) != 1 {
+		t.Errorf("ProjectList length = %d, want 1", len(s.Project.ProjectList))
+	}
+
+	// элемент без тега не занимает пустой тег
+	untagged, err := s.AddIndex(&client.RegResponse{Media: client.MediaTypeOciManifest, Digest: "sha256:untagged", Content: `{"schemaVersion":2}`}, "")
+	if err != nil || s.Project.ItemByDigest["sha256:untagged"] != untagged {
+		t.Fatalf("AddIndex without tag = %+v, %v, want registered item", untagged, err)
+	}
+	if _, ok := s.Project.ItemByTag[""]; ok || len(s.Project.ItemByTag) != 1 {
+		t.Errorf("ItemByTag = %+v, want v1 only", s.Project.ItemByTag)
 	}
 }
 
 // TestAddIndex_DuplicateIndex проверяет обработку существующего индекса
 func TestAddIndex_DuplicateIndex(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	regres1 := &client.RegResponse{
 		Reference: "registry.example.com/repo/image:v1",
@@ -136,7 +107,7 @@ func TestAddIndex_DuplicateIndex(t *testing.T) {
 		Content:   `{"schemaVersion":2}`,
 	}
 
-	ri1, err := AddIndex(regres1, "v1")
+	ri1, err := s.AddIndex(regres1, "v1")
 	if err != nil {
 		t.Fatalf("First AddIndex should not return error, got: %v", err)
 	}
@@ -148,7 +119,7 @@ func TestAddIndex_DuplicateIndex(t *testing.T) {
 		Content:   `{"schemaVersion":2,"different":"content"}`,
 	}
 
-	ri2, err := AddIndex(regres2, "v2")
+	ri2, err := s.AddIndex(regres2, "v2")
 	if err != nil {
 		t.Fatalf("Second AddIndex should not return error, got: %v", err)
 	}
@@ -157,20 +128,18 @@ func TestAddIndex_DuplicateIndex(t *testing.T) {
 		t.Error("AddIndex should return the same RegIndex for duplicate digest")
 	}
 
-	if len(data.ProjectList) != 1 {
-		t.Errorf("ProjectList length = %d, want 1 (duplicate should not add)", len(data.ProjectList))
+	if len(s.Project.ProjectList) != 1 {
+		t.Errorf("ProjectList length = %d, want 1 (duplicate should not add)", len(s.Project.ProjectList))
 	}
 
-	if data.ItemByTag["v2"] != ri1 {
+	if s.Project.ItemByTag["v2"] != ri1 {
 		t.Error("ItemByTag['v2'] should point to the existing RegIndex")
 	}
 }
 
 // TestAddIndex_MediaTypeAnnotationMapping проверяет маппинг media type → annotation
 func TestAddIndex_MediaTypeAnnotationMapping(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	testcases := []struct {
 		media    string
@@ -209,7 +178,7 @@ func TestAddIndex_MediaTypeAnnotationMapping(t *testing.T) {
 				Content:   `{"test":true}`,
 			}
 
-			ri, err := AddIndex(regres, "v1")
+			ri, err := s.AddIndex(regres, "v1")
 			if err != nil {
 				t.Fatalf("AddIndex should not return error, got: %v", err)
 			}
@@ -223,9 +192,7 @@ func TestAddIndex_MediaTypeAnnotationMapping(t *testing.T) {
 
 // TestAddCnab_ValidManifest проверяет разбор валидного OCI index
 func TestAddCnab_ValidManifest(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	ociIndex := `{
 		"schemaVersion": 2,
@@ -263,12 +230,12 @@ func TestAddCnab_ValidManifest(t *testing.T) {
 		Content:   ociIndex,
 	}
 
-	err := AddCnab(regres, "v1")
+	err := s.AddCnab(regres, "v1")
 	if err != nil {
 		t.Fatalf("AddCnab should not return error, got: %v", err)
 	}
 
-	ri := data.ItemByDigest["sha256:parent123"]
+	ri := s.Project.ItemByDigest["sha256:parent123"]
 	if ri == nil {
 		t.Fatal("RegIndex not found in ItemByDigest")
 	}
@@ -277,16 +244,14 @@ func TestAddCnab_ValidManifest(t *testing.T) {
 		t.Errorf("DownLinks length = %d, want 3", len(ri.DownLinks))
 	}
 
-	if len(data.ItemsQueue) != 3 {
-		t.Errorf("ItemsQueue length = %d, want 3", len(data.ItemsQueue))
+	if len(s.Project.ItemsQueue) != 3 {
+		t.Errorf("ItemsQueue length = %d, want 3", len(s.Project.ItemsQueue))
 	}
 }
 
 // TestAddCnab_EmptyManifests проверяет обработку пустого manifests
 func TestAddCnab_EmptyManifests(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	regres := &client.RegResponse{
 		Reference: "registry.example.com/repo/cnab:v1",
@@ -295,12 +260,12 @@ func TestAddCnab_EmptyManifests(t *testing.T) {
 		Content:   `{"schemaVersion":2,"manifests":[]}`,
 	}
 
-	err := AddCnab(regres, "v1")
+	err := s.AddCnab(regres, "v1")
 	if err != nil {
 		t.Fatalf("AddCnab should not return error for empty manifests, got: %v", err)
 	}
 
-	ri := data.ItemByDigest["sha256:empty123"]
+	ri := s.Project.ItemByDigest["sha256:empty123"]
 	if ri == nil {
 		t.Fatal("RegIndex not found")
 	}
@@ -312,9 +277,7 @@ func TestAddCnab_EmptyManifests(t *testing.T) {
 
 // TestAddCnab_MissingManifestsKey проверяет ошибку при отсутствии manifests
 func TestAddCnab_MissingManifestsKey(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	regres := &client.RegResponse{
 		Reference: "registry.example.com/repo/cnab:v1",
@@ -323,17 +286,35 @@ func TestAddCnab_MissingManifestsKey(t *testing.T) {
 		Content:   `{"schemaVersion":2}`,
 	}
 
-	err := AddCnab(regres, "v1")
-	if err == nil {
-		t.Error("AddCnab should return error when manifests key is missing")
+	err := s.AddCnab(regres, "v1")
+	if !errors.Is(err, ErrNotCnabIndex) {
+		t.Errorf("AddCnab error = %v, want ErrNotCnabIndex when manifests key is missing", err)
+	}
+}
+
+// TestAddCnab_InvalidContent проверяет, что невалидный json не регистрируется в графе
+func TestAddCnab_InvalidContent(t *testing.T) {
+	s := newTestSession(t)
+
+	regres := &client.RegResponse{
+		Reference: "registry.example.com/repo/cnab:v1",
+		Media:     client.MediaTypeOciIndex,
+		Digest:    "sha256:broken",
+		Content:   `{"schemaVersion":2,"manifests":[`,
+	}
+
+	err := s.AddCnab(regres, "v1")
+	if !errors.Is(err, ErrNotCnabIndex) {
+		t.Errorf("AddCnab error = %v, want ErrNotCnabIndex for invalid json", err)
+	}
+	if len(s.Project.ProjectList) != 0 || s.Project.ItemByDigest["sha256:broken"] != nil || s.Project.ItemByTag["v1"] != nil {
+		t.Errorf("invalid manifest should not be registered, got %+v", s.Project)
 	}
 }
 
 // TestAddCnab_NotArrayManifests проверяет ошибку, если manifests не массив
 func TestAddCnab_NotArrayManifests(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	regres := &client.RegResponse{
 		Reference: "registry.example.com/repo/cnab:v1",
@@ -342,17 +323,15 @@ func TestAddCnab_NotArrayManifests(t *testing.T) {
 		Content:   `{"schemaVersion":2,"manifests":{"digest":"sha256:x"}}`,
 	}
 
-	err := AddCnab(regres, "v1")
-	if err == nil {
-		t.Error("AddCnab should return error when manifests is not an array")
+	err := s.AddCnab(regres, "v1")
+	if !errors.Is(err, ErrNotCnabIndex) {
+		t.Errorf("AddCnab error = %v, want ErrNotCnabIndex when manifests is not an array", err)
 	}
 }
 
 // TestAddCnab_ComponentAnnotation проверяет special handling component annotation
 func TestAddCnab_ComponentAnnotation(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	regres := &client.RegResponse{
 		Reference: "registry.example.com/repo/cnab:v1",
@@ -373,12 +352,12 @@ func TestAddCnab_ComponentAnnotation(t *testing.T) {
 		}`,
 	}
 
-	err := AddCnab(regres, "v1")
+	err := s.AddCnab(regres, "v1")
 	if err != nil {
 		t.Fatalf("AddCnab should not return error, got: %v", err)
 	}
 
-	ri := data.ItemByDigest["sha256:comp123"]
+	ri := s.Project.ItemByDigest["sha256:comp123"]
 	if ri == nil {
 		t.Fatal("RegIndex not found")
 	}
@@ -394,43 +373,11 @@ func TestAddCnab_ComponentAnnotation(t *testing.T) {
 
 // TestInspectCnab_FullFlow проверяет полный цикл InspectCnab через httptest
 func TestInspectCnab_FullFlow(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
-
 	tagsList := `{
 		"name": "repo/cnab",
 		"tags": ["v1.0", "v2.0"]
 	}`
 
-	ociIndexV1 := `{
-		"schemaVersion": 2,
-		"mediaType": "application/vnd.oci.image.index.v1+json",
-		"manifests": [
-			{
-				"digest": "sha256:config_v1",
-				"mediaType": "application/vnd.oci.image.manifest.v1+json",
-				"annotations": {
-					"io.cnab.manifest.type": "config"
-				}
-			}
-		]
-	}`
-
-	ociIndexV2 := `{
-		"schemaVersion": 2,
-		"mediaType": "application/vnd.oci.image.index.v1+json",
-		"manifests": [
-			{
-				"digest": "sha256:config_v2",
-				"mediaType": "application/vnd.oci.image.manifest.v1+json",
-				"annotations": {
-					"io.cnab.manifest.type": "config"
-				}
-			}
-		]
-	}`
-
 	configManifestV1 := `{
 		"schemaVersion": 2,
 		"mediaType": "application/vnd.oci.image.manifest.v1+json",
@@ -447,6 +394,30 @@ func TestInspectCnab_FullFlow(t *testing.T) {
 		}
 	}`
 
+	// индексы ссылаются на настоящие digest конфигурационных манифестов
+	ociIndex := `{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.oci.image.index.v1+json",
+		"manifests": [
+			{
+				"digest": "%s",
+				"mediaType": "application/vnd.oci.image.manifest.v1+json",
+				"annotations": {
+					"io.cnab.manifest.type": "config"
+				}
+			}
+		]
+	}`
+	ociIndexV1 := fmt.Sprintf(ociIndex, digestOf(configManifestV1))
+	ociIndexV2 := fmt.Sprintf(ociIndex, digestOf(configManifestV2))
+
+	manifests := map[string]string{
+		"v1.0":                     ociIndexV1,
+		"v2.0":                     ociIndexV2,
+		digestOf(configManifestV1): configManifestV1,
+		digestOf(configManifestV2): configManifestV2,
+	}
+
 	requestCount := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestCount++
@@ -454,26 +425,15 @@ func TestInspectCnab_FullFlow(t *testing.T) {
 
 		w.Header().Set("Content-Type", "application/json")
 
+		_, reference, _ := strings.Cut(path, "/manifests/")
 		switch {
 		case strings.HasSuffix(path, "/tags/list/"):
 			w.WriteHeader(200)
 			w.Write([]byte(tagsList))
-		case strings.HasSuffix(path, "/manifests/v1.0"):
-			w.Header().Set("Docker-Content-Digest", "sha256:oci_v1")
-			w.WriteHeader(200)
-			w.Write([]byte(ociIndexV1))
-		case strings.HasSuffix(path, "/manifests/v2.0"):
-			w.Header().Set("Docker-Content-Digest", "sha256:oci_v2")
-			w.WriteHeader(200)
-			w.Write([]byte(ociIndexV2))
-		case strings.HasSuffix(path, "/manifests/sha256:config_v1"):
-			w.Header().Set("Docker-Content-Digest", "sha256:config_v1")
+		case len(manifests[reference]) != 0:
+			w.Header().Set("Docker-Content-Digest", digestOf(manifests[reference]))
 			w.WriteHeader(200)
-			w.Write([]byte(configManifestV1))
-		case strings.HasSuffix(path, "/manifests/sha256:config_v2"):
-			w.Header().Set("Docker-Content-Digest", "sha256:config_v2")
-			w.WriteHeader(200)
-			w.Write([]byte(configManifestV2))
+			w.Write([]byte(manifests[reference]))
 		default:
 			w.WriteHeader(404)
 			w.Write([]byte(`{"error":"not found"}`))
@@ -482,6 +442,7 @@ func TestInspectCnab_FullFlow(t *testing.T) {
 	defer server.Close()
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogDebugLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -497,15 +458,17 @@ func TestInspectCnab_FullFlow(t *testing.T) {
 	cl.Tag = "v1.0"
 	cl.Digest = ""
 
-	cnf := (*Config)(cfg)
-	cnf.InspectCnab(cl)
+	s := NewSession((*Config)(cfg), cl)
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
 
-	if len(data.ItemByTag) < 2 {
-		t.Errorf("ItemByTag length = %d, want at least 2", len(data.ItemByTag))
+	if len(s.Project.ItemByTag) < 2 {
+		t.Errorf("ItemByTag length = %d, want at least 2", len(s.Project.ItemByTag))
 	}
 
-	if len(data.ProjectList) < 2 {
-		t.Errorf("ProjectList length = %d, want at least 2", len(data.ProjectList))
+	if len(s.Project.ProjectList) < 2 {
+		t.Errorf("ProjectList length = %d, want at least 2", len(s.Project.ProjectList))
 	}
 
 	if requestCount < 3 {
@@ -515,10 +478,6 @@ func TestInspectCnab_FullFlow(t *testing.T) {
 
 // TestInspectCnab_EmptyTagsList проверяет обработку пустого списка тегов
 func TestInspectCnab_EmptyTagsList(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
-
 	tagsList := `{
 		"name": "repo/cnab",
 		"tags": []
@@ -532,6 +491,7 @@ func TestInspectCnab_EmptyTagsList(t *testing.T) {
 	defer server.Close()
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogDebugLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -546,156 +506,113 @@ func TestInspectCnab_EmptyTagsList(t *testing.T) {
 	cl.Repository = "repo/cnab"
 	cl.Tag = "v1"
 
-	cnf := (*Config)(cfg)
-	cnf.InspectCnab(cl)
+	s := NewSession((*Config)(cfg), cl)
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
 
 	// Пустой список тегов → ничего не добавляется в ItemByTag
-	if len(data.ItemByTag) != 0 {
-		t.Errorf("ItemByTag length = %d, want 0", len(data.ItemByTag))
+	if len(s.Project.ItemByTag) != 0 {
+		t.Errorf("ItemByTag length = %d, want 0", len(s.Project.ItemByTag))
 	}
 }
 
-// TestShowCnabReport_ValidData проверяет генерацию JSON-отчёта
-func TestShowCnabReport_ValidData(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+// TestInspectCnab_UntaggedRoot проверяет, что cnab, открытый по digest без тега, обходится, но не получает пустой тег
+func TestInspectCnab_UntaggedRoot(t *testing.T) {
 
-	ri1 := &data.RegIndex{
-		Reference:  "registry.example.com/repo/cnab:v1",
-		Tag:        "v1",
-		Digest:     "sha256:parent123",
-		Media:      client.MediaTypeOciIndex,
-		Annotation: data.ItemTypeCnab,
-		Date:       "Mon, 01 Jan 2024 00:00:00 GMT",
-		Content:    `{"schemaVersion":2}`,
-		DownLinks: []data.CnabItem{
-			{Digest: "sha256:config123", Annotation: "config"},
-			{Digest: "sha256:invocation456", Annotation: "invocation"},
-		},
-		UpLinks: []data.CnabItem{
-			{Digest: "sha256:grandparent", Annotation: "cnab index"},
-		},
-	}
+	config := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"sha256:config"}}`
+	cnab := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"%s","mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"io.cnab.manifest.type":"config"}}]}`, digestOf(config))
+	manifests := map[string]string{digestOf(config): config, digestOf(cnab): cnab}
 
-	ri2 := &data.RegIndex{
-		Reference:  "registry.example.com/repo/cnab:v2",
-		Tag:        "v2",
-		Digest:     "sha256:parent456",
-		Media:      client.MediaTypeOciIndex,
-		Annotation: data.ItemTypeCnab,
-		Date:       "Tue, 01 Jan 2024 00:00:00 GMT",
-		Content:    `{"schemaVersion":2}`,
-		DownLinks: []data.CnabItem{
-			{Digest: "sha256:config456", Annotation: "config"},
-		},
-	}
-
-	data.ProjectList = []*data.RegIndex{ri1, ri2}
-	data.ItemByDigest["sha256:parent123"] = ri1
-	data.ItemByDigest["sha256:parent456"] = ri2
-	data.ItemByTag["v1"] = ri1
-	data.ItemByTag["v2"] = ri2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list/") {
+			w.Write([]byte(`{"name":"repo/cnab","tags":[]}`))
+			return
+		}
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		content, ok := manifests[reference]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		media, _ := jsonparser.GetString([]byte(content), "mediaType")
+		w.Header().Set("Content-Type", media)
+		w.Header().Set("Docker-Content-Digest", digestOf(content))
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
 
-	cfg := &data.Config{
-		Verbosity: 3,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
+	cfg := &data.Config{Scheme: "http", Timeout: 10000}
+	s, regres, err := (*Config)(cfg).OpenSession(strings.TrimPrefix(server.URL, "http://") + "/repo/cnab@" + digestOf(cnab))
+	if err != nil {
+		t.Fatalf("OpenSession should not return error, got: %v", err)
 	}
-	data.Gc = cfg
-
-	cl := &client.RegClient{
-		Reference:  "registry.example.com/repo/cnab:v1",
-		Registry:   "registry.example.com",
-		Repository: "repo/cnab",
-		Tag:        "v1",
+	if err := s.AddCnab(regres, s.Client.Tag); err != nil {
+		t.Fatalf("AddCnab should not return error, got: %v", err)
 	}
-
-	cnf := (*Config)(cfg)
-	cnf.ShowCnabReport(cl)
-}
-
-// TestShowCnabReport_RawMode проверяет raw-режим вывода
-func TestShowCnabReport_RawMode(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
-
-	ri := &data.RegIndex{
-		Reference:  "registry.example.com/repo/cnab:v1",
-		Tag:        "v1",
-		Digest:     "sha256:parent123",
-		Media:      client.MediaTypeOciIndex,
-		Annotation: data.ItemTypeCnab,
-		Content:    `{"test":true}`,
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
 	}
 
-	data.ItemByTag["v1"] = ri
-	data.ProjectList = []*data.RegIndex{ri}
-
-	cfg := &data.Config{
-		Verbosity: 3,
-		Raw:       true,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
+	if len(s.Project.ItemByTag) != 0 {
+		t.Errorf("ItemByTag = %+v, want no tags", s.Project.ItemByTag)
 	}
-	data.Gc = cfg
-
-	cl := &client.RegClient{
-		Reference:  "registry.example.com/repo/cnab:v1",
-		Registry:   "registry.example.com",
-		Repository: "repo/cnab",
+	root := s.Project.ItemByDigest[digestOf(cnab)]
+	component, ok := s.Project.ItemByDigest[digestOf(config)]
+	if !ok || !hasLink(component.UpLinks, root.Digest) || root.Lost != 0 {
+		t.Errorf("config = %+v, root = %+v, want config linked to untagged root", component, root)
+	}
+	plan, err := s.PlanDelete()
+	if err != nil {
+		t.Fatalf("PlanDelete should not return error, got: %v", err)
+	}
+	if digests := planDigests(plan); len(digests) != 2 || digests[0] != digestOf(config) || digests[1] != root.Digest {
+		t.Errorf("plan = %+v, want config then root", digests)
 	}
-
-	cnf := (*Config)(cfg)
-	cnf.ShowCnabReport(cl)
 }
 
-// TestShowCnabReport_LowVerbosity проверяет отсутствие вывода при низком verbosity
-func TestShowCnabReport_LowVerbosity(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+// TestReport_SortedItems проверяет типизированный отчёт без вывода в stdout
+func TestReport_SortedItems(t *testing.T) {
+	s := newTestSession(t)
 
-	ri := &data.RegIndex{
-		Reference:  "registry.example.com/repo/cnab:v1",
-		Tag:        "v1",
+	cnab := &data.RegIndex{
+		Reference:  "registry.example.com/repo/cnab:v2",
+		Tag:        "v2",
 		Digest:     "sha256:parent123",
 		Media:      client.MediaTypeOciIndex,
 		Annotation: data.ItemTypeCnab,
+		DownLinks:  []data.CnabItem{{Digest: "sha256:config123"}, {Digest: "sha256:lost456"}},
+		Lost:       1,
 	}
-
-	data.ItemByTag["v1"] = ri
-	data.ProjectList = []*data.RegIndex{ri}
-
-	cfg := &data.Config{
-		Verbosity: 1, // LogErrorLevel - меньше LogNormalLevel
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
+	config := &data.RegIndex{
+		Tag:        "v1",
+		Digest:     "sha256:config123",
+		Annotation: data.ItemTypeConfig,
+		UpLinks:    []data.CnabItem{{Digest: "sha256:parent123"}},
 	}
-	data.Gc = cfg
+	s.Project.ProjectList = []*data.RegIndex{cnab, config}
+	s.Project.ItemByTag["v2"] = cnab
+	s.Project.ItemByTag["v1"] = config
 
-	cl := &client.RegClient{
-		Reference:  "registry.example.com/repo/cnab:v1",
-		Registry:   "registry.example.com",
-		Repository: "repo/cnab",
+	report := s.Report()
+	if report.Reference != cnab.Reference {
+		t.Errorf("Reference = %q, want %q", report.Reference, cnab.Reference)
+	}
+	if len(report.Shortlist) != 2 || report.Shortlist[0].Tag != "v1" || report.Shortlist[1].Tag != "v2" {
+		t.Fatalf("Shortlist = %+v, want v1, v2", report.Shortlist)
+	}
+	if report.Shortlist[0].Count != 1 || report.Shortlist[1].Links != 2 || report.Shortlist[1].Lost != 1 {
+		t.Errorf("Shortlist counters = %+v", report.Shortlist)
 	}
 
-	cnf := (*Config)(cfg)
-	cnf.ShowCnabReport(cl)
+	if empty := newTestSession(t).Report(); len(empty.Reference) != 0 || len(empty.Shortlist) != 0 {
+		t.Errorf("Report of empty project = %+v, want empty", empty)
+	}
 }
 
 // TestAddIndex_InvalidContent проверяет обработку невалидного JSON в Content
 func TestAddIndex_InvalidContent(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	regres := &client.RegResponse{
 		Reference: "registry.example.com/repo/image:v1",
@@ -704,7 +621,7 @@ func TestAddIndex_InvalidContent(t *testing.T) {
 		Content:   "{invalid json content!!!",
 	}
 
-	_, err := AddIndex(regres, "v1")
+	_, err := s.AddIndex(regres, "v1")
 	if err == nil {
 		t.Error("AddIndex should return error for invalid JSON content")
 	}
@@ -712,9 +629,7 @@ func TestAddIndex_InvalidContent(t *testing.T) {
 
 // TestAddIndex_PrettyContent проверяет, что Content сохраняется в pretty-формате
 func TestAddIndex_PrettyContent(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
+	s := newTestSession(t)
 
 	originalContent := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
 
@@ -725,7 +640,7 @@ func TestAddIndex_PrettyContent(t *testing.T) {
 		Content:   originalContent,
 	}
 
-	ri, err := AddIndex(regres, "v1")
+	ri, err := s.AddIndex(regres, "v1")
 	if err != nil {
 		t.Fatalf("AddIndex should not return error, got: %v", err)
 	}
@@ -740,12 +655,29 @@ func TestAddIndex_PrettyContent(t *testing.T) {
 	}
 }
 
+// TestInspectCnab_InvalidTagList проверяет ошибку ErrTagListInvalid вместо завершения процесса
+func TestInspectCnab_InvalidTagList(t *testing.T) {
+
+	for _, body := range []string{`{"name":"repo/cnab","tags":"v1"}`, `not json`} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+
+		cfg := &data.Config{Scheme: "http", Timeout: 10000}
+		cl := client.NewRegClient((*client.Config)(cfg), "test")
+		cl.Registry = strings.TrimPrefix(server.URL, "http://")
+		cl.Repository = "repo/cnab"
+
+		s := NewSession((*Config)(cfg), cl)
+		if err := s.InspectCnab(); !errors.Is(err, ErrTagListInvalid) {
+			t.Errorf("InspectCnab(%s) error = %v, want ErrTagListInvalid", body, err)
+		}
+		server.Close()
+	}
+}
+
 // TestInspectCnab_TagNotFound проверяет обработку ошибки при получении манифеста тега
 func TestInspectCnab_TagNotFound(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
-
 	tagsList := `{
 		"name": "repo/cnab",
 		"tags": ["v1.0", "v2.0"]
@@ -759,6 +691,7 @@ func TestInspectCnab_TagNotFound(t *testing.T) {
 	defer server.Close()
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogDebugLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -773,20 +706,86 @@ func TestInspectCnab_TagNotFound(t *testing.T) {
 	cl.Repository = "repo/cnab"
 	cl.Tag = "v1.0"
 
-	cnf := (*Config)(cfg)
-	cnf.InspectCnab(cl)
+	s := NewSession((*Config)(cfg), cl)
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
+
+	if len(s.Project.ItemByTag) < 1 {
+		t.Errorf("ItemByTag length = %d, want at least 1", len(s.Project.ItemByTag))
+	}
+}
+
+// TestInspectCnab_PartialFetch проверяет, что недоступный тег даёт ErrPartial, а остальной граф строится
+func TestInspectCnab_PartialFetch(t *testing.T) {
+
+	index := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/tags/list/"):
+			w.Write([]byte(`{"name":"repo/cnab","tags":["v1","v2"]}`))
+		case strings.HasSuffix(r.URL.Path, "/manifests/v1"):
+			w.Header().Set("Content-Type", client.MediaTypeOciIndex)
+			w.Write([]byte(index))
+		default:
+			w.WriteHeader(404)
+			w.Write([]byte(`{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.Config{Scheme: "http", Timeout: 10000}
+	cl := client.NewRegClient((*client.Config)(cfg), "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	s := NewSession((*Config)(cfg), cl)
+	if err := s.InspectCnab(); !errors.Is(err, ErrPartial) {
+		t.Errorf("InspectCnab error = %v, want ErrPartial", err)
+	}
+	if s.Project.ItemByTag["v1"] == nil || s.Project.ItemByTag["v2"] != nil {
+		t.Errorf("ItemByTag = %+v, want only v1", s.Project.ItemByTag)
+	}
+}
+
+// TestInspectCnab_InvalidIndex проверяет, что тег с невалидным cnab индексом делает граф неполным
+func TestInspectCnab_InvalidIndex(t *testing.T) {
+	manifests := map[string]string{
+		"v1": `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`,
+		"v2": `{"schemaVersion":2,"manifests":[`,
+		"v3": `{"schemaVersion":2}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list/") {
+			w.Write([]byte(`{"name":"repo/cnab","tags":["v1","v2","v3"]}`))
+			return
+		}
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		w.Header().Set("Content-Type", client.MediaTypeOciIndex)
+		w.Write([]byte(manifests[reference]))
+	}))
+	defer server.Close()
+
+	cfg := &data.Config{Scheme: "http", Timeout: 10000}
+	cl := client.NewRegClient((*client.Config)(cfg), "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
 
-	if len(data.ItemByTag) < 1 {
-		t.Errorf("ItemByTag length = %d, want at least 1", len(data.ItemByTag))
+	s := NewSession((*Config)(cfg), cl)
+	err := s.InspectCnab()
+	if !errors.Is(err, ErrPartial) || !errors.Is(err, ErrNotCnabIndex) {
+		t.Errorf("InspectCnab error = %v, want ErrPartial and ErrNotCnabIndex", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "2 of 3 tags were not fetched") {
+		t.Errorf("InspectCnab error = %v, want 2 failed tags", err)
+	}
+	if s.Project.ItemByTag["v1"] == nil || s.Project.ItemByTag["v2"] != nil {
+		t.Errorf("ItemByTag = %+v, want v1 and no invalid v2", s.Project.ItemByTag)
 	}
 }
 
 // TestInspectCnab_MultipleCNABIndexes проверяет обработку нескольких CNAB индексов
 func TestInspectCnab_MultipleCNABIndexes(t *testing.T) {
-	snap := saveGlobalState()
-	defer restoreGlobalState(snap)
-	resetGlobalState(t)
-
 	tagsList := `{
 		"name": "repo/cnab",
 		"tags": ["v1.0", "v2.0", "v3.0"]
@@ -797,7 +796,7 @@ func TestInspectCnab_MultipleCNABIndexes(t *testing.T) {
 		"mediaType": "application/vnd.oci.image.index.v1+json",
 		"manifests": [
 			{
-				"digest": "sha256:config_%s",
+				"digest": "sha256:%064d",
 				"mediaType": "application/vnd.oci.image.manifest.v1+json",
 				"annotations": {
 					"io.cnab.manifest.type": "config"
@@ -818,17 +817,20 @@ func TestInspectCnab_MultipleCNABIndexes(t *testing.T) {
 			w.WriteHeader(200)
 			w.Write([]byte(tagsList))
 		case strings.HasSuffix(path, "/manifests/v1.0"):
-			w.Header().Set("Docker-Content-Digest", "sha256:oci_v1")
+			index := fmt.Sprintf(ociIndex, 1)
+			w.Header().Set("Docker-Content-Digest", digestOf(index))
 			w.WriteHeader(200)
-			w.Write([]byte(strings.Replace(ociIndex, "%s", "v1", 1)))
+			w.Write([]byte(index))
 		case strings.HasSuffix(path, "/manifests/v2.0"):
-			w.Header().Set("Docker-Content-Digest", "sha256:oci_v2")
+			index := fmt.Sprintf(ociIndex, 2)
+			w.Header().Set("Docker-Content-Digest", digestOf(index))
 			w.WriteHeader(200)
-			w.Write([]byte(strings.Replace(ociIndex, "%s", "v2", 1)))
+			w.Write([]byte(index))
 		case strings.HasSuffix(path, "/manifests/v3.0"):
-			w.Header().Set("Docker-Content-Digest", "sha256:oci_v3")
+			index := fmt.Sprintf(ociIndex, 3)
+			w.Header().Set("Docker-Content-Digest", digestOf(index))
 			w.WriteHeader(200)
-			w.Write([]byte(strings.Replace(ociIndex, "%s", "v3", 1)))
+			w.Write([]byte(index))
 		default:
 			w.WriteHeader(404)
 			w.Write([]byte(`{"error":"not found"}`))
@@ -837,6 +839,7 @@ func TestInspectCnab_MultipleCNABIndexes(t *testing.T) {
 	defer server.Close()
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogDebugLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -851,18 +854,315 @@ func TestInspectCnab_MultipleCNABIndexes(t *testing.T) {
 	cl.Repository = "repo/cnab"
 	cl.Tag = "v1.0"
 
-	cnf := (*Config)(cfg)
-	cnf.InspectCnab(cl)
+	s := NewSession((*Config)(cfg), cl)
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
 
-	if len(data.ItemByTag) < 3 {
-		t.Errorf("ItemByTag length = %d, want at least 3", len(data.ItemByTag))
+	if len(s.Project.ItemByTag) < 3 {
+		t.Errorf("ItemByTag length = %d, want at least 3", len(s.Project.ItemByTag))
 	}
 
-	if len(data.ProjectList) < 3 {
-		t.Errorf("ProjectList length = %d, want at least 3", len(data.ProjectList))
+	if len(s.Project.ProjectList) < 3 {
+		t.Errorf("ProjectList length = %d, want at least 3", len(s.Project.ProjectList))
 	}
 
 	if requestCount < 4 {
 		t.Errorf("Request count = %d, want at least 4", requestCount)
 	}
 }
+
+// TestInspectCnab_Concurrency проверяет параллельную загрузку и порядок регистрации по списку тегов
+func TestInspectCnab_Concurrency(t *testing.T) {
+
+	configManifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"sha256:config"}}`
+	configDigest := digestOf(configManifest)
+	var tags []string
+	manifests := map[string]string{configDigest: configManifest}
+	for i := 0; i < 40; i++ {
+		tag := fmt.Sprintf("v1.%02d", i)
+		tags = append(tags, tag)
+		manifests[tag] = fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"%s","mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"io.cnab.manifest.type":"config"}}],"annotations":{"tag":"%s"}}`, configDigest, tag)
+	}
+	tagsList, _ := json.Marshal(map[string]interface{}{"name": "repo/cnab", "tags": tags})
+
+	var mu sync.Mutex
+	inflight, maxInflight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list/") {
+			w.Write(tagsList)
+			return
+		}
+		mu.Lock()
+		inflight++
+		if inflight > maxInflight {
+			maxInflight = inflight
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inflight--
+		mu.Unlock()
+
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		content, ok := manifests[reference]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", client.MediaTypeOciIndex)
+		if reference == configDigest {
+			w.Header().Set("Content-Type", client.MediaTypeOciManifest)
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	cfg := &data.Config{Logger: logging.New(logging.LogErrorLevel), Scheme: "http", Client: "cnabtool/0.1.1", Timeout: 10000, Concurrency: 4}
+	cl := client.NewRegClient((*client.Config)(cfg), "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+
+	s := NewSession((*Config)(cfg), cl)
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
+
+	if maxInflight < 2 || maxInflight > 4 {
+		t.Errorf("max parallel requests = %d, want within [2, 4]", maxInflight)
+	}
+	if len(s.Project.ProjectList) != 41 {
+		t.Fatalf("ProjectList length = %d, want 41", len(s.Project.ProjectList))
+	}
+	// индексы регистрируются в порядке списка тегов
+	for i, tag := range tags {
+		if s.Project.ProjectList[i].Tag != tag {
+			t.Errorf("ProjectList[%d].Tag = %q, want %q", i, s.Project.ProjectList[i].Tag, tag)
+		}
+	}
+	config := s.Project.ItemByDigest[configDigest]
+	if config == nil || len(config.UpLinks) != 40 {
+		t.Fatalf("config item should have 40 uplinks, got %+v", config)
+	}
+	// uplinks добавляются в отсортированном порядке тегов
+	if config.UpLinks[0].Digest != s.Project.ItemByTag["v1.00"].Digest {
+		t.Errorf("first uplink = %q, want index of v1.00", config.UpLinks[0].Digest)
+	}
+	if cfg.Logger.Errors() != 0 {
+		t.Errorf("errors = %d, want 0", cfg.Logger.Errors())
+	}
+}
+
+// TestInspectCnab_HeadSkipsKnownManifests проверяет, что теги одного манифеста загружаются один раз
+func TestInspectCnab_HeadSkipsKnownManifests(t *testing.T) {
+
+	configManifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"sha256:config"}}`
+	index := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"%s","mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"io.cnab.manifest.type":"config"}}]}`, digestOf(configManifest))
+	other := strings.Replace(index, `"schemaVersion":2`, `"schemaVersion":2,"annotations":{"tag":"v2.0"}`, 1)
+	manifests := map[string]string{
+		"v1.0":                   index,
+		"latest":                 index,
+		"stable":                 index,
+		"v2.0":                   other,
+		"v2":                     other,
+		digestOf(configManifest): configManifest,
+	}
+	tagsList := `{"name":"repo/cnab","tags":["latest","stable","v1.0","v2","v2.0"]}`
+
+	var mu sync.Mutex
+	gets := map[string]int{}
+	heads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list/") {
+			w.Write([]byte(tagsList))
+			return
+		}
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		content, ok := manifests[reference]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		mu.Lock()
+		if r.Method == http.MethodHead {
+			heads++
+		} else {
+			gets[reference]++
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", client.MediaTypeOciIndex)
+		w.Header().Set("Docker-Content-Digest", digestOf(content))
+		if r.Method != http.MethodHead {
+			w.Write([]byte(content))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.Config{Scheme: "http", Client: "cnabtool/0.1.1", Timeout: 10000}
+	s, regres, err := (*Config)(cfg).OpenSession(strings.TrimPrefix(server.URL, "http://") + "/repo/cnab:v1.0")
+	if err != nil {
+		t.Fatalf("OpenSession should not return error, got: %v", err)
+	}
+	s.AddCnab(regres, "v1.0")
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
+
+	// v1.0 is reference, it is not requested again, other tags are checked by HEAD
+	if heads != 4 {
+		t.Errorf("HEAD requests = %d, want 4", heads)
+	}
+	// latest and stable are the same manifest as root, v2.0 is the same as v2
+	if gets["latest"]+gets["stable"]+gets["v1.0"] != 1 {
+		t.Errorf("GET requests for root manifest = %v, want 1", gets)
+	}
+	if gets["v2"]+gets["v2.0"] != 1 {
+		t.Errorf("GET requests for v2 manifest = %v, want 1", gets)
+	}
+	for _, tag := range []string{"latest", "stable", "v1.0"} {
+		if s.Project.ItemByTag[tag] != s.Project.ItemByDigest[digestOf(index)] {
+			t.Errorf("ItemByTag[%s] is not root index", tag)
+		}
+	}
+	if s.Project.ItemByTag["v2"] == nil || s.Project.ItemByTag["v2"] != s.Project.ItemByTag["v2.0"] {
+		t.Errorf("tags v2 and v2.0 must point to the same item")
+	}
+}
+
+// TestInspectCnab_DistinctTags проверяет, что каждый манифест разных тегов загружается один раз после HEAD
+func TestInspectCnab_DistinctTags(t *testing.T) {
+	manifests := map[string]string{}
+	var tags []string
+	for i := 0; i < 6; i++ {
+		tag := fmt.Sprintf("v%d", i)
+		tags = append(tags, tag)
+		manifests[tag] = fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","annotations":{"tag":"%s"},"manifests":[]}`, tag)
+	}
+	tagsList, _ := json.Marshal(map[string]interface{}{"name": "repo/cnab", "tags": tags})
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method]++
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/tags/list/") {
+			w.Write(tagsList)
+			return
+		}
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		content, ok := manifests[reference]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", client.MediaTypeOciIndex)
+		w.Header().Set("Docker-Content-Digest", digestOf(content))
+		if r.Method != http.MethodHead {
+			w.Write([]byte(content))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &data.Config{Scheme: "http", Client: "cnabtool/0.1.1", Timeout: 10000, Concurrency: 2}
+	cl := client.NewRegClient((*client.Config)(cfg), "test")
+	cl.Registry = strings.TrimPrefix(server.URL, "http://")
+	cl.Repository = "repo/cnab"
+	s := NewSession((*Config)(cfg), cl)
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
+
+	// tag list, HEAD and GET per tag, order of tags doesn't matter
+	if requests[http.MethodHead] != len(tags) || requests[http.MethodGet] != len(tags)+1 {
+		t.Errorf("requests = %v, want %d HEAD and %d GET", requests, len(tags), len(tags)+1)
+	}
+	for _, tag := range tags {
+		if s.Project.ItemByTag[tag] == nil {
+			t.Errorf("tag %s is not registered", tag)
+		}
+	}
+}
+
+// newNestedSession создаёт реестр с cnab, invocation-образ которого - manifest list с вложенным oci index
+func newNestedSession(t *testing.T) (*Session, map[string]string) {
+	t.Helper()
+
+	manifests := map[string]string{}
+	add := func(content string) string {
+		digest := digestOf(content)
+		manifests[digest] = content
+		return digest
+	}
+	config := add(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"digest":"sha256:config"}}`)
+	amd64 := add(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","layers":[{"digest":"sha256:amd64"}]}`)
+	armv8 := add(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[{"digest":"sha256:armv8"}]}`)
+	arm := add(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"%s","mediaType":"application/vnd.oci.image.manifest.v1+json","platform":{"os":"linux","architecture":"arm64","variant":"v8"}}]}`, armv8))
+	list := add(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","manifests":[{"digest":"%s","mediaType":"application/vnd.docker.distribution.manifest.v2+json","platform":{"os":"linux","architecture":"amd64"}},{"digest":"%s","mediaType":"application/vnd.oci.image.index.v1+json","platform":{"os":"linux","architecture":"arm64"}}]}`, amd64, arm))
+	cnab := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"digest":"%s","mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"io.cnab.manifest.type":"config"}},{"digest":"%s","mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","annotations":{"io.cnab.manifest.type":"invocation"}}]}`, config, list)
+	manifests["v1"] = cnab
+	names := map[string]string{"config": config, "amd64": amd64, "armv8": armv8, "arm": arm, "list": list, "cnab": digestOf(cnab)}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list/") {
+			w.Write([]byte(`{"name":"repo/cnab","tags":["v1"]}`))
+			return
+		}
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		content, ok := manifests[reference]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		media, _ := jsonparser.GetString([]byte(content), "mediaType")
+		w.Header().Set("Content-Type", media)
+		w.Header().Set("Docker-Content-Digest", digestOf(content))
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &data.Config{Scheme: "http", Client: "cnabtool/0.1.1", Timeout: 10000}
+	s, regres, err := (*Config)(cfg).OpenSession(strings.TrimPrefix(server.URL, "http://") + "/repo/cnab:v1")
+	if err != nil {
+		t.Fatalf("OpenSession should not return error, got: %v", err)
+	}
+	s.AddCnab(regres, "v1")
+	if err := s.InspectCnab(); err != nil {
+		t.Fatalf("InspectCnab should not return error, got: %v", err)
+	}
+	return s, names
+}
+
+// TestInspectCnab_NestedIndexes проверяет обход вложенных manifest list и oci index с платформами
+func TestInspectCnab_NestedIndexes(t *testing.T) {
+	s, names := newNestedSession(t)
+
+	for _, name := range []string{"config", "amd64", "armv8", "arm", "list", "cnab"} {
+		if _, ok := s.Project.ItemByDigest[names[name]]; !ok {
+			t.Errorf("item %s was not registered", name)
+		}
+	}
+	list := s.Project.ItemByDigest[names["list"]]
+	if len(list.DownLinks) != 2 || list.Lost != 0 {
+		t.Errorf("manifest list links = %+v, lost %d, want 2 links", list.DownLinks, list.Lost)
+	}
+	if list.Annotation != "invocation" {
+		t.Errorf("manifest list annotation = %q, want invocation", list.Annotation)
+	}
+	platforms := map[string]string{"amd64": "linux/amd64", "arm": "linux/arm64", "armv8": "linux/arm64/v8"}
+	for name, want := range platforms {
+		ri := s.Project.ItemByDigest[names[name]]
+		if ri.Platform != want {
+			t.Errorf("%s platform = %q, want %q", name, ri.Platform, want)
+		}
+		if len(ri.UpLinks) != 1 {
+			t.Errorf("%s uplinks = %+v, want 1", name, ri.UpLinks)
+		}
+	}
+	if annotation := s.Project.ItemByDigest[names["arm"]].Annotation; annotation != data.ItemTypeIndex {
+		t.Errorf("nested index annotation = %q, want %q", annotation, data.ItemTypeIndex)
+	}
+	if annotation := s.Project.ItemByDigest[names["armv8"]].Annotation; annotation != data.ItemTypeImage {
+		t.Errorf("platform image annotation = %q, want %q", annotation, data.ItemTypeImage)
+	}
+}
@@ -19,30 +19,49 @@ type Config data.Config
 
 func (cc *Config) GetManifest(reference string) (*client.RegResponse, *client.RegClient, error) {
 
-	cl := client.NewRegClient((*client.Config)(cc), reference)
-
-	// parse argument
-	err := cl.ParseReference()
+	cl, err := cc.referenceClient(reference)
 	if err != nil {
-		err_line := fmt.Sprintf("invalid reference %+v", err)
-		logging.Error(err_line)
-		return nil, nil, errors.New(err_line)
+		return nil, nil, err
 	}
-	logging.Debug(fmt.Sprintf("Client %+v", cl))
-
-	// save current project root
-	data.Repository = cl.Repository
-	data.Registry = cl.Registry
-	data.Scheme = cl.Scheme
 
 	// do request
 	regres, err := cl.GetRegIndex()
 	//if regres != nil {
-	//	logging.Debug(fmt.Sprintf("response content %+v", regres))
+	//	cc.Logger.Debug(fmt.Sprintf("response content %+v", regres))
 	//}
 	return regres, cl, err
 }
 
+// HeadManifest check manifest by reference without downloading it
+
+func (cc *Config) HeadManifest(reference string) (*client.RegResponse, error) {
+
+	cl, err := cc.referenceClient(reference)
+	if err != nil {
+		return nil, err
+	}
+	return cl.HeadManifest()
+}
+
+// referenceClient make client for reference with resolved credentials
+
+func (cc *Config) referenceClient(reference string) (*client.RegClient, error) {
+
+	cl := client.NewRegClient((*client.Config)(cc), reference)
+
+	// parse argument
+	err := cl.ParseReference()
+	if err != nil {
+		err_line := fmt.Sprintf("invalid reference %+v", err)
+		cc.Logger.Error(err_line)
+		return nil, errors.New(err_line)
+	}
+	// credentials for the registry from docker config, if not given explicitly
+	cl.ResolveCredentials()
+	cc.Logger.Debug(fmt.Sprintf("Client %+v", cl))
+	return cl, nil
+}
+
 // pretty print RegResponse
 
 func ResponsePrettyPrint(regres *client.RegResponse) {
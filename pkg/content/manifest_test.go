@@ -3,6 +3,7 @@ package content
 import (
 	"cnabtool/pkg/client"
 	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -12,31 +13,6 @@ import (
 
 // TestGetManifest_ValidReference проверяет успешное получение манифеста через httptest-сервер
 func TestGetManifest_ValidReference(t *testing.T) {
-	// Сохраняем состояние для восстановления
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	origScheme := data.Scheme
-	origRegistry := data.Registry
-	origRepository := data.Repository
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-		data.Scheme = origScheme
-		data.Registry = origRegistry
-		data.Repository = origRepository
-	}()
-
-	// Инициализируем Gc
-	data.Gc = &data.Config{
-		Verbosity: 4,
-		Timeout:   10000,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
-	}
-	defer func() { data.Gc = nil }()
-
 	// Создаём тестовый сервер
 	manifestJSON := `{
 		"schemaVersion": 2,
@@ -49,10 +25,11 @@ func TestGetManifest_ValidReference(t *testing.T) {
 			}
 		]
 	}`
+	digest := digestOf(manifestJSON)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
-		w.Header().Set("Docker-Content-Digest", "sha256:abc123")
+		w.Header().Set("Docker-Content-Digest", digest)
 		w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
 		w.WriteHeader(200)
 		w.Write([]byte(manifestJSON))
@@ -64,6 +41,7 @@ func TestGetManifest_ValidReference(t *testing.T) {
 
 	// Создаём Config
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogDebugLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -75,7 +53,7 @@ func TestGetManifest_ValidReference(t *testing.T) {
 
 	// Вызываем GetManifest
 	cnf := (*Config)(cfg)
-	regres, cl, err := cnf.GetManifest(serverHost + "/repo/image:v1@sha256:abc123")
+	regres, cl, err := cnf.GetManifest(serverHost + "/repo/image:v1@" + digest)
 
 	if err != nil {
 		t.Fatalf("GetManifest should not return error, got: %v", err)
@@ -97,30 +75,31 @@ func TestGetManifest_ValidReference(t *testing.T) {
 	if cl.Tag != "v1" {
 		t.Errorf("cl.Tag = %q, want %q", cl.Tag, "v1")
 	}
-	if cl.Digest != "sha256:abc123" {
-		t.Errorf("cl.Digest = %q, want %q", cl.Digest, "sha256:abc123")
+	if cl.Digest != digest {
+		t.Errorf("cl.Digest = %q, want %q", cl.Digest, digest)
 	}
 	if cl.Scheme != "http" {
 		t.Errorf("cl.Scheme = %q, want %q", cl.Scheme, "http")
 	}
 
-	// Проверяем сохранённое глобальное состояние
-	if data.Registry != serverHost {
-		t.Errorf("data.Registry = %q, want %q", data.Registry, serverHost)
+	// Проверяем корень проекта в сессии
+	session := NewSession(cnf, cl)
+	if session.Project.Registry != serverHost {
+		t.Errorf("Project.Registry = %q, want %q", session.Project.Registry, serverHost)
 	}
-	if data.Repository != "repo/image" {
-		t.Errorf("data.Repository = %q, want %q", data.Repository, "repo/image")
+	if session.Project.Repository != "repo/image" {
+		t.Errorf("Project.Repository = %q, want %q", session.Project.Repository, "repo/image")
 	}
-	if data.Scheme != "http" {
-		t.Errorf("data.Scheme = %q, want %q", data.Scheme, "http")
+	if session.Project.Scheme != "http" {
+		t.Errorf("Project.Scheme = %q, want %q", session.Project.Scheme, "http")
 	}
 
 	// Проверяем ответ
 	if regres.Media != "application/vnd.oci.image.index.v1+json" {
 		t.Errorf("regres.Media = %q, want %q", regres.Media, "application/vnd.oci.image.index.v1+json")
 	}
-	if regres.Digest != "sha256:abc123" {
-		t.Errorf("regres.Digest = %q, want %q", regres.Digest, "sha256:abc123")
+	if regres.Digest != digest {
+		t.Errorf("regres.Digest = %q, want %q", regres.Digest, digest)
 	}
 	if regres.Status != 200 {
 		t.Errorf("regres.Status = %d, want 200", regres.Status)
@@ -129,17 +108,6 @@ func TestGetManifest_ValidReference(t *testing.T) {
 
 // TestGetManifest_InvalidReference проверяет обработку невалидной ссылки
 func TestGetManifest_InvalidReference(t *testing.T) {
-	origGc := data.Gc
-	defer func() { data.Gc = origGc }()
-
-	data.Gc = &data.Config{
-		Verbosity: 1,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
-	}
-
 	testcases := []struct {
 		name string
 		ref  string
@@ -161,6 +129,7 @@ func TestGetManifest_InvalidReference(t *testing.T) {
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
 			cfg := &data.Config{
+				Logger: logging.New(logging.LogErrorLevel),
 				Scheme: "https",
 				Credentials: data.Credentials{
 					Username: "testuser",
@@ -180,29 +149,6 @@ func TestGetManifest_InvalidReference(t *testing.T) {
 
 // TestGetManifest_TagOnly проверяет ссылку только с тегом (без digest)
 func TestGetManifest_TagOnly(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	origScheme := data.Scheme
-	origRegistry := data.Registry
-	origRepository := data.Repository
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-		data.Scheme = origScheme
-		data.Registry = origRegistry
-		data.Repository = origRepository
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: 4,
-		Timeout:   10000,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
-	}
-	defer func() { data.Gc = nil }()
-
 	manifestJSON := `{
 		"schemaVersion": 2,
 		"mediaType": "application/vnd.oci.image.index.v1+json",
@@ -211,7 +157,7 @@ func TestGetManifest_TagOnly(t *testing.T) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
-		w.Header().Set("Docker-Content-Digest", "sha256:tagonly123")
+		w.Header().Set("Docker-Content-Digest", digestOf(manifestJSON))
 		w.WriteHeader(200)
 		w.Write([]byte(manifestJSON))
 	}))
@@ -220,6 +166,7 @@ func TestGetManifest_TagOnly(t *testing.T) {
 	serverHost := strings.TrimPrefix(server.URL, "http://")
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogDebugLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -356,19 +303,8 @@ func TestResponsePrettyPrint_LargeContent(t *testing.T) {
 
 // TestConfig_TypeAlias проверяет, что Config является тип-алиасом data.Config
 func TestConfig_TypeAlias(t *testing.T) {
-	origGc := data.Gc
-	defer func() { data.Gc = origGc }()
-
-	data.Gc = &data.Config{
-		Verbosity: 2,
-		Timeout:   10000,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
-	}
-
 	cfg := &data.Config{
+		Logger:  logging.New(logging.LogNormalLevel),
 		Scheme:  "https",
 		Raw:     true,
 		DryRun:  true,
@@ -404,29 +340,6 @@ func TestConfig_TypeAlias(t *testing.T) {
 
 // TestGetManifest_DockerManifest проверяет получение docker manifest v2
 func TestGetManifest_DockerManifest(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	origScheme := data.Scheme
-	origRegistry := data.Registry
-	origRepository := data.Repository
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-		data.Scheme = origScheme
-		data.Registry = origRegistry
-		data.Repository = origRepository
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: 4,
-		Timeout:   10000,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
-	}
-	defer func() { data.Gc = nil }()
-
 	dockerManifest := `{
 		"schemaVersion": 2,
 		"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
@@ -441,10 +354,11 @@ func TestGetManifest_DockerManifest(t *testing.T) {
 			}
 		]
 	}`
+	digest := digestOf(dockerManifest)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
-		w.Header().Set("Docker-Content-Digest", "sha256:docker123")
+		w.Header().Set("Docker-Content-Digest", digest)
 		w.WriteHeader(200)
 		w.Write([]byte(dockerManifest))
 	}))
@@ -453,6 +367,7 @@ func TestGetManifest_DockerManifest(t *testing.T) {
 	serverHost := strings.TrimPrefix(server.URL, "http://")
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogDebugLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -463,7 +378,7 @@ func TestGetManifest_DockerManifest(t *testing.T) {
 	}
 
 	cnf := (*Config)(cfg)
-	regres, _, err := cnf.GetManifest(serverHost + "/repo/image:v1@sha256:docker123")
+	regres, _, err := cnf.GetManifest(serverHost + "/repo/image:v1@" + digest)
 
 	if err != nil {
 		t.Fatalf("GetManifest should not return error, got: %v", err)
@@ -472,24 +387,13 @@ func TestGetManifest_DockerManifest(t *testing.T) {
 	if regres.Media != "application/vnd.docker.distribution.manifest.v2+json" {
 		t.Errorf("regres.Media = %q, want %q", regres.Media, "application/vnd.docker.distribution.manifest.v2+json")
 	}
-	if regres.Digest != "sha256:docker123" {
-		t.Errorf("regres.Digest = %q, want %q", regres.Digest, "sha256:docker123")
+	if regres.Digest != digest {
+		t.Errorf("regres.Digest = %q, want %q", regres.Digest, digest)
 	}
 }
 
 // TestGetManifest_ErrorResponse проверяет обработку HTTP-ошибки от сервера
 func TestGetManifest_ErrorResponse(t *testing.T) {
-	origGc := data.Gc
-	defer func() { data.Gc = origGc }()
-
-	data.Gc = &data.Config{
-		Verbosity: 1,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
-	}
-
 	// Сервер возвращает 500
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
@@ -500,6 +404,7 @@ func TestGetManifest_ErrorResponse(t *testing.T) {
 	serverHost := strings.TrimPrefix(server.URL, "http://")
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogErrorLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -519,17 +424,6 @@ func TestGetManifest_ErrorResponse(t *testing.T) {
 
 // TestGetManifest_Unauthorized проверяет обработку 401
 func TestGetManifest_Unauthorized(t *testing.T) {
-	origGc := data.Gc
-	defer func() { data.Gc = origGc }()
-
-	data.Gc = &data.Config{
-		Verbosity: 1,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
-	}
-
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(401)
 		w.Write([]byte("Unauthorized"))
@@ -539,6 +433,7 @@ func TestGetManifest_Unauthorized(t *testing.T) {
 	serverHost := strings.TrimPrefix(server.URL, "http://")
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogErrorLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -558,38 +453,16 @@ func TestGetManifest_Unauthorized(t *testing.T) {
 
 // TestGetManifest_DigestOnlyReference проверяет ссылку только с digest (без тега)
 func TestGetManifest_DigestOnlyReference(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	origScheme := data.Scheme
-	origRegistry := data.Registry
-	origRepository := data.Repository
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-		data.Scheme = origScheme
-		data.Registry = origRegistry
-		data.Repository = origRepository
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: 4,
-		Timeout:   10000,
-		Credentials: data.Credentials{
-			Username: "testuser",
-			Password: "testpass",
-		},
-	}
-	defer func() { data.Gc = nil }()
-
 	manifestJSON := `{
 		"schemaVersion": 2,
 		"mediaType": "application/vnd.oci.image.index.v1+json",
 		"manifests": []
 	}`
+	digest := digestOf(manifestJSON)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
-		w.Header().Set("Docker-Content-Digest", "sha256:digestonly")
+		w.Header().Set("Docker-Content-Digest", digest)
 		w.WriteHeader(200)
 		w.Write([]byte(manifestJSON))
 	}))
@@ -598,6 +471,7 @@ func TestGetManifest_DigestOnlyReference(t *testing.T) {
 	serverHost := strings.TrimPrefix(server.URL, "http://")
 
 	cfg := &data.Config{
+		Logger: logging.New(logging.LogDebugLevel),
 		Scheme: "http",
 		Credentials: data.Credentials{
 			Username: "testuser",
@@ -608,7 +482,7 @@ func TestGetManifest_DigestOnlyReference(t *testing.T) {
 	}
 
 	cnf := (*Config)(cfg)
-	_, cl, err := cnf.GetManifest(serverHost + "/repo/image@sha256:digestonly")
+	_, cl, err := cnf.GetManifest(serverHost + "/repo/image@" + digest)
 
 	if err != nil {
 		t.Fatalf("GetManifest should not return error, got: %v", err)
@@ -617,7 +491,7 @@ func TestGetManifest_DigestOnlyReference(t *testing.T) {
 	if cl.Tag != "" {
 		t.Errorf("cl.Tag should be empty for digest-only reference, got %q", cl.Tag)
 	}
-	if cl.Digest != "sha256:digestonly" {
-		t.Errorf("cl.Digest = %q, want %q", cl.Digest, "sha256:digestonly")
+	if cl.Digest != digest {
+		t.Errorf("cl.Digest = %q, want %q", cl.Digest, digest)
 	}
 }
@@ -0,0 +1,213 @@
+package content
+
+import (
+	"cnabtool/pkg/data"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+// ErrPolicyInvalid is empty or broken retention policy
+
+var ErrPolicyInvalid = errors.New("invalid prune policy")
+
+// AnnotationCreated is creation time of index, RFC 3339
+
+const AnnotationCreated = "org.opencontainers.image.created"
+
+// reasons of prune decision
+
+const (
+	ReasonAllowlist   = "allowlist"
+	ReasonUnknownDate = "unknown date" // date rule can't be checked, bundle is kept
+)
+
+// timeNow is replaced in tests, it is also start time of delete requests
+
+var timeNow = time.Now
+
+// decision of retention policy for one cnab
+
+type PruneDecision struct {
+	Digest  string   `json:"digest" yaml:"digest"`
+	Tags    []string `json:"tags" yaml:"tags"`
+	Date    string   `json:"date,omitempty" yaml:"date,omitempty"` // RFC 3339, empty if unknown
+	Keep    bool     `json:"keep" yaml:"keep"`
+	Reasons []string `json:"reasons,omitempty" yaml:"reasons,omitempty"` // rules, which keep cnab
+}
+
+// decisions and delete plan of cnab, which are not kept
+
+type PrunePlan struct {
+	Decisions []PruneDecision `json:"decisions" yaml:"decisions"`
+	Delete    *DeletePlan     `json:"delete" yaml:"delete"`
+}
+
+// compiled retention policy
+
+type pruneRules struct {
+	policy    data.PrunePolicy
+	allowlist map[string]bool
+	tags      []*regexp.Regexp
+	semver    []semverConstraint
+}
+
+// compilePolicy check policy, empty policy would delete everything, so it is an error
+
+func compilePolicy(policy data.PrunePolicy) (*pruneRules, error) {
+	if policy.KeepLast < 0 || policy.KeepDays < 0 {
+		return nil, fmt.Errorf("%w: keeplast and keepdays must not be negative", ErrPolicyInvalid)
+	}
+	if policy.KeepLast == 0 && policy.KeepDays == 0 && len(policy.KeepTags) == 0 &&
+		len(policy.KeepSemver) == 0 && len(policy.Allowlist) == 0 {
+		return nil, fmt.Errorf("%w: no rules, every cnab of repository would be deleted", ErrPolicyInvalid)
+	}
+	rules := &pruneRules{policy: policy, allowlist: make(map[string]bool)}
+	for _, tag := range policy.Allowlist {
+		rules.allowlist[tag] = true
+	}
+	for _, expr := range policy.KeepTags {
+		// tag must match as a whole
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: keeptags %q, %w", ErrPolicyInvalid, expr, err)
+		}
+		rules.tags = append(rules.tags, re)
+	}
+	for _, constraint := range policy.KeepSemver {
+		sc, err := parseConstraint(constraint)
+		if err != nil {
+			return nil, fmt.Errorf("%w: keepsemver, %w", ErrPolicyInvalid, err)
+		}
+		rules.semver = append(rules.semver, sc)
+	}
+	return rules, nil
+}
+
+// byTag return reasons to keep cnab by its tags
+
+func (r *pruneRules) byTag(tags []string) []string {
+	var reasons []string
+	for _, tag := range tags {
+		if r.allowlist[tag] {
+			reasons = append(reasons, fmt.Sprintf("%s %s", ReasonAllowlist, tag))
+		}
+		for i, re := range r.tags {
+			if re.MatchString(tag) {
+				reasons = append(reasons, fmt.Sprintf("tag %s matches %s", tag, r.policy.KeepTags[i]))
+			}
+		}
+		for i, sc := range r.semver {
+			if sc.match(tag) {
+				reasons = append(reasons, fmt.Sprintf("tag %s satisfies %s", tag, r.policy.KeepSemver[i]))
+			}
+		}
+	}
+	return reasons
+}
+
+// itemDate return creation annotation of index, or Last-Modified of manifest
+
+func itemDate(item *data.RegIndex) (time.Time, bool) {
+	if created, err := jsonparser.GetString([]byte(item.Content), "annotations", AnnotationCreated); err == nil {
+		if date, err := time.Parse(time.RFC3339, created); err == nil {
+			return date, true
+		}
+	}
+	if len(item.Date) != 0 {
+		if date, err := http.ParseTime(item.Date); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// PlanPrune apply retention policy of config to cnab indexes of inspected repository
+// and make delete plan of cnab, which are not kept. Cnab is kept if any rule keeps
+// it: allowlist, tag regexes, semver constraints, age in days or the newest N.
+// Graph of partial inspect is not planned, see checkComplete
+
+func (s *Session) PlanPrune() (*PrunePlan, error) {
+	if err := s.checkComplete(); err != nil {
+		return nil, err
+	}
+	rules, err := compilePolicy(s.Config.Prune)
+	if err != nil {
+		s.Config.Logger.Error(err.Error())
+		return nil, err
+	}
+
+	// cnab with all its tags
+	var items []*data.RegIndex
+	tagsOf := make(map[*data.RegIndex][]string)
+	for _, tag := range s.SortedTags() {
+		item := s.Project.ItemByTag[tag]
+		if item.Annotation != data.ItemTypeCnab {
+			continue
+		}
+		if _, ok := tagsOf[item]; !ok {
+			items = append(items, item)
+		}
+		tagsOf[item] = append(tagsOf[item], tag)
+	}
+
+	dates := make(map[*data.RegIndex]time.Time)
+	var dated []*data.RegIndex
+	for _, item := range items {
+		if date, ok := itemDate(item); ok {
+			dates[item] = date
+			dated = append(dated, item)
+		}
+	}
+	// newest first, digest makes order stable
+	sort.SliceStable(dated, func(i, j int) bool {
+		if !dates[dated[i]].Equal(dates[dated[j]]) {
+			return dates[dated[i]].After(dates[dated[j]])
+		}
+		return dated[i].Digest < dated[j].Digest
+	})
+	newest := make(map[*data.RegIndex]int)
+	for i, item := range dated {
+		newest[item] = i + 1
+	}
+	dateRule := rules.policy.KeepLast != 0 || rules.policy.KeepDays != 0
+	since := timeNow().AddDate(0, 0, -rules.policy.KeepDays)
+
+	plan := &PrunePlan{}
+	var targets []*data.RegIndex
+	for _, item := range items {
+		decision := PruneDecision{Digest: item.Digest, Tags: tagsOf[item]}
+		decision.Reasons = rules.byTag(decision.Tags)
+		date, known := dates[item]
+		switch {
+		case known:
+			decision.Date = date.UTC().Format(time.RFC3339)
+			if rules.policy.KeepDays != 0 && date.After(since) {
+				decision.Reasons = append(decision.Reasons, fmt.Sprintf("younger than %d days", rules.policy.KeepDays))
+			}
+			if rank := newest[item]; rank <= rules.policy.KeepLast {
+				decision.Reasons = append(decision.Reasons, fmt.Sprintf("newest %d of %d", rank, rules.policy.KeepLast))
+			}
+		case dateRule:
+			decision.Reasons = append(decision.Reasons, ReasonUnknownDate)
+		}
+		decision.Keep = len(decision.Reasons) != 0
+		if decision.Keep {
+			s.Config.Logger.Info(fmt.Sprintf("Keep cnab %s %s, %s", strings.Join(decision.Tags, ","), item.Digest, strings.Join(decision.Reasons, "; ")))
+		} else {
+			s.Config.Logger.Message(fmt.Sprintf("Prune cnab %s %s", strings.Join(decision.Tags, ","), item.Digest))
+			targets = append(targets, item)
+		}
+		plan.Decisions = append(plan.Decisions, decision)
+	}
+
+	// the same graph-aware plan as delete, kept cnab keep their components
+	plan.Delete = s.planTargets(targets)
+	return plan, nil
+}
@@ -0,0 +1,129 @@
+package content
+
+import (
+	"cnabtool/pkg/data"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPruneSession создаёт репозиторий с cnab разного возраста, v1.0.0 и v1.1.0 разделяют компонент
+func newPruneSession(t *testing.T, policy data.PrunePolicy) *Session {
+	t.Helper()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	s := newTestSession(t)
+	s.Config.Prune = policy
+	s.Project.Scheme = "http"
+	s.Project.Registry = "registry.example.com"
+	s.Project.Repository = "repo/cnab"
+
+	add := func(ri *data.RegIndex, tags ...string) {
+		if len(tags) != 0 {
+			ri.Annotation = data.ItemTypeCnab
+		}
+		s.Project.ItemByDigest[ri.Digest] = ri
+		s.Project.ProjectList = append(s.Project.ProjectList, ri)
+		for _, tag := range tags {
+			s.Project.ItemByTag[tag] = ri
+		}
+	}
+	add(&data.RegIndex{Digest: "sha256:cnab1", Date: "Thu, 01 Jan 2026 10:00:00 GMT", DownLinks: []data.CnabItem{
+		{Digest: "sha256:own1", Annotation: "config"}, {Digest: "sha256:shared", Annotation: "invocation"}}}, "v1.0.0")
+	add(&data.RegIndex{Digest: "sha256:cnab2", Date: "Tue, 01 Sep 2026 10:00:00 GMT", DownLinks: []data.CnabItem{
+		{Digest: "sha256:shared", Annotation: "invocation"}}}, "v1.1.0")
+	add(&data.RegIndex{Digest: "sha256:cnab3", Date: "Sat, 10 Oct 2026 10:00:00 GMT"}, "v2.0.0", "latest")
+	// дата создания из аннотации важнее Last-Modified
+	add(&data.RegIndex{Digest: "sha256:cnab4", Date: "Thu, 01 Jan 2026 10:00:00 GMT",
+		Content: `{"annotations":{"org.opencontainers.image.created":"2026-10-16T08:00:00Z"}}`}, "dev-x")
+	add(&data.RegIndex{Digest: "sha256:cnab5"}, "nodate")
+	add(&data.RegIndex{Digest: "sha256:cnab6", Date: "Wed, 01 Jan 2025 10:00:00 GMT"}, "stable")
+	add(&data.RegIndex{Digest: "sha256:cnab7", Date: "Wed, 01 Jan 2025 10:00:00 GMT"}, "old")
+	add(&data.RegIndex{Digest: "sha256:own1", UpLinks: []data.CnabItem{{Digest: "sha256:cnab1"}}})
+	add(&data.RegIndex{Digest: "sha256:shared", UpLinks: []data.CnabItem{{Digest: "sha256:cnab1"}, {Digest: "sha256:cnab2"}}})
+	return s
+}
+
+// TestPlanPrune_Policy проверяет решения каждого правила и план удаления непокрытых cnab
+func TestPlanPrune_Policy(t *testing.T) {
+	s := newPruneSession(t, data.PrunePolicy{
+		KeepLast:   1,
+		KeepDays:   7,
+		KeepTags:   []string{"lat.*"},
+		KeepSemver: []string{"^1.1"},
+		Allowlist:  []string{"stable"},
+	})
+
+	plan, err := s.PlanPrune()
+	if err != nil {
+		t.Fatalf("PlanPrune should not return error, got: %v", err)
+	}
+	decisions := make(map[string]PruneDecision)
+	for _, decision := range plan.Decisions {
+		decisions[decision.Digest] = decision
+	}
+	if len(decisions) != 7 {
+		t.Fatalf("decisions = %+v, want 7 cnab", plan.Decisions)
+	}
+	want := map[string]string{
+		"sha256:cnab1": "",
+		"sha256:cnab2": "tag v1.1.0 satisfies ^1.1",
+		"sha256:cnab3": "tag latest matches lat.*",
+		"sha256:cnab4": "younger than 7 days; newest 1 of 1",
+		"sha256:cnab5": ReasonUnknownDate,
+		"sha256:cnab6": "allowlist stable",
+		"sha256:cnab7": "",
+	}
+	for digest, reasons := range want {
+		decision := decisions[digest]
+		if got := strings.Join(decision.Reasons, "; "); got != reasons || decision.Keep != (len(reasons) != 0) {
+			t.Errorf("decision of %s = %+v, want reasons %q", digest, decision, reasons)
+		}
+	}
+	if decisions["sha256:cnab3"].Date != "2026-10-10T10:00:00Z" || strings.Join(decisions["sha256:cnab3"].Tags, ",") != "latest,v2.0.0" {
+		t.Errorf("decision of cnab3 = %+v", decisions["sha256:cnab3"])
+	}
+	if decisions["sha256:cnab4"].Date != "2026-10-16T08:00:00Z" {
+		t.Errorf("date of cnab4 = %s, want created annotation", decisions["sha256:cnab4"].Date)
+	}
+
+	if got := strings.Join(planDigests(plan.Delete), ","); got != "sha256:cnab7,sha256:own1,sha256:cnab1" {
+		t.Errorf("delete plan = %s, want old and v1.0.0 with own component", got)
+	}
+	retained := plan.Delete.Retained
+	if len(retained) != 1 || retained[0].Digest != "sha256:shared" || strings.Join(retained[0].Tags, ",") != "v1.1.0" {
+		t.Errorf("retained = %+v, want shared component kept by v1.1.0", retained)
+	}
+}
+
+// TestPlanPrune_TagRulesOnly проверяет, что без правил по дате cnab без даты удаляется
+func TestPlanPrune_TagRulesOnly(t *testing.T) {
+	s := newPruneSession(t, data.PrunePolicy{Allowlist: []string{"stable", "latest"}})
+
+	plan, err := s.PlanPrune()
+	if err != nil {
+		t.Fatalf("PlanPrune should not return error, got: %v", err)
+	}
+	if got := strings.Join(planDigests(plan.Delete), ","); got != "sha256:cnab4,sha256:cnab5,sha256:cnab7,sha256:own1,sha256:shared,sha256:cnab1,sha256:cnab2" {
+		t.Errorf("delete plan = %s", got)
+	}
+}
+
+// TestPlanPrune_InvalidPolicy проверяет отказ для пустой и ошибочной политики
+func TestPlanPrune_InvalidPolicy(t *testing.T) {
+	policies := []data.PrunePolicy{
+		{},
+		{KeepLast: -1},
+		{KeepTags: []string{"v[1"}},
+		{KeepSemver: []string{">=one"}},
+	}
+	for _, policy := range policies {
+		s := newPruneSession(t, policy)
+		if _, err := s.PlanPrune(); !errors.Is(err, ErrPolicyInvalid) {
+			t.Errorf("PlanPrune(%+v) error = %v, want ErrPolicyInvalid", policy, err)
+		}
+	}
+}
@@ -2,8 +2,8 @@ package content
 
 import (
 	"cnabtool/pkg/client"
-	"cnabtool/pkg/logging"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,28 +15,39 @@ import (
 
 // PurgeEmptyFolders удаляет пустые родительские папки через Artifactory Storage API.
 // Работает и в --dry-run (показывает, что было бы удалено).
-// Перехватывает все ошибки без panic — даже при таймаутах или сетевых сбоях.
-func (c *Config) PurgeEmptyFolders(cl *client.RegClient) {
+// Перехватывает все ошибки без panic — даже при таймаутах или сетевых сбоях,
+// останавливается на первой ошибке и возвращает её. Таймаут удаления ошибкой не считается.
+func (s *Session) PurgeEmptyFolders() error {
+	c, cl := s.Config, s.Client
 	if !c.Purge {
-		return
+		return nil
 	}
 
 	repoKey := c.deriveRepoKey(cl)
 	if repoKey == "" {
-		logging.Error("purge: cannot derive repo-key, use --repo-key")
-		return
+		errLine := "purge: cannot derive repo-key, use --repo-key"
+		s.Config.Logger.Error(errLine)
+		return errors.New(errLine)
+	}
+
+	// первая ошибка, после неё purge останавливается
+	var purgeErr error
+	fail := func(errLine string) {
+		s.Config.Logger.Error(errLine)
+		purgeErr = errors.New(errLine)
 	}
 
 	// Начинаем с пути репозитория (без тега)
 	currentPath := cl.Repository
 	if currentPath == "" || currentPath == "/" {
-		logging.Debug("purge: empty repository path, nothing to purge")
-		return
+		s.Config.Logger.Debug("purge: empty repository path, nothing to purge")
+		return nil
 	}
 
 	// Artifactory DELETE папки может занимать >60 секунд.
 	// Отдельный клиент с таймаутом 180 секунд только для DELETE-операций purge.
-	purgeClient := &http.Client{Timeout: 180 * time.Second}
+	// Транспорт с настройками TLS берём у клиента registry.
+	purgeClient := &http.Client{Timeout: 180 * time.Second, Transport: cl.WebClient.Transport}
 
 	// Собираем длительности успешных DELETE для адаптивной остановки.
 	var deletionTimes []time.Duration
@@ -47,22 +58,22 @@ func (c *Config) PurgeEmptyFolders(cl *client.RegClient) {
 			cl.Scheme, cl.Registry, repoKey, currentPath)
 
 		if c.DryRun {
-			logging.Normal(fmt.Sprintf("[dry-run] Purge: check folder %s", storageURL))
+			s.Config.Logger.Normal(fmt.Sprintf("[dry-run] Purge: check folder %s", storageURL))
 		} else {
-			logging.Debug(fmt.Sprintf(">> Purge: check folder %s for emptiness", currentPath))
+			s.Config.Logger.Debug(fmt.Sprintf(">> Purge: check folder %s for emptiness", currentPath))
 		}
 
 		resp, err := cl.WebRequestEx("GET", storageURL)
 		if err != nil {
-			logging.Error(fmt.Sprintf("purge: cannot list folder %s: %v", currentPath, err))
+			fail(fmt.Sprintf("purge: cannot list folder %s: %v", currentPath, err))
 			break
 		}
 		if resp == nil {
-			logging.Error(fmt.Sprintf("purge: nil response for folder %s", currentPath))
+			fail(fmt.Sprintf("purge: nil response for folder %s", currentPath))
 			break
 		}
 		if resp.StatusCode != 200 {
-			logging.Error(fmt.Sprintf("purge: unexpected status %d for folder %s", resp.StatusCode, currentPath))
+			fail(fmt.Sprintf("purge: unexpected status %d for folder %s", resp.StatusCode, currentPath))
 			resp.Body.Close()
 			break
 		}
@@ -70,7 +81,7 @@ func (c *Config) PurgeEmptyFolders(cl *client.RegClient) {
 		body, err := io.ReadAll(io.LimitReader(resp.Body, client.MaxBodySize))
 		resp.Body.Close()
 		if err != nil {
-			logging.Error(fmt.Sprintf("purge: cannot read response for %s: %v", currentPath, err))
+			fail(fmt.Sprintf("purge: cannot read response for %s: %v", currentPath, err))
 			break
 		}
 
@@ -81,13 +92,13 @@ func (c *Config) PurgeEmptyFolders(cl *client.RegClient) {
 			} `json:"children"`
 		}
 		if err := json.Unmarshal(body, &info); err != nil {
-			logging.Error(fmt.Sprintf("purge: cannot parse list for %s: %v", currentPath, err))
+			fail(fmt.Sprintf("purge: cannot parse list for %s: %v", currentPath, err))
 			break
 		}
 
 		// Если есть дочерние элементы — папка не пустая, останавливаемся
 		if len(info.Children) > 0 {
-			logging.Debug(fmt.Sprintf(">> Purge: folder %s is not empty, stopping", currentPath))
+			s.Config.Logger.Debug(fmt.Sprintf(">> Purge: folder %s is not empty, stopping", currentPath))
 			break
 		}
 
@@ -96,13 +107,13 @@ func (c *Config) PurgeEmptyFolders(cl *client.RegClient) {
 			cl.Scheme, cl.Registry, repoKey, currentPath)
 
 		if c.DryRun {
-			logging.Normal(fmt.Sprintf("[dry-run] Purge: folder %s is empty, would delete %s", currentPath, deleteURL))
+			s.Config.Logger.Normal(fmt.Sprintf("[dry-run] Purge: folder %s is empty, would delete %s", currentPath, deleteURL))
 		} else {
-			logging.Normal(fmt.Sprintf("Purge: delete empty folder %s", currentPath))
+			s.Config.Logger.Normal(fmt.Sprintf("Purge: delete empty folder %s", currentPath))
 
 			req, err := http.NewRequest("DELETE", deleteURL, nil)
 			if err != nil {
-				logging.Error(fmt.Sprintf("purge: failed to build request for %s: %v", currentPath, err))
+				fail(fmt.Sprintf("purge: failed to build request for %s: %v", currentPath, err))
 				break
 			}
 			if cl.Credentials.Username != "" && cl.Credentials.Password != "" {
@@ -116,21 +127,21 @@ func (c *Config) PurgeEmptyFolders(cl *client.RegClient) {
 			if err != nil {
 				// Штатная обработка таймаута: Artifactory может дообработать удаление в фоне.
 				if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() {
-					logging.Normal(fmt.Sprintf("[warning] Purge: delete folder %s timed out after 180s. Artifactory may still process it in background. Stopping purge.", currentPath))
+					s.Config.Logger.Normal(fmt.Sprintf("[warning] Purge: delete folder %s timed out after 180s. Artifactory may still process it in background. Stopping purge.", currentPath))
 				} else {
-					logging.Error(fmt.Sprintf("purge: failed to delete folder %s: %v", currentPath, err))
+					fail(fmt.Sprintf("purge: failed to delete folder %s: %v", currentPath, err))
 				}
 				break
 			}
 			if delResp != nil {
 				delResp.Body.Close()
 				if delResp.StatusCode >= 300 {
-					logging.Error(fmt.Sprintf("purge: failed to delete folder %s: HTTP %d", currentPath, delResp.StatusCode))
+					fail(fmt.Sprintf("purge: failed to delete folder %s: HTTP %d", currentPath, delResp.StatusCode))
 					break
 				}
 			}
 
-			logging.Normal(fmt.Sprintf("Purge: folder %s deleted in %v", currentPath, elapsed))
+			s.Config.Logger.Normal(fmt.Sprintf("Purge: folder %s deleted in %v", currentPath, elapsed))
 			deletionTimes = append(deletionTimes, elapsed)
 
 			// Адаптивная остановка: если текущее удаление в 5+ раз медленнее среднего предыдущего.
@@ -141,7 +152,7 @@ func (c *Config) PurgeEmptyFolders(cl *client.RegClient) {
 				}
 				avg := sum / time.Duration(len(deletionTimes)-1)
 				if avg > 0 && elapsed > 5*avg {
-					logging.Normal(fmt.Sprintf("[warning] Purge: delete time %v is >5× average %v. Parent folder likely too heavy. Stopping purge.", elapsed, avg))
+					s.Config.Logger.Normal(fmt.Sprintf("[warning] Purge: delete time %v is >5× average %v. Parent folder likely too heavy. Stopping purge.", elapsed, avg))
 					break
 				}
 			}
@@ -155,14 +166,16 @@ func (c *Config) PurgeEmptyFolders(cl *client.RegClient) {
 		currentPath = parent
 	}
 
-	logging.Normal("Purge: completed")
+	s.Config.Logger.Normal("Purge: completed")
+	return purgeErr
 }
 
 // deriveRepoKey вычисляет repo-key Artifactory из hostname registry.
-// Если задан --repo-key, использует его. Иначе берёт первую часть hostname.
+// cl.RepoKey уже учитывает приоритет: --repo-key, затем repokey профиля registry,
+// затем repokey из конфига. Если он пуст, берёт первую часть hostname.
 func (c *Config) deriveRepoKey(cl *client.RegClient) string {
-	if c.RepoKey != "" {
-		return c.RepoKey
+	if cl.RepoKey != "" {
+		return cl.RepoKey
 	}
 	host := cl.Registry
 	if idx := strings.Index(host, ":"); idx != -1 {
@@ -0,0 +1,60 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newPurgeSession создаёт сессию с включённым purge для тестового сервера Artifactory
+func newPurgeSession(t *testing.T, handler http.HandlerFunc) *Session {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	s := newTestSession(t)
+	s.Config.Purge = true
+	s.Config.Scheme = "http"
+	s.Client = client.NewRegClient((*client.Config)(s.Config), strings.TrimPrefix(server.URL, "http://")+"/cnab/app:v1")
+	if err := s.Client.ParseReference(); err != nil {
+		t.Fatalf("ParseReference should not return error, got: %v", err)
+	}
+	s.Client.RepoKey = "docker-local"
+	return s
+}
+
+// TestPurgeEmptyFolders_Error проверяет, что сбой purge возвращается ошибкой
+func TestPurgeEmptyFolders_Error(t *testing.T) {
+	s := newPurgeSession(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	err := s.PurgeEmptyFolders()
+	if err == nil || !strings.Contains(err.Error(), "unexpected status 500") {
+		t.Errorf("PurgeEmptyFolders error = %v, want unexpected status 500", err)
+	}
+}
+
+// TestPurgeEmptyFolders_NotEmpty проверяет остановку на непустой папке без ошибки
+func TestPurgeEmptyFolders_NotEmpty(t *testing.T) {
+	var deleted []string
+	s := newPurgeSession(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/cnab/app") {
+			w.Write([]byte(`{"children":[]}`))
+			return
+		}
+		w.Write([]byte(`{"children":[{"uri":"/other","folder":true}]}`))
+	})
+	if err := s.PurgeEmptyFolders(); err != nil {
+		t.Fatalf("PurgeEmptyFolders should not return error, got: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "/artifactory/docker-local/cnab/app" {
+		t.Errorf("deleted = %v, want empty folder cnab/app only", deleted)
+	}
+}
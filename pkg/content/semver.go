@@ -0,0 +1,248 @@
+package content
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semantic version, build metadata is dropped
+// https://semver.org
+
+type semver struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+// parseSemver parse tag like v1.2.3, 1.2.3-rc.1 or 1.2, missing parts are zero
+
+func parseSemver(tag string) (semver, bool) {
+	var v semver
+	tag = strings.TrimPrefix(tag, "v")
+	tag, _, _ = strings.Cut(tag, "+")
+	tag, prerelease, found := strings.Cut(tag, "-")
+	if found {
+		if len(prerelease) == 0 {
+			return v, false
+		}
+		v.prerelease = strings.Split(prerelease, ".")
+	}
+	parts := strings.Split(tag, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+	numbers := []*int{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		*numbers[i] = n
+	}
+	return v, true
+}
+
+// compareSemver return -1, 0 or 1, prerelease is lower than release
+
+func compareSemver(a, b semver) int {
+	for _, pair := range [][2]int{{a.major, b.major}, {a.minor, b.minor}, {a.patch, b.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(a.prerelease) == 0 && len(b.prerelease) == 0:
+		return 0
+	case len(a.prerelease) == 0:
+		return 1
+	case len(b.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		if c := compareIdentifier(a.prerelease[i], b.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a.prerelease) < len(b.prerelease):
+		return -1
+	case len(a.prerelease) > len(b.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifier compare prerelease identifiers, numbers are lower than strings
+
+func compareIdentifier(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		if na == nb {
+			return 0
+		}
+		if na < nb {
+			return -1
+		}
+		return 1
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// one comparison of constraint
+
+type comparator struct {
+	op      string
+	version semver
+}
+
+func (c comparator) match(v semver) bool {
+	cmp := compareSemver(v, c.version)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// semver constraint - alternatives separated by ||, every alternative is
+// comparators separated by spaces or commas, e.g. ">=1.2.0 <2.0.0 || ^3.1"
+
+type semverConstraint [][]comparator
+
+// parseConstraint parse =, !=, >, >=, <, <=, ~ (same minor), ^ (same major)
+// and wildcards like 1.2.x. Partial version like 1 or =1.2 is wildcard 1.x or 1.2.x,
+// with other operators missing parts are zero
+
+func parseConstraint(constraint string) (semverConstraint, error) {
+	var sc semverConstraint
+	for _, alternative := range strings.Split(constraint, "||") {
+		var group []comparator
+		for _, field := range strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' }) {
+			comparators, err := parseComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid semver constraint %q, %w", constraint, err)
+			}
+			group = append(group, comparators...)
+		}
+		if len(group) == 0 {
+			return nil, errors.New(fmt.Sprintf("invalid semver constraint %q, empty alternative", constraint))
+		}
+		sc = append(sc, group)
+	}
+	return sc, nil
+}
+
+// parseComparator make comparators of one field, ranges become two comparators
+
+func parseComparator(field string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(field, prefix) {
+			op = prefix
+			break
+		}
+	}
+	text := strings.TrimPrefix(field, op)
+
+	// wildcard 1.x or 1.2.* means range of lower parts
+	parts := strings.Split(strings.TrimPrefix(text, "v"), ".")
+	wildcard := len(parts)
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			wildcard = i
+			parts = parts[:i]
+			break
+		}
+	}
+	if wildcard == 0 {
+		// any version
+		return []comparator{{op: ">=", version: semver{}}}, nil
+	}
+	v, ok := parseSemver(strings.Join(parts, "."))
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s is not semantic version", text))
+	}
+	if wildcard < 3 && (len(op) == 0 || op == "=") {
+		// partial version like 1 or =1.2 means every version with these parts,
+		// other operators fill missing parts with zero
+		return []comparator{{op: ">=", version: v}, {op: "<", version: bump(v, wildcard-1)}}, nil
+	}
+
+	switch op {
+	case "~":
+		// patch changes, or minor changes if minor is not given
+		level := 1
+		if len(parts) == 1 {
+			level = 0
+		}
+		return []comparator{{op: ">=", version: v}, {op: "<", version: bump(v, level)}}, nil
+	case "^":
+		// changes which don't modify the left-most non-zero part
+		level := 0
+		if v.major == 0 && len(parts) > 1 {
+			level = 1
+			if v.minor == 0 && len(parts) > 2 {
+				level = 2
+			}
+		}
+		return []comparator{{op: ">=", version: v}, {op: "<", version: bump(v, level)}}, nil
+	case "":
+		op = "="
+	}
+	return []comparator{{op: op, version: v}}, nil
+}
+
+// bump increment part of version by level 0 - major, 1 - minor, 2 - patch,
+// lower parts are zero, prerelease is dropped
+
+func bump(v semver, level int) semver {
+	switch level {
+	case 0:
+		return semver{major: v.major + 1}
+	case 1:
+		return semver{major: v.major, minor: v.minor + 1}
+	}
+	return semver{major: v.major, minor: v.minor, patch: v.patch + 1}
+}
+
+// match check if tag is semantic version, which satisfies constraint
+
+func (sc semverConstraint) match(tag string) bool {
+	v, ok := parseSemver(tag)
+	if !ok {
+		return false
+	}
+	for _, group := range sc {
+		matched := true
+		for _, c := range group {
+			if !c.match(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package content
+
+import "testing"
+
+// TestCompareSemver проверяет порядок версий, включая prerelease
+func TestCompareSemver(t *testing.T) {
+	ordered := []string{"0.9.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0", "v1.0.1", "1.2", "1.10.0", "2"}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, okA := parseSemver(ordered[i])
+		b, okB := parseSemver(ordered[i+1])
+		if !okA || !okB {
+			t.Fatalf("parseSemver(%q, %q) = %v, %v", ordered[i], ordered[i+1], okA, okB)
+		}
+		if compareSemver(a, b) != -1 || compareSemver(b, a) != 1 {
+			t.Errorf("%s must be lower than %s", ordered[i], ordered[i+1])
+		}
+	}
+	a, _ := parseSemver("v1.2.3+build.5")
+	b, _ := parseSemver("1.2.3")
+	if compareSemver(a, b) != 0 {
+		t.Errorf("build metadata must be ignored")
+	}
+	for _, tag := range []string{"latest", "1.2.3.4", "1.a", "1.2.3-", "", "-1.0"} {
+		if _, ok := parseSemver(tag); ok {
+			t.Errorf("parseSemver(%q) should fail", tag)
+		}
+	}
+}
+
+// TestSemverConstraint проверяет операторы, диапазоны и шаблоны ограничений
+func TestSemverConstraint(t *testing.T) {
+	testcases := []struct {
+		constraint string
+		match      []string
+		skip       []string
+	}{
+		{constraint: "1.2.3", match: []string{"1.2.3", "v1.2.3"}, skip: []string{"1.2.4", "1.2.3-rc.1"}},
+		{constraint: "!=1.2.3", match: []string{"1.2.4"}, skip: []string{"1.2.3"}},
+		{constraint: ">=1.2.0 <2.0.0", match: []string{"1.2.0", "1.9.9"}, skip: []string{"1.1.9", "2.0.0", "latest"}},
+		{constraint: ">1.0, <=1.5", match: []string{"1.0.1", "1.5.0"}, skip: []string{"1.0.0", "1.5.1"}},
+		{constraint: "~1.2.3", match: []string{"1.2.3", "1.2.9"}, skip: []string{"1.3.0", "1.2.2"}},
+		{constraint: "~1", match: []string{"1.0.0", "1.9.0"}, skip: []string{"2.0.0"}},
+		{constraint: "^1.2", match: []string{"1.2.0", "1.9.0"}, skip: []string{"2.0.0", "1.1.0"}},
+		{constraint: "^0.2.3", match: []string{"0.2.3", "0.2.9"}, skip: []string{"0.3.0"}},
+		{constraint: "^0.0.3", match: []string{"0.0.3"}, skip: []string{"0.0.4"}},
+		{constraint: "1.2.x", match: []string{"1.2.0", "1.2.7"}, skip: []string{"1.3.0"}},
+		{constraint: "2.*", match: []string{"2.0.0", "2.5.1"}, skip: []string{"3.0.0", "1.9.9"}},
+		{constraint: "*", match: []string{"0.0.1", "5.0.0"}, skip: []string{"latest"}},
+		{constraint: "1", match: []string{"1.0.0", "1.2.3", "v1.9.9"}, skip: []string{"2.0.0", "0.9.9"}},
+		{constraint: "=1.2", match: []string{"1.2.0", "1.2.3"}, skip: []string{"1.3.0"}},
+		{constraint: "=2", match: []string{"2.0.0", "2.5.1"}, skip: []string{"3.0.0", "1.9.9"}},
+		{constraint: ">=1 <2", match: []string{"1.0.0", "1.9.9"}, skip: []string{"2.0.0"}},
+		{constraint: "<1.0.0 || ^3.1", match: []string{"0.5.0", "3.1.0", "3.9.9"}, skip: []string{"1.0.0", "3.0.9", "4.0.0"}},
+	}
+	for _, tc := range testcases {
+		sc, err := parseConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("parseConstraint(%q) error: %v", tc.constraint, err)
+			continue
+		}
+		for _, tag := range tc.match {
+			if !sc.match(tag) {
+				t.Errorf("%q should match %s", tc.constraint, tag)
+			}
+		}
+		for _, tag := range tc.skip {
+			if sc.match(tag) {
+				t.Errorf("%q should not match %s", tc.constraint, tag)
+			}
+		}
+	}
+
+	for _, constraint := range []string{"", ">=abc", "1.2 ||", "~1.2.3.4"} {
+		if _, err := parseConstraint(constraint); err == nil {
+			t.Errorf("parseConstraint(%q) should return error", constraint)
+		}
+	}
+}
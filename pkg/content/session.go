@@ -0,0 +1,101 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/data"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// session owns config, registry client and graph of one cnab project,
+// so several projects may be inspected in one process
+
+type Session struct {
+	Config  *Config
+	Client  *client.RegClient
+	Project *data.Project
+
+	untagUnsupported bool  // registry rejected tag deletion once
+	partial          error // partial failure of inspect, graph is incomplete
+}
+
+// NewSession make session with empty project, project root is taken from client
+
+func NewSession(cc *Config, cl *client.RegClient) *Session {
+	project := data.NewProject()
+	project.Scheme = cl.Scheme
+	project.Registry = cl.Registry
+	project.Repository = cl.Repository
+	return &Session{
+		Config:  cc,
+		Client:  cl,
+		Project: project,
+	}
+}
+
+// OpenSession get manifest by reference and make session for its project
+
+func (cc *Config) OpenSession(reference string) (*Session, *client.RegResponse, error) {
+	regres, cl, err := cc.GetManifest(reference)
+	if cl == nil {
+		return nil, regres, err
+	}
+	return NewSession(cc, cl), regres, err
+}
+
+// RepositorySession make session for repository of reference without fetching manifest
+
+func (cc *Config) RepositorySession(reference string) (*Session, error) {
+	cl, err := cc.referenceClient(reference)
+	if err != nil {
+		return nil, err
+	}
+	return NewSession(cc, cl), nil
+}
+
+// OpenRepository make session for repository reference without tag and digest,
+// e.g. registry.example.com/project/cnab
+
+func (cc *Config) OpenRepository(repository string) (*Session, error) {
+	cl := client.NewRegClient((*client.Config)(cc), repository)
+	if err := cl.ParseRepository(); err != nil {
+		err_line := fmt.Sprintf("invalid repository %+v", err)
+		cc.Logger.Error(err_line)
+		return nil, errors.New(err_line)
+	}
+	// credentials for the registry from docker config, if not given explicitly
+	cl.ResolveCredentials()
+	return NewSession(cc, cl), nil
+}
+
+// rootItems return items of tags in order of tags, every item once. Item of
+// reference opened by digest goes first, it may have no tag
+
+func (s *Session) rootItems() []*data.RegIndex {
+	var items []*data.RegIndex
+	seen := make(map[*data.RegIndex]bool)
+	if root, ok := s.Project.ItemByDigest[s.Client.Digest]; ok && len(s.Client.Digest) != 0 {
+		seen[root] = true
+		items = append(items, root)
+	}
+	for _, tag := range s.SortedTags() {
+		item := s.Project.ItemByTag[tag]
+		if !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SortedTags return tags of project in stable order for reports
+
+func (s *Session) SortedTags() []string {
+	tags := make([]string, 0, len(s.Project.ItemByTag))
+	for tag := range s.Project.ItemByTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
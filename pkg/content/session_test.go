@@ -0,0 +1,77 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/data"
+	"cnabtool/pkg/logging"
+	"testing"
+)
+
+// TestSession_Independent проверяет, что две сессии в одном процессе не разделяют граф проекта
+func TestSession_Independent(t *testing.T) {
+
+	cfg := &Config{Logger: logging.New(logging.LogErrorLevel), Scheme: "https"}
+	s1 := NewSession(cfg, client.NewRegClient((*client.Config)(cfg), "registry.example.com/team-a/cnab:v1"))
+	s2 := NewSession(cfg, client.NewRegClient((*client.Config)(cfg), "registry.example.com/team-b/cnab:v1"))
+
+	if s1.Project.Repository != "team-a/cnab" || s2.Project.Repository != "team-b/cnab" {
+		t.Errorf("repositories = %q, %q; want team-a/cnab, team-b/cnab", s1.Project.Repository, s2.Project.Repository)
+	}
+
+	regres := &client.RegResponse{
+		Reference: "registry.example.com/team-a/cnab:v1",
+		Media:     client.MediaTypeOciIndex,
+		Digest:    "sha256:abc123",
+		Content:   `{"schemaVersion":2,"manifests":[]}`,
+	}
+	if err := s1.AddCnab(regres, "v1"); err != nil {
+		t.Fatalf("AddCnab should not return error, got: %v", err)
+	}
+
+	if len(s1.Project.ProjectList) != 1 || s1.Project.ItemByTag["v1"] == nil {
+		t.Errorf("first session should contain v1, got %+v", s1.Project)
+	}
+	if len(s2.Project.ProjectList) != 0 || len(s2.Project.ItemByDigest) != 0 || len(s2.Project.ItemByTag) != 0 {
+		t.Errorf("second session should stay empty, got %+v", s2.Project)
+	}
+}
+
+// TestSession_OwnLogger проверяет, что сессии разных конфигураций не разделяют счётчик ошибок и секреты
+func TestSession_OwnLogger(t *testing.T) {
+	cfg1 := &Config{Scheme: "https", Logger: logging.New(logging.LogQuietLevel)}
+	cfg2 := &Config{Scheme: "https", Logger: logging.New(logging.LogQuietLevel)}
+	cfg1.Credentials = data.Credentials{Username: "user1", Password: "secret1"}
+	s1 := NewSession(cfg1, client.NewRegClient((*client.Config)(cfg1), "registry.example.com/team-a/cnab:v1"))
+	s2 := NewSession(cfg2, client.NewRegClient((*client.Config)(cfg2), "registry.example.com/team-b/cnab:v1"))
+
+	s1.Config.Logger.AddSensitive(s1.Client.Credentials.Password)
+	if _, err := s1.Config.OpenRepository("registry.example.com/team-a/cnab:v1"); err == nil {
+		t.Fatal("OpenRepository should reject reference with tag")
+	}
+
+	if s1.Config.Logger.Errors() != 1 || s2.Config.Logger.Errors() != 0 {
+		t.Errorf("errors = %d, %d; want 1, 0", s1.Config.Logger.Errors(), s2.Config.Logger.Errors())
+	}
+	if s1.Client.Logger != cfg1.Logger || s2.Client.Logger != cfg2.Logger {
+		t.Error("client should log through logger of its config")
+	}
+	if s2.Config.Logger.Mask("secret1") != "secret1" {
+		t.Error("second session should not mask secrets of first")
+	}
+}
+
+// TestSession_SortedTags проверяет стабильный порядок тегов
+func TestSession_SortedTags(t *testing.T) {
+	s := newTestSession(t)
+	for _, tag := range []string{"v2", "v10", "v1"} {
+		s.Project.ItemByTag[tag] = nil
+	}
+	got := s.SortedTags()
+	want := []string{"v1", "v10", "v2"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SortedTags() = %v, want %v", got, want)
+			break
+		}
+	}
+}
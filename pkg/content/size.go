@@ -0,0 +1,107 @@
+package content
+
+import (
+	"cnabtool/pkg/data"
+
+	"github.com/buger/jsonparser"
+)
+
+// size of tagged item
+
+type ItemSize struct {
+	Size   int64 // manifests, configs and layers reachable from item
+	Unique int64 // bytes, which are not reachable from any other tag
+}
+
+// manifestBlobs collect manifest itself, config and layers of image manifest by digest
+
+func manifestBlobs(ri *data.RegIndex, length int) map[string]int64 {
+	blobs := map[string]int64{ri.Digest: int64(length)}
+	add := func(descriptor []byte) {
+		digest, err := jsonparser.GetString(descriptor, "digest")
+		if err != nil {
+			return
+		}
+		size, _ := jsonparser.GetInt(descriptor, "size")
+		blobs[digest] = size
+	}
+	if config, _, _, err := jsonparser.Get([]byte(ri.Content), "config"); err == nil {
+		add(config)
+	}
+	jsonparser.ArrayEach([]byte(ri.Content), func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		add(value)
+	}, "layers")
+	return blobs
+}
+
+// closure collect blobs reachable from item, items of other repositories are skipped
+
+func (s *Session) closure(item *data.RegIndex, blobs map[string]int64, visited map[string]bool) {
+	if visited[item.Digest] || len(item.Repository) != 0 {
+		return
+	}
+	visited[item.Digest] = true
+	for digest, size := range item.Blobs {
+		blobs[digest] = size
+	}
+	for _, link := range item.DownLinks {
+		if child, ok := s.Project.ItemByDigest[link.Digest]; ok {
+			s.closure(child, blobs, visited)
+		}
+	}
+}
+
+// sum total bytes of blobs
+
+func sum(blobs map[string]int64) int64 {
+	var total int64
+	for _, size := range blobs {
+		total += size
+	}
+	return total
+}
+
+// Sizes compute size and unique bytes of every tagged item and total bytes of
+// repository, blobs are counted once. Unique bytes are freed if only the item is deleted
+
+func (s *Session) Sizes() (map[string]ItemSize, int64) {
+	closures := make(map[*data.RegIndex]map[string]int64)
+	owners := make(map[string]map[*data.RegIndex]bool)
+	for _, item := range s.Project.ItemByTag {
+		if _, ok := closures[item]; ok {
+			continue
+		}
+		blobs := make(map[string]int64)
+		s.closure(item, blobs, make(map[string]bool))
+		closures[item] = blobs
+		for digest := range blobs {
+			if owners[digest] == nil {
+				owners[digest] = make(map[*data.RegIndex]bool)
+			}
+			owners[digest][item] = true
+		}
+	}
+
+	sizes := make(map[string]ItemSize)
+	for tag, item := range s.Project.ItemByTag {
+		blobs := closures[item]
+		size := ItemSize{Size: sum(blobs)}
+		for digest, bytes := range blobs {
+			if len(owners[digest]) == 0 || (len(owners[digest]) == 1 && owners[digest][item]) {
+				size.Unique += bytes
+			}
+		}
+		sizes[tag] = size
+	}
+
+	repository := make(map[string]int64)
+	for _, item := range s.Project.ProjectList {
+		if len(item.Repository) != 0 {
+			continue
+		}
+		for digest, size := range item.Blobs {
+			repository[digest] = size
+		}
+	}
+	return sizes, sum(repository)
+}
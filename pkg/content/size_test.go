@@ -0,0 +1,59 @@
+package content
+
+import (
+	"cnabtool/pkg/data"
+	"testing"
+)
+
+// TestManifestBlobs проверяет сбор размеров манифеста, конфига и слоёв
+func TestManifestBlobs(t *testing.T) {
+	ri := &data.RegIndex{
+		Digest:  "sha256:manifest",
+		Content: `{"schemaVersion":2,"config":{"digest":"sha256:config","size":10},"layers":[{"digest":"sha256:a","size":100},{"digest":"sha256:b","size":200}]}`,
+	}
+	blobs := manifestBlobs(ri, 50)
+	want := map[string]int64{"sha256:manifest": 50, "sha256:config": 10, "sha256:a": 100, "sha256:b": 200}
+	if len(blobs) != len(want) {
+		t.Fatalf("blobs = %v, want %v", blobs, want)
+	}
+	for digest, size := range want {
+		if blobs[digest] != size {
+			t.Errorf("blobs[%s] = %d, want %d", digest, blobs[digest], size)
+		}
+	}
+}
+
+// TestSizes проверяет общий и уникальный размер бандлов и итог репозитория
+func TestSizes(t *testing.T) {
+	s := newTestSession(t)
+	add := func(ri *data.RegIndex, blobs map[string]int64, tags ...string) {
+		ri.Blobs = blobs
+		s.Project.ItemByDigest[ri.Digest] = ri
+		s.Project.ProjectList = append(s.Project.ProjectList, ri)
+		for _, tag := range tags {
+			s.Project.ItemByTag[tag] = ri
+		}
+	}
+	// v1 и latest - один бандл, v2 делит с ним базовый образ
+	add(&data.RegIndex{Digest: "sha256:cnab1", Annotation: data.ItemTypeCnab, DownLinks: []data.CnabItem{{Digest: "sha256:img1"}, {Digest: "sha256:base"}}},
+		map[string]int64{"sha256:cnab1": 5}, "v1", "latest")
+	add(&data.RegIndex{Digest: "sha256:cnab2", Annotation: data.ItemTypeCnab, DownLinks: []data.CnabItem{{Digest: "sha256:base"}, {Digest: "sha256:foreign"}}},
+		map[string]int64{"sha256:cnab2": 7}, "v2")
+	add(&data.RegIndex{Digest: "sha256:img1"}, map[string]int64{"sha256:img1": 3, "sha256:layer1": 100, "sha256:shared": 1000})
+	add(&data.RegIndex{Digest: "sha256:base"}, map[string]int64{"sha256:base": 2, "sha256:shared": 1000})
+	add(&data.RegIndex{Digest: "sha256:foreign", Repository: "other.example.com/images"}, map[string]int64{"sha256:foreign": 4, "sha256:huge": 100000})
+
+	sizes, total := s.Sizes()
+	if got := sizes["v1"]; got.Size != 1110 || got.Unique != 108 {
+		t.Errorf("v1 size = %+v, want size 1110, unique 108", got)
+	}
+	if sizes["latest"] != sizes["v1"] {
+		t.Errorf("latest size = %+v, want the same as v1 %+v", sizes["latest"], sizes["v1"])
+	}
+	if got := sizes["v2"]; got.Size != 1009 || got.Unique != 7 {
+		t.Errorf("v2 size = %+v, want size 1009, unique 7", got)
+	}
+	if total != 1117 {
+		t.Errorf("repository total = %d, want 1117", total)
+	}
+}
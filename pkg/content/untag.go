@@ -0,0 +1,151 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// outcome of one untag
+
+type UntagResult struct {
+	Tag      string `json:"tag"`
+	Digest   string `json:"digest,omitempty"` // known for fallback only
+	Status   int    `json:"status"`
+	Untagged bool   `json:"untagged"`
+	Fallback bool   `json:"fallback"` // registry rejected tag deletion, manifest was deleted by digest
+	Body     string `json:"body,omitempty"`
+	Err      error  `json:"-"`
+}
+
+// Untag remove tags of repository, manifests are kept. The first rejection of tag
+// deletion switches session to fallback: manifest is deleted by digest, but only
+// if no other tag points to it. In dry run mode nothing is requested
+
+func (s *Session) Untag(tags []string) []UntagResult {
+	var results []UntagResult
+	var rejected []int
+	for _, tag := range tags {
+		s.Config.Logger.Message(fmt.Sprintf("Untag %s/%s:%s", s.Project.Registry, s.Project.Repository, tag))
+		if s.Config.DryRun {
+			continue
+		}
+		result := UntagResult{Tag: tag}
+		if !s.untagUnsupported {
+			status, body, err := s.Client.DeleteTag(tag)
+			result.Status, result.Body, result.Err = status, body, err
+			if err == nil {
+				s.Config.Logger.Message(fmt.Sprintf("Tag %s was deleted successfully", tag))
+				result.Untagged = true
+				results = append(results, result)
+				continue
+			}
+			if !errors.Is(err, client.ErrUntagUnsupported) {
+				s.Config.Logger.Error(fmt.Sprintf("can't delete tag %s, %+v", tag, err.Error()))
+				results = append(results, result)
+				continue
+			}
+			s.Config.Logger.Info(fmt.Sprintf("registry rejects tag deletion, %+v, fall back to delete by digest", err.Error()))
+			s.untagUnsupported = true
+		}
+		rejected = append(rejected, len(results))
+		results = append(results, result)
+	}
+	if len(rejected) != 0 {
+		s.untagFallback(results, rejected)
+	}
+	return results
+}
+
+// tagDigests map every tag of repository to digest of its manifest,
+// digest is empty if it is unknown
+
+func (s *Session) tagDigests() (map[string]string, error) {
+	tags, err := s.listTags()
+	if err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string)
+	for i, digest := range s.headIndexes(tags) {
+		digests[tags[i]] = digest
+	}
+	return digests, nil
+}
+
+// untagFallback delete manifests of rejected tags by digest, manifest with other tags is kept
+
+func (s *Session) untagFallback(results []UntagResult, rejected []int) {
+	digests, err := s.tagDigests()
+	if err != nil {
+		for _, i := range rejected {
+			results[i].Err = fmt.Errorf("%w, tags of repository are unknown: %w", client.ErrUntagUnsupported, err)
+		}
+		return
+	}
+	requested := make(map[string]bool)
+	for _, i := range rejected {
+		requested[results[i].Tag] = true
+	}
+
+	deleted := make(map[string]DeleteResult)
+	for _, i := range rejected {
+		result := &results[i]
+		result.Fallback = true
+		result.Digest = digests[result.Tag]
+		if len(result.Digest) == 0 {
+			result.Err = fmt.Errorf("%w: digest of tag %s is unknown", client.ErrUntagUnsupported, result.Tag)
+			s.Config.Logger.Error(result.Err.Error())
+			continue
+		}
+
+		// delete by digest removes every tag of manifest, tag with unknown digest may be one of them
+		var others []string
+		for tag, digest := range digests {
+			if !requested[tag] && (digest == result.Digest || len(digest) == 0) {
+				others = append(others, tag)
+			}
+		}
+		if len(others) != 0 {
+			sort.Strings(others)
+			result.Err = fmt.Errorf("%w: manifest %s of tag %s has other tags %s", client.ErrUntagUnsupported, result.Digest, result.Tag, strings.Join(others, ","))
+			s.Config.Logger.Error(result.Err.Error())
+			continue
+		}
+
+		deleteResult, ok := deleted[result.Digest]
+		if !ok {
+			deleteResult = s.deleteEntry(DeleteEntry{
+				Annotation: "tag " + result.Tag,
+				Digest:     result.Digest,
+				URL:        s.manifestURL(result.Digest),
+				Reason:     ReasonTarget,
+			})
+			deleted[result.Digest] = deleteResult
+		}
+		result.Status, result.Body, result.Err = deleteResult.Status, deleteResult.Body, deleteResult.Err
+		result.Untagged = deleteResult.Deleted
+	}
+}
+
+// TargetTags return tags of delete targets, see PlanDelete
+
+func (s *Session) TargetTags() ([]string, error) {
+	if err := s.Client.ParseReference(); err != nil {
+		errLine := fmt.Sprintf("can not parse reference %+v", err.Error())
+		s.Config.Logger.Error(errLine)
+		return nil, errors.New(errLine)
+	}
+	targets, err := s.deleteTargets()
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, tag := range s.SortedTags() {
+		if isTarget(targets, s.Project.ItemByTag[tag]) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
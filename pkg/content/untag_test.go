@@ -0,0 +1,96 @@
+package content
+
+import (
+	"cnabtool/pkg/client"
+	"cnabtool/pkg/data"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newUntagSession создаёт реестр с тегами v1 -> A, v2 и latest -> B
+func newUntagSession(t *testing.T, tagDelete bool) (*Session, *[]string) {
+	t.Helper()
+
+	digestA, digestB := "sha256:"+strings.Repeat("a", 64), "sha256:"+strings.Repeat("b", 64)
+	tags := map[string]string{"v1": digestA, "v2": digestB, "latest": digestB}
+	var mu sync.Mutex
+	var deletes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list/") {
+			w.Write([]byte(`{"name":"repo/cnab","tags":["latest","v1","v2"]}`))
+			return
+		}
+		_, reference, _ := strings.Cut(r.URL.Path, "/manifests/")
+		if r.Method == http.MethodHead {
+			digest, ok := tags[reference]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			w.Header().Set("Content-Type", client.MediaTypeOciIndex)
+			w.Header().Set("Docker-Content-Digest", digest)
+			return
+		}
+		mu.Lock()
+		deletes = append(deletes, reference)
+		mu.Unlock()
+		if _, isTag := tags[reference]; isTag && !tagDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(`{"errors":[{"code":"UNSUPPORTED"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &data.Config{Scheme: "http", Timeout: 10000}
+	s, err := (*Config)(cfg).RepositorySession(strings.TrimPrefix(server.URL, "http://") + "/repo/cnab:v1")
+	if err != nil {
+		t.Fatalf("RepositorySession should not return error, got: %v", err)
+	}
+	return s, &deletes
+}
+
+// TestUntag_Supported проверяет удаление тегов, когда реестр это поддерживает
+func TestUntag_Supported(t *testing.T) {
+	s, deletes := newUntagSession(t, true)
+
+	results := s.Untag([]string{"v1", "v2"})
+	if len(results) != 2 || !results[0].Untagged || !results[1].Untagged || results[0].Fallback {
+		t.Fatalf("results = %+v, want both tags untagged", results)
+	}
+	if strings.Join(*deletes, ",") != "v1,v2" {
+		t.Errorf("deletes = %v, want tags only", *deletes)
+	}
+
+	s.Config.DryRun = true
+	if results := s.Untag([]string{"latest"}); len(results) != 0 || len(*deletes) != 2 {
+		t.Errorf("dry run results = %+v, deletes %v", results, *deletes)
+	}
+}
+
+// TestUntag_Fallback проверяет удаление по digest, если реестр отклоняет удаление тега
+func TestUntag_Fallback(t *testing.T) {
+	s, deletes := newUntagSession(t, false)
+
+	results := s.Untag([]string{"v1", "v2"})
+	if len(results) != 2 {
+		t.Fatalf("results = %+v, want 2", results)
+	}
+	// у v1 нет других тегов, манифест удаляется по digest
+	if !results[0].Untagged || !results[0].Fallback || results[0].Digest != "sha256:"+strings.Repeat("a", 64) {
+		t.Errorf("v1 result = %+v, want deleted by digest", results[0])
+	}
+	// манифест v2 помечен ещё и latest
+	if results[1].Untagged || !errors.Is(results[1].Err, client.ErrUntagUnsupported) || !strings.Contains(results[1].Err.Error(), "latest") {
+		t.Errorf("v2 result = %+v, err %v, want refusal because of latest", results[1], results[1].Err)
+	}
+	// удаление тега пробуется один раз, дальше сразу fallback
+	if strings.Join(*deletes, ",") != "v1,"+results[0].Digest {
+		t.Errorf("deletes = %v, want one tag attempt and delete of v1 manifest", *deletes)
+	}
+}
@@ -4,56 +4,88 @@ Copyright © 2023 Aleksey Barabanov <alekseybb@gmail.com>
 
 package data
 
+import "cnabtool/pkg/logging"
+
 // config structure
 
 type Config struct {
 	// configuration
-	Verbosity int    `mapstructure:"verbosity"` // log level
-	Timeout   int    `mapstructure:"timeout"`   // web io timeout ms
-	Unsecure  bool   `mapstructure:"unsecure"`  // unsecure tls
-	Client    string `mapstructure:"client"`    // http client
-	Scheme    string `mapstructure:"scheme"`    // url scheme
-	Raw       bool   `mapstructure:"raw"`       // raw format - only for inspect content
-	DryRun    bool   `mapstructure:"dryrun"`    // dry-run mode - only for delete content
-	Purge     bool   `mapstructure:"purge"`     // purge empty folders via Artifactory API
-	RepoKey   string `mapstructure:"repokey"`   // Artifactory repository key (overrides hostname parsing)
-	Error     int    // errors count
+	Verbosity       int    `mapstructure:"verbosity"`       // log level
+	Timeout         int    `mapstructure:"timeout"`         // web io timeout ms
+	Unsecure        bool   `mapstructure:"unsecure"`        // unsecure tls
+	Client          string `mapstructure:"client"`          // http client
+	Scheme          string `mapstructure:"scheme"`          // url scheme
+	Raw             bool   `mapstructure:"raw"`             // raw format - only for inspect content
+	Head            bool   `mapstructure:"head"`            // headers only - only for manifest content
+	DryRun          bool   `mapstructure:"dryrun"`          // dry-run mode - only for delete content
+	AllBundles      bool   `mapstructure:"allbundles"`      // delete every cnab of repository - only for delete content
+	UntagOnly       bool   `mapstructure:"untagonly"`       // delete tags, keep manifests - only for delete content
+	Force           bool   `mapstructure:"force"`           // delete cnab with other tags - only for delete content
+	Purge           bool   `mapstructure:"purge"`           // purge empty folders via Artifactory API
+	RepoKey         string `mapstructure:"repokey"`         // Artifactory repository key (overrides hostname parsing)
+	CACert          string `mapstructure:"cacert"`          // extra CA bundle, pem
+	Cert            string `mapstructure:"cert"`            // client certificate, pem
+	Key             string `mapstructure:"key"`             // client certificate key, pem
+	CertsDir        string `mapstructure:"certsdir"`        // docker like certs.d directory with <host>/ subdirectories
+	PageSize        int    `mapstructure:"pagesize"`        // tags list page size, 0 - registry default
+	MaxManifestSize int64  `mapstructure:"maxmanifestsize"` // manifest body limit, bytes
+	Retries         int    `mapstructure:"retries"`         // retries on transient registry errors
+	RetryWait       int    `mapstructure:"retrywait"`       // first retry backoff ms
+	Concurrency     int    `mapstructure:"concurrency"`     // parallel manifest fetches
+	Field           string `mapstructure:"field"`           // json path - only for bundle content
+	Output          string `mapstructure:"output"`          // output file - bundle, delete and prune content
+	FollowImages    bool   `mapstructure:"followimages"`    // follow images of bundle.json - inspect and delete content
+	// retention policy - only for prune content
+	Prune PrunePolicy `mapstructure:"prune"`
 	// credentials
 	Credentials Credentials `mapstructure:"credentials"`
+	// per registry profiles, key is host or glob like *.example.com
+	Registries map[string]RegistryProfile `mapstructure:"registries"`
+	// settings given by command line flags, they win over registry profiles
+	Explicit map[string]bool `mapstructure:"-"`
+	// log level, errors count and masked literals of the config owner, nil is quiet
+	Logger *logging.Logger `mapstructure:"-"`
 
 	//WebClient http.Client // web client
 }
 
-// server url and credentials
+// registry profile overrides global settings for matched registry
 
-type Credentials struct {
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+type RegistryProfile struct {
+	Credentials Credentials `mapstructure:"credentials"`
+	Scheme      string      `mapstructure:"scheme"`   // url scheme
+	Unsecure    bool        `mapstructure:"unsecure"` // unsecure tls
+	Timeout     int         `mapstructure:"timeout"`  // web io timeout ms
+	Client      string      `mapstructure:"client"`   // http client
+	RepoKey     string      `mapstructure:"repokey"`  // Artifactory repository key
+	CACert      string      `mapstructure:"cacert"`   // extra CA bundle, pem
+	Cert        string      `mapstructure:"cert"`     // client certificate, pem
+	Key         string      `mapstructure:"key"`      // client certificate key, pem
 }
 
-// global config
-
-var Gc *Config = nil
+// retention policy, bundle is kept if any rule keeps one of its tags
 
-// list fo masked literals
-
-var Sensitives []string
-
-// link to project root
-
-var Scheme string
-var Registry string
-var Repository string
+type PrunePolicy struct {
+	KeepLast   int      `mapstructure:"keeplast"`   // keep N newest bundles
+	KeepDays   int      `mapstructure:"keepdays"`   // keep bundles younger than N days
+	KeepTags   []string `mapstructure:"keeptags"`   // keep tags matched by regular expressions
+	KeepSemver []string `mapstructure:"keepsemver"` // keep tags matched by semver constraints
+	Allowlist  []string `mapstructure:"allowlist"`  // always keep these tags
+}
 
-// items queue
+// server url and credentials
 
-var ItemsQueue []string
+type Credentials struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
 
 // cnab index item
 
 type CnabItem struct {
 	Digest     string
 	Annotation string
+	Platform   string // os/architecture/variant, child of image index only
 }
 
 // cnab catalog
@@ -65,16 +97,68 @@ type RegIndex struct {
 	Annotation string
 	Date       string
 	Digest     string
-	DownLinks  []CnabItem // down links
-	UpLinks    []CnabItem // up links
-	Lost       int        // link not found
-	Content    string     // pretty json
+	DownLinks  []CnabItem       // down links
+	UpLinks    []CnabItem       // up links
+	Lost       int              // link not found
+	Platform   string           // os/architecture/variant, child of image index only
+	Repository string           // registry/repository of image out of project, empty for project items
+	Blobs      map[string]int64 // sizes of manifest itself, config and layers by digest
+	Content    string           // pretty json
+	Bundle     *Bundle          // decoded bundle.json, cnab index only
+}
+
+// image of bundle
+// https://github.com/cnabio/cnab-spec/blob/main/101-bundle-json.md#the-image-list
+
+type BundleImage struct {
+	Image         string            `json:"image"`
+	ImageType     string            `json:"imageType,omitempty"`
+	ContentDigest string            `json:"contentDigest,omitempty"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Size          int64             `json:"size,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// bundle.json, definitions of parameters and others are kept as is
+// https://github.com/cnabio/cnab-spec/blob/main/101-bundle-json.md
+
+type Bundle struct {
+	SchemaVersion    string                            `json:"schemaVersion"`
+	Name             string                            `json:"name"`
+	Version          string                            `json:"version"`
+	Description      string                            `json:"description,omitempty"`
+	InvocationImages []BundleImage                     `json:"invocationImages,omitempty"`
+	Images           map[string]BundleImage            `json:"images,omitempty"`
+	Parameters       map[string]map[string]interface{} `json:"parameters,omitempty"`
+	Credentials      map[string]map[string]interface{} `json:"credentials,omitempty"`
+	Outputs          map[string]map[string]interface{} `json:"outputs,omitempty"`
+	Actions          map[string]map[string]interface{} `json:"actions,omitempty"`
+	Custom           map[string]interface{}            `json:"custom,omitempty"`
+}
+
+// cnab project graph
+
+type Project struct {
+	// link to project root
+	Scheme     string
+	Registry   string
+	Repository string
+
+	ItemsQueue   []string             // items queue
+	ProjectList  []*RegIndex          // items in order of registration
+	ItemByDigest map[string]*RegIndex // items by digest
+	ItemByTag    map[string]*RegIndex // items by tag
 }
 
-var ProjectList []*RegIndex
+// NewProject make empty project graph
 
-var ItemByDigest = make(map[string]*RegIndex)
-var ItemByTag = make(map[string]*RegIndex)
+func NewProject() *Project {
+	return &Project{
+		ItemByDigest: make(map[string]*RegIndex),
+		ItemByTag:    make(map[string]*RegIndex),
+	}
+}
 
 // catalog item types
 
@@ -82,5 +166,6 @@ const (
 	ItemTypeCnab   = "cnab index"
 	ItemTypeImage  = "docker image"
 	ItemTypeConfig = "cnab config"
+	ItemTypeIndex  = "image index"
 	ItemTypeStuff  = "stuff"
 )
@@ -32,7 +32,6 @@ func TestConfig_Struct(t *testing.T) {
 		DryRun:    true,
 		Purge:     true,
 		RepoKey:   "my-repo",
-		Error:     2,
 		Credentials: Credentials{
 			Username: "testuser",
 			Password: "testpass",
@@ -66,9 +65,6 @@ func TestConfig_Struct(t *testing.T) {
 	if cfg.RepoKey != "my-repo" {
 		t.Errorf("Config.RepoKey = %q, want %q", cfg.RepoKey, "my-repo")
 	}
-	if cfg.Error != 2 {
-		t.Errorf("Config.Error = %d, want 2", cfg.Error)
-	}
 	if cfg.Credentials.Username != "testuser" {
 		t.Errorf("Config.Credentials.Username = %q, want %q", cfg.Credentials.Username, "testuser")
 	}
@@ -108,9 +104,6 @@ func TestConfig_DefaultValues(t *testing.T) {
 	if cfg.RepoKey != "" {
 		t.Errorf("Config.RepoKey default = %q, want empty", cfg.RepoKey)
 	}
-	if cfg.Error != 0 {
-		t.Errorf("Config.Error default = %d, want 0", cfg.Error)
-	}
 }
 
 // TestCredentials_Struct проверяет структуру Credentials
@@ -258,116 +251,42 @@ func TestRegIndex_Default(t *testing.T) {
 	}
 }
 
-// TestGlobalState_Default проверяет начальное состояние глобальных переменных
-func TestGlobalState_Default(t *testing.T) {
-	// Сохраняем текущее состояние для восстановления
-	origGc := Gc
-	origSensitives := Sensitives
-	origScheme := Scheme
-	origRegistry := Registry
-	origRepository := Repository
-	origItemsQueue := ItemsQueue
-	origProjectList := ProjectList
-	origItemByDigest := ItemByDigest
-	origItemByTag := ItemByTag
-
-	defer func() {
-		// Восстанавливаем состояние
-		Gc = origGc
-		Sensitives = origSensitives
-		Scheme = origScheme
-		Registry = origRegistry
-		Repository = origRepository
-		ItemsQueue = origItemsQueue
-		ProjectList = origProjectList
-		ItemByDigest = origItemByDigest
-		ItemByTag = origItemByTag
-	}()
-
-	// Очищаем глобальное состояние
-	Gc = nil
-	Sensitives = nil
-	Scheme = ""
-	Registry = ""
-	Repository = ""
-	ItemsQueue = nil
-	ProjectList = nil
-	ItemByDigest = make(map[string]*RegIndex)
-	ItemByTag = make(map[string]*RegIndex)
-
-	// Проверяем начальное состояние
-	if Gc != nil {
-		t.Errorf("Gc default = %v, want nil", Gc)
-	}
-	if Sensitives != nil {
-		t.Errorf("Sensitives default = %v, want nil", Sensitives)
-	}
-	if Scheme != "" {
-		t.Errorf("Scheme default = %q, want empty", Scheme)
-	}
-	if Registry != "" {
-		t.Errorf("Registry default = %q, want empty", Registry)
-	}
-	if Repository != "" {
-		t.Errorf("Repository default = %q, want empty", Repository)
-	}
-	if ItemsQueue != nil {
-		t.Errorf("ItemsQueue default = %v, want nil", ItemsQueue)
-	}
-	if ProjectList != nil {
-		t.Errorf("ProjectList default = %v, want nil", ProjectList)
-	}
-	if len(ItemByDigest) != 0 {
-		t.Errorf("ItemByDigest default length = %d, want 0", len(ItemByDigest))
-	}
-	if len(ItemByTag) != 0 {
-		t.Errorf("ItemByTag default length = %d, want 0", len(ItemByTag))
+// TestNewProject_Default проверяет начальное состояние графа проекта
+func TestNewProject_Default(t *testing.T) {
+	p := NewProject()
+
+	if p.Scheme != "" {
+		t.Errorf("Scheme default = %q, want empty", p.Scheme)
+	}
+	if p.Registry != "" {
+		t.Errorf("Registry default = %q, want empty", p.Registry)
+	}
+	if p.Repository != "" {
+		t.Errorf("Repository default = %q, want empty", p.Repository)
+	}
+	if p.ItemsQueue != nil {
+		t.Errorf("ItemsQueue default = %v, want nil", p.ItemsQueue)
+	}
+	if p.ProjectList != nil {
+		t.Errorf("ProjectList default = %v, want nil", p.ProjectList)
+	}
+	if p.ItemByDigest == nil || len(p.ItemByDigest) != 0 {
+		t.Errorf("ItemByDigest default = %v, want empty map", p.ItemByDigest)
+	}
+	if p.ItemByTag == nil || len(p.ItemByTag) != 0 {
+		t.Errorf("ItemByTag default = %v, want empty map", p.ItemByTag)
 	}
 }
 
-// TestGlobalState_Modification проверяет модификацию глобальных переменных
-func TestGlobalState_Modification(t *testing.T) {
-	// Сохраняем текущее состояние для восстановления
-	origGc := Gc
-	origSensitives := Sensitives
-	origScheme := Scheme
-	origRegistry := Registry
-	origRepository := Repository
-	origItemsQueue := ItemsQueue
-	origProjectList := ProjectList
-	origItemByDigest := ItemByDigest
-	origItemByTag := ItemByTag
-
-	defer func() {
-		// Восстанавливаем состояние
-		Gc = origGc
-		Sensitives = origSensitives
-		Scheme = origScheme
-		Registry = origRegistry
-		Repository = origRepository
-		ItemsQueue = origItemsQueue
-		ProjectList = origProjectList
-		ItemByDigest = origItemByDigest
-		ItemByTag = origItemByTag
-	}()
-
-	// Очищаем
-	Gc = nil
-	Sensitives = nil
-	Scheme = ""
-	Registry = ""
-	Repository = ""
-	ItemsQueue = nil
-	ProjectList = nil
-	ItemByDigest = make(map[string]*RegIndex)
-	ItemByTag = make(map[string]*RegIndex)
-
-	// Модифицируем
-	Sensitives = []string{"password123", "secret-token"}
-	Scheme = "https"
-	Registry = "registry.example.com"
-	Repository = "repo/image"
-	ItemsQueue = []string{"sha256:abc", "sha256:def"}
+// TestProject_Independent проверяет, что проекты не разделяют состояние
+func TestProject_Independent(t *testing.T) {
+	p1 := NewProject()
+	p2 := NewProject()
+
+	p1.Scheme = "https"
+	p1.Registry = "registry.example.com"
+	p1.Repository = "repo/image"
+	p1.ItemsQueue = []string{"sha256:abc", "sha256:def"}
 
 	ri := &RegIndex{
 		Reference:  "registry.example.com/repo/image:v1",
@@ -376,37 +295,26 @@ func TestGlobalState_Modification(t *testing.T) {
 		Annotation: "cnab index",
 	}
 
-	ProjectList = []*RegIndex{ri}
-	ItemByDigest["sha256:v1"] = ri
-	ItemByTag["v1"] = ri
+	p1.ProjectList = []*RegIndex{ri}
+	p1.ItemByDigest["sha256:v1"] = ri
+	p1.ItemByTag["v1"] = ri
 
-	// Проверяем
-	if len(Sensitives) != 2 {
-		t.Errorf("Sensitives length = %d, want 2", len(Sensitives))
-	}
-	if Sensitives[0] != "password123" {
-		t.Errorf("Sensitives[0] = %q, want %q", Sensitives[0], "password123")
-	}
-	if Scheme != "https" {
-		t.Errorf("Scheme = %q, want %q", Scheme, "https")
+	if p1.Registry != "registry.example.com" {
+		t.Errorf("Registry = %q, want %q", p1.Registry, "registry.example.com")
 	}
-	if Registry != "registry.example.com" {
-		t.Errorf("Registry = %q, want %q", Registry, "registry.example.com")
+	if len(p1.ItemsQueue) != 2 {
+		t.Errorf("ItemsQueue length = %d, want 2", len(p1.ItemsQueue))
 	}
-	if Repository != "repo/image" {
-		t.Errorf("Repository = %q, want %q", Repository, "repo/image")
+	if p1.ItemByDigest["sha256:v1"] != ri {
+		t.Errorf("ItemByDigest['sha256:v1'] = %v, want %v", p1.ItemByDigest["sha256:v1"], ri)
 	}
-	if len(ItemsQueue) != 2 {
-		t.Errorf("ItemsQueue length = %d, want 2", len(ItemsQueue))
+	if p1.ItemByTag["v1"] != ri {
+		t.Errorf("ItemByTag['v1'] = %v, want %v", p1.ItemByTag["v1"], ri)
 	}
-	if len(ProjectList) != 1 {
-		t.Errorf("ProjectList length = %d, want 1", len(ProjectList))
-	}
-	if ItemByDigest["sha256:v1"] != ri {
-		t.Errorf("ItemByDigest['sha256:v1'] = %v, want %v", ItemByDigest["sha256:v1"], ri)
-	}
-	if ItemByTag["v1"] != ri {
-		t.Errorf("ItemByTag['v1'] = %v, want %v", ItemByTag["v1"], ri)
+
+	// второй проект остаётся пустым
+	if p2.Registry != "" || len(p2.ProjectList) != 0 || len(p2.ItemByDigest) != 0 || len(p2.ItemByTag) != 0 {
+		t.Errorf("second project should stay empty, got %+v", p2)
 	}
 }
 
@@ -659,6 +567,8 @@ func TestRegIndex_ReferenceParsing(t *testing.T) {
 
 // TestRegIndex_Compound проверяет составные операции
 func TestRegIndex_Compound(t *testing.T) {
+	p := NewProject()
+
 	// Создаём несколько RegIndex и добавляем в ProjectList
 	ri1 := &RegIndex{
 		Reference:  "registry.example.com/repo/image:v1",
@@ -675,48 +585,48 @@ func TestRegIndex_Compound(t *testing.T) {
 	}
 
 	// Добавляем в ProjectList
-	ProjectList = append(ProjectList, ri1, ri2)
+	p.ProjectList = append(p.ProjectList, ri1, ri2)
 
-	if len(ProjectList) != 2 {
-		t.Errorf("ProjectList length = %d, want 2", len(ProjectList))
+	if len(p.ProjectList) != 2 {
+		t.Errorf("ProjectList length = %d, want 2", len(p.ProjectList))
 	}
 
 	// Добавляем в ItemByDigest
-	ItemByDigest["sha256:v1"] = ri1
-	ItemByDigest["sha256:v2"] = ri2
+	p.ItemByDigest["sha256:v1"] = ri1
+	p.ItemByDigest["sha256:v2"] = ri2
 
-	if len(ItemByDigest) != 2 {
-		t.Errorf("ItemByDigest length = %d, want 2", len(ItemByDigest))
+	if len(p.ItemByDigest) != 2 {
+		t.Errorf("ItemByDigest length = %d, want 2", len(p.ItemByDigest))
 	}
 
 	// Добавляем в ItemByTag
-	ItemByTag["v1"] = ri1
-	ItemByTag["v2"] = ri2
+	p.ItemByTag["v1"] = ri1
+	p.ItemByTag["v2"] = ri2
 
-	if len(ItemByTag) != 2 {
-		t.Errorf("ItemByTag length = %d, want 2", len(ItemByTag))
+	if len(p.ItemByTag) != 2 {
+		t.Errorf("ItemByTag length = %d, want 2", len(p.ItemByTag))
 	}
 
 	// Проверяем связь
-	if ItemByDigest["sha256:v1"] != ri1 {
+	if p.ItemByDigest["sha256:v1"] != ri1 {
 		t.Error("ItemByDigest['sha256:v1'] should point to ri1")
 	}
-	if ItemByTag["v1"] != ri1 {
+	if p.ItemByTag["v1"] != ri1 {
 		t.Error("ItemByTag['v1'] should point to ri1")
 	}
-	if ItemByDigest["sha256:v2"] != ri2 {
+	if p.ItemByDigest["sha256:v2"] != ri2 {
 		t.Error("ItemByDigest['sha256:v2'] should point to ri2")
 	}
-	if ItemByTag["v2"] != ri2 {
+	if p.ItemByTag["v2"] != ri2 {
 		t.Error("ItemByTag['v2'] should point to ri2")
 	}
 
 	// Обновляем существующий элемент
 	ri1.Lost = 3
-	ItemByDigest["sha256:v1"] = ri1
+	p.ItemByDigest["sha256:v1"] = ri1
 
 	// Проверяем, что обновление отразилось
-	if ItemByDigest["sha256:v1"].Lost != 3 {
-		t.Errorf("After update, Lost = %d, want 3", ItemByDigest["sha256:v1"].Lost)
+	if p.ItemByDigest["sha256:v1"].Lost != 3 {
+		t.Errorf("After update, Lost = %d, want 3", p.ItemByDigest["sha256:v1"].Lost)
 	}
 }
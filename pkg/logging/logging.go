@@ -6,13 +6,13 @@ package logging
 
 import (
 	"bytes"
-	"cnabtool/pkg/data"
 	"encoding/json"
 	"fmt"
 	"log"
 	"path"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 const (
@@ -23,78 +23,145 @@ const (
 	LogDebugLevel  = 4 // debug messages + errors
 )
 
-func maskcredentials(mess string) string {
-	res := mess
-	for _, secret := range data.Sensitives {
-		s := strings.ReplaceAll(res, secret, "*******")
-		res = s
+// Logger owns log level, count of errors and sensitive literals of one session,
+// so sessions of one process don't share them. Nil logger is quiet and counts nothing
+
+type Logger struct {
+	mu         sync.Mutex
+	verbosity  int      // current log level
+	errorCount int      // errors count
+	sensitives []string // list of masked literals
+}
+
+// New make logger with log level
+
+func New(verbosity int) *Logger {
+	return &Logger{verbosity: verbosity}
+}
+
+// SetVerbosity set log level
+
+func (l *Logger) SetVerbosity(level int) {
+	if l == nil {
+		return
 	}
-	return res
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.verbosity = level
 }
 
-func Error(mess string) {
-	data.Gc.Error++
-	pc, file, lineNo, ok := runtime.Caller(1)
-	point := "n/a"
-	if ok {
-		funcName := runtime.FuncForPC(pc).Name()
-		fileName := path.Base(file)
-		point = fmt.Sprintf("%s - %s - %d", fileName, funcName, lineNo)
+// Verbosity return current log level
+
+func (l *Logger) Verbosity() int {
+	if l == nil {
+		return LogQuietLevel
 	}
-	if data.Gc.Verbosity >= LogErrorLevel {
-		log.Printf("%s Error >> %s\n", point, maskcredentials(mess))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.verbosity
+}
+
+// Errors return count of logged errors
+
+func (l *Logger) Errors() int {
+	if l == nil {
+		return 0
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.errorCount
 }
 
-func Fatal(mess string) {
-	pc, file, lineNo, ok := runtime.Caller(1)
-	point := "n/a"
-	if ok {
-		funcName := runtime.FuncForPC(pc).Name()
-		fileName := path.Base(file)
-		point = fmt.Sprintf("%s - %s - %d", fileName, funcName, lineNo)
+// AddSensitive add literal to masked list, empty literal is ignored
+
+func (l *Logger) AddSensitive(secret string) {
+	if l == nil || len(secret) == 0 {
+		return
 	}
-	if data.Gc.Verbosity >= LogErrorLevel {
-		log.Fatalf("%s Error >> %s\n", point, maskcredentials(mess))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sensitives = append(l.sensitives, secret)
+}
+
+// Sensitives return copy of masked literals
+
+func (l *Logger) Sensitives() []string {
+	if l == nil {
+		return nil
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.sensitives...)
 }
 
-func Message(mess string) {
-	if data.Gc.Verbosity >= LogNormalLevel {
-		log.Printf(">> %s\n", maskcredentials(mess))
+// Mask hide sensitive literals in message
+
+func (l *Logger) Mask(mess string) string {
+	if l == nil {
+		return mess
 	}
+	return l.maskcredentials(mess)
 }
 
-func Normal(mess string) {
-	pc, file, lineNo, ok := runtime.Caller(1)
-	point := "n/a"
-	if ok {
-		funcName := runtime.FuncForPC(pc).Name()
-		fileName := path.Base(file)
-		point = fmt.Sprintf("%s - %s - %d", fileName, funcName, lineNo)
+func (l *Logger) maskcredentials(mess string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	res := mess
+	for _, secret := range l.sensitives {
+		s := strings.ReplaceAll(res, secret, "*******")
+		res = s
 	}
-	if data.Gc.Verbosity >= LogNormalLevel {
-		log.Printf("%s Info >> %s\n", point, maskcredentials(mess))
+	return res
+}
+
+// callPoint return file, function and line of logging call
+
+func callPoint() string {
+	pc, file, lineNo, ok := runtime.Caller(2)
+	if !ok {
+		return "n/a"
 	}
+	funcName := runtime.FuncForPC(pc).Name()
+	fileName := path.Base(file)
+	return fmt.Sprintf("%s - %s - %d", fileName, funcName, lineNo)
 }
 
-func Info(mess string) {
-	if data.Gc.Verbosity >= LogInfoLevel {
-		log.Printf("Info >> %s\n", maskcredentials(mess))
+func (l *Logger) Error(mess string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.errorCount++
+	l.mu.Unlock()
+	point := callPoint()
+	if l.Verbosity() >= LogErrorLevel {
+		log.Printf("%s Error >> %s\n", point, l.maskcredentials(mess))
+	}
+}
+
+func (l *Logger) Message(mess string) {
+	if l.Verbosity() >= LogNormalLevel {
+		log.Printf(">> %s\n", l.maskcredentials(mess))
 	}
 }
 
-func Debug(mess string) {
-	pc, file, lineNo, ok := runtime.Caller(1)
-	point := "n/a"
-	if ok {
-		funcName := runtime.FuncForPC(pc).Name()
-		fileName := path.Base(file)
-		point = fmt.Sprintf("%s - %s - %d", fileName, funcName, lineNo)
+func (l *Logger) Normal(mess string) {
+	point := callPoint()
+	if l.Verbosity() >= LogNormalLevel {
+		log.Printf("%s Info >> %s\n", point, l.maskcredentials(mess))
 	}
+}
+
+func (l *Logger) Info(mess string) {
+	if l.Verbosity() >= LogInfoLevel {
+		log.Printf("Info >> %s\n", l.maskcredentials(mess))
+	}
+}
 
-	if data.Gc.Verbosity >= LogDebugLevel {
-		log.Printf("Debug: %s >> %s\n", point, maskcredentials(mess))
+func (l *Logger) Debug(mess string) {
+	point := callPoint()
+	if l.Verbosity() >= LogDebugLevel {
+		log.Printf("Debug: %s >> %s\n", point, l.maskcredentials(mess))
 	}
 }
 
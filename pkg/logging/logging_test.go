@@ -1,7 +1,6 @@
 package logging
 
 import (
-	"cnabtool/pkg/data"
 	"io"
 	"log"
 	"strings"
@@ -54,215 +53,130 @@ func captureLogOutput() *logCapture {
 	return cap
 }
 
-// TestError_IncrementsErrorCount проверяет, что Error инкрементирует data.Gc.Error
+// TestError_IncrementsErrorCount проверяет, что Error увеличивает счётчик ошибок
 func TestError_IncrementsErrorCount(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-	}()
+	l := New(LogDebugLevel)
 
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
-
-	before := data.Gc.Error
+	before := l.Errors()
 
-	Error("test error message")
+	l.Error("test error message")
 
-	if data.Gc.Error != before+1 {
-		t.Errorf("data.Gc.Error after Error() = %d, want %d", data.Gc.Error, before+1)
+	if l.Errors() != before+1 {
+		t.Errorf("l.Errors() after l.Error() = %d, want %d", l.Errors(), before+1)
 	}
 }
 
 // TestError_VerbosityFilter проверяет фильтрацию по уровню verbosity
 func TestError_VerbosityFilter(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogErrorLevel,
-	}
+	l := New(LogErrorLevel)
 
-	Error("test error")
+	l.Error("test error")
 
-	if data.Gc.Error != 1 {
-		t.Errorf("data.Gc.Error should be incremented even at LogErrorLevel, got %d", data.Gc.Error)
+	if l.Errors() != 1 {
+		t.Errorf("l.Errors() should be incremented even at LogErrorLevel, got %d", l.Errors())
 	}
 }
 
 // TestMessage_VerbosityFilter проверяет, что Message логируется при LogNormalLevel+
 func TestMessage_VerbosityFilter(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogNormalLevel)
 
-	data.Gc = &data.Config{
-		Verbosity: LogNormalLevel,
-	}
-
-	Message("test message")
+	l.Message("test message")
 
 	// Проверяем, что Message не вызывает ошибок
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestMessage_NotLoggedAtLowVerbosity проверяет, что Message не логируется при LogErrorLevel
 func TestMessage_NotLoggedAtLowVerbosity(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogErrorLevel,
-	}
+	l := New(LogErrorLevel)
 
-	Message("should not be logged at error level")
+	l.Message("should not be logged at error level")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestInfo_VerbosityFilter проверяет, что Info логируется при LogInfoLevel+
 func TestInfo_VerbosityFilter(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogInfoLevel)
 
-	data.Gc = &data.Config{
-		Verbosity: LogInfoLevel,
-	}
-
-	Info("test info message")
+	l.Info("test info message")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Info should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Info should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestInfo_NotLoggedAtLowVerbosity проверяет, что Info не логируется при LogNormalLevel
 func TestInfo_NotLoggedAtLowVerbosity(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogNormalLevel,
-	}
+	l := New(LogNormalLevel)
 
-	Info("should not be logged at normal level")
+	l.Info("should not be logged at normal level")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Info should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Info should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestDebug_VerbosityFilter проверяет, что Debug логируется при LogDebugLevel+
 func TestDebug_VerbosityFilter(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogDebugLevel)
 
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
-
-	Debug("test debug message")
+	l.Debug("test debug message")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Debug should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Debug should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestDebug_NotLoggedAtLowVerbosity проверяет, что Debug не логируется при LogInfoLevel
 func TestDebug_NotLoggedAtLowVerbosity(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogInfoLevel)
 
-	data.Gc = &data.Config{
-		Verbosity: LogInfoLevel,
-	}
+	l.Debug("should not be logged at info level")
 
-	Debug("should not be logged at info level")
-
-	if data.Gc.Error != 0 {
-		t.Errorf("Debug should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Debug should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestMaskCredentials_Simple проверяет простую замену чувствительных данных
 func TestMaskCredentials_Simple(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
-	data.Sensitives = []string{"secret123", "password"}
+	l := New(LogDebugLevel)
+	l.sensitives = []string{"secret123", "password"}
 
-	Message("test secret123 data")
+	l.Message("test secret123 data")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestMaskCredentials_MultipleSecrets проверяет замену нескольких чувствительных данных
 func TestMaskCredentials_MultipleSecrets(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-	}()
+	l := New(LogDebugLevel)
+	l.sensitives = []string{"secret1", "secret2"}
 
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
-	data.Sensitives = []string{"secret1", "secret2"}
+	l.Message("test secret1 and secret2")
 
-	Message("test secret1 and secret2")
-
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestMaskCredentials_EmptySensitives проверяет работу с пустым списком
 func TestMaskCredentials_EmptySensitives(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
-	data.Sensitives = nil
+	l := New(LogDebugLevel)
+	l.sensitives = nil
 
-	Message("test no secrets")
+	l.Message("test no secrets")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
@@ -394,154 +308,92 @@ func TestPrettyString_ComplexJSON(t *testing.T) {
 
 // TestError_VerbosityLevels проверяет Error при разных уровнях verbosity
 func TestError_VerbosityLevels(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogErrorLevel)
 
-	data.Gc = &data.Config{
-		Verbosity: LogErrorLevel,
-	}
-
-	Error("test error")
+	l.Error("test error")
 
-	if data.Gc.Error != 1 {
-		t.Errorf("data.Gc.Error should be incremented at LogErrorLevel, got %d", data.Gc.Error)
+	if l.Errors() != 1 {
+		t.Errorf("l.Errors() should be incremented at LogErrorLevel, got %d", l.Errors())
 	}
 }
 
 // TestError_QuietMode проверяет, что Error не логируется при LogQuietLevel
 func TestError_QuietMode(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogQuietLevel,
-	}
+	l := New(LogQuietLevel)
 
-	Error("should not be logged")
+	l.Error("should not be logged")
 
-	if data.Gc.Error != 1 {
-		t.Errorf("data.Gc.Error should still be incremented at LogQuietLevel, got %d", data.Gc.Error)
+	if l.Errors() != 1 {
+		t.Errorf("l.Errors() should still be incremented at LogQuietLevel, got %d", l.Errors())
 	}
 }
 
 // TestMessage_NormalLevel проверяет Message при LogNormalLevel
 func TestMessage_NormalLevel(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogNormalLevel)
 
-	data.Gc = &data.Config{
-		Verbosity: LogNormalLevel,
-	}
-
-	Message("normal message")
+	l.Message("normal message")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestInfo_InfoLevel проверяет Info при LogInfoLevel
 func TestInfo_InfoLevel(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogInfoLevel,
-	}
+	l := New(LogInfoLevel)
 
-	Info("info message")
+	l.Info("info message")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Info should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Info should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestDebug_DebugLevel проверяет Debug при LogDebugLevel
 func TestDebug_DebugLevel(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
+	l := New(LogDebugLevel)
 
-	Debug("debug message")
+	l.Debug("debug message")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Debug should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Debug should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestMaskCredentials_Password проверяет замену паролей
 func TestMaskCredentials_Password(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
-	data.Sensitives = []string{"mypassword123"}
+	l := New(LogDebugLevel)
+	l.sensitives = []string{"mypassword123"}
 
-	Message("auth with mypassword123")
+	l.Message("auth with mypassword123")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestMaskCredentials_Base64Auth проверяет замену base64 auth tokens
 func TestMaskCredentials_Base64Auth(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
-	data.Sensitives = []string{"dGVzdHVzZXI6dGVzdHBhc3M="} // base64("testuser:testpass")
+	l := New(LogDebugLevel)
+	l.sensitives = []string{"dGVzdHVzZXI6dGVzdHBhc3M="} // base64("testuser:testpass")
 
-	Message("auth header dGVzdHVzZXI6dGVzdHBhc3M=")
+	l.Message("auth header dGVzdHVzZXI6dGVzdHBhc3M=")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestMaskCredentials_NoMatch проверяет работу, когда чувствительные данные не найдены
 func TestMaskCredentials_NoMatch(t *testing.T) {
-	origGc := data.Gc
-	origSensitives := data.Sensitives
-	defer func() {
-		data.Gc = origGc
-		data.Sensitives = origSensitives
-	}()
+	l := New(LogDebugLevel)
+	l.sensitives = []string{"notfound", "nobody"}
 
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
-	data.Sensitives = []string{"notfound", "nobody"}
+	l.Message("test safe data")
 
-	Message("test safe data")
-
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
@@ -795,83 +647,61 @@ func TestPrettyString_JSONWithDeeplyNested(t *testing.T) {
 
 // TestError_ConcurrentSafety проверяет, что Error корректно инкрементирует counter
 func TestError_ConcurrentSafety(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
-
-	data.Gc = &data.Config{
-		Verbosity: LogErrorLevel,
-	}
+	l := New(LogErrorLevel)
 
 	// Сохраняем текущее значение
-	initialError := data.Gc.Error
+	initialError := l.Errors()
 
 	// Вызываем Error несколько раз
 	for i := 0; i < 10; i++ {
-		Error("concurrent test")
+		l.Error("concurrent test")
 	}
 
 	expected := initialError + 10
-	if data.Gc.Error != expected {
-		t.Errorf("data.Gc.Error after 10 Error() calls = %d, want %d", data.Gc.Error, expected)
+	if l.Errors() != expected {
+		t.Errorf("l.Errors() after 10 l.Error() calls = %d, want %d", l.Errors(), expected)
 	}
 }
 
 // TestMessage_AllLevels проверяет Message при разных уровнях
 func TestMessage_AllLevels(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogQuietLevel)
 
 	// При LogNormalLevel Message должен логироваться
-	data.Gc = &data.Config{
-		Verbosity: LogNormalLevel,
-	}
+	l.SetVerbosity(LogNormalLevel)
 
-	Message("test at normal level")
+	l.Message("test at normal level")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Message should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Message should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestInfo_AllLevels проверяет Info при разных уровнях
 func TestInfo_AllLevels(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogQuietLevel)
 
 	// При LogInfoLevel Info должен логироваться
-	data.Gc = &data.Config{
-		Verbosity: LogInfoLevel,
-	}
+	l.SetVerbosity(LogInfoLevel)
 
-	Info("test at info level")
+	l.Info("test at info level")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Info should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Info should not increment Error counter, got %d", l.Errors())
 	}
 }
 
 // TestDebug_AllLevels проверяет Debug при разных уровнях
 func TestDebug_AllLevels(t *testing.T) {
-	origGc := data.Gc
-	defer func() {
-		data.Gc = origGc
-	}()
+	l := New(LogQuietLevel)
 
 	// При LogDebugLevel Debug должен логироваться
-	data.Gc = &data.Config{
-		Verbosity: LogDebugLevel,
-	}
+	l.SetVerbosity(LogDebugLevel)
 
-	Debug("test at debug level")
+	l.Debug("test at debug level")
 
-	if data.Gc.Error != 0 {
-		t.Errorf("Debug should not increment Error counter, got %d", data.Gc.Error)
+	if l.Errors() != 0 {
+		t.Errorf("Debug should not increment Error counter, got %d", l.Errors())
 	}
 }
 
@@ -1601,3 +1431,50 @@ func TestPrettyString_JSONWithCNABInspectResult(t *testing.T) {
 		t.Error("PrettyString result should contain 'lost'")
 	}
 }
+
+// TestLogger_Independent проверяет, что два логгера не разделяют уровень, ошибки и маскируемые строки
+func TestLogger_Independent(t *testing.T) {
+	first := New(LogQuietLevel)
+	second := New(LogDebugLevel)
+
+	first.AddSensitive("first-secret")
+	first.Error("first failed")
+	first.Error("first failed again")
+
+	if first.Errors() != 2 || second.Errors() != 0 {
+		t.Errorf("Errors() = %d, %d; want 2, 0", first.Errors(), second.Errors())
+	}
+	if first.Verbosity() != LogQuietLevel || second.Verbosity() != LogDebugLevel {
+		t.Errorf("Verbosity() = %d, %d; want %d, %d", first.Verbosity(), second.Verbosity(), LogQuietLevel, LogDebugLevel)
+	}
+	if first.Mask("first-secret") == "first-secret" {
+		t.Error("first logger should mask its secret")
+	}
+	if second.Mask("first-secret") != "first-secret" || len(second.Sensitives()) != 0 {
+		t.Errorf("second logger should not know secrets of first, got %v", second.Sensitives())
+	}
+
+	// копия списка не меняет логгер
+	sensitives := first.Sensitives()
+	sensitives[0] = "changed"
+	if first.Sensitives()[0] != "first-secret" {
+		t.Error("Sensitives() should return copy")
+	}
+}
+
+// TestLogger_Nil проверяет, что nil логгер молчит и ничего не считает
+func TestLogger_Nil(t *testing.T) {
+	var l *Logger
+	l.SetVerbosity(LogDebugLevel)
+	l.AddSensitive("secret")
+	l.Error("ignored")
+	l.Message("ignored")
+	l.Debug("ignored")
+
+	if l.Verbosity() != LogQuietLevel || l.Errors() != 0 || l.Sensitives() != nil {
+		t.Errorf("nil logger = %d, %d, %v; want quiet and empty", l.Verbosity(), l.Errors(), l.Sensitives())
+	}
+	if l.Mask("secret") != "secret" {
+		t.Error("nil logger should not mask")
+	}
+}